@@ -4,6 +4,8 @@ import (
 	"context"
 	"fmt"
 	"os"
+	// the scratch image has no zoneinfo for link schedule time zones
+	_ "time/tzdata"
 
 	"github.com/jacekdobrowolski/goshort/internal/links"
 	_ "go.uber.org/automaxprocs"
@@ -11,7 +13,18 @@ import (
 
 func main() {
 	ctx := context.Background()
-	if err := links.Run(ctx, os.Stdout, os.Getenv); err != nil {
+
+	run := func() error {
+		return links.Run(ctx, os.Stdout, os.Getenv)
+	}
+
+	if links.IsAdminCommand(os.Args[1:]) {
+		run = func() error {
+			return links.RunAdmin(ctx, os.Args[1:], os.Stdout, os.Getenv)
+		}
+	}
+
+	if err := run(); err != nil {
 		fmt.Fprintf(os.Stderr, "%s\n", err)
 		os.Exit(1)
 	}
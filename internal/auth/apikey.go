@@ -0,0 +1,146 @@
+package auth
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+)
+
+const (
+	apiKeyPrefix    = "gsk_"
+	apiKeyIDBytes   = 6
+	apiKeySecretLen = 32
+
+	APIKeyHeader = "X-Api-Key"
+	MethodAPIKey = "api_key"
+)
+
+var ErrUnknownAPIKey = errors.New("unknown api key")
+
+type APIKey struct {
+	ID        string     `db:"id"`
+	Name      string     `db:"name"`
+	TenantID  string     `db:"tenant_id"`
+	Hash      []byte     `db:"hash"`
+	Scopes    []Scope    `db:"-"`
+	CreatedAt time.Time  `db:"created_at"`
+	RevokedAt *time.Time `db:"revoked_at"`
+}
+
+type APIKeyStore interface {
+	GetAPIKey(ctx context.Context, id string) (*APIKey, error)
+}
+
+// NewAPIKey generates a key, the returned token is shown to the user once
+// and only its hash is kept.
+func NewAPIKey(name, tenantID string, scopes []Scope) (*APIKey, string, error) {
+	id := make([]byte, apiKeyIDBytes)
+	if _, err := rand.Read(id); err != nil {
+		return nil, "", fmt.Errorf("error generating api key id: %w", err)
+	}
+
+	secret := make([]byte, apiKeySecretLen)
+	if _, err := rand.Read(secret); err != nil {
+		return nil, "", fmt.Errorf("error generating api key secret: %w", err)
+	}
+
+	key := &APIKey{
+		ID:        hex.EncodeToString(id),
+		Name:      name,
+		TenantID:  tenantID,
+		Scopes:    scopes,
+		CreatedAt: time.Now().UTC(),
+	}
+
+	encodedSecret := base64.RawURLEncoding.EncodeToString(secret)
+	key.Hash = hashSecret(encodedSecret)
+
+	return key, apiKeyPrefix + key.ID + "." + encodedSecret, nil
+}
+
+func parseAPIKey(token string) (string, string, error) {
+	rest, ok := strings.CutPrefix(token, apiKeyPrefix)
+	if !ok {
+		return "", "", fmt.Errorf("%w: malformed api key", ErrInvalidCredentials)
+	}
+
+	id, secret, ok := strings.Cut(rest, ".")
+	if !ok || id == "" || secret == "" {
+		return "", "", fmt.Errorf("%w: malformed api key", ErrInvalidCredentials)
+	}
+
+	return id, secret, nil
+}
+
+// secrets carry 256 bits of entropy so a fast hash is enough.
+func hashSecret(secret string) []byte {
+	sum := sha256.Sum256([]byte(secret))
+
+	return sum[:]
+}
+
+// APIKeyAuthenticator resolves keys sent in the X-Api-Key header
+// or as an Authorization bearer token starting with the key prefix.
+type APIKeyAuthenticator struct {
+	Store APIKeyStore
+}
+
+func (a APIKeyAuthenticator) Authenticate(r *http.Request) (*Principal, error) {
+	token := r.Header.Get(APIKeyHeader)
+	if token == "" {
+		bearer, ok := bearerToken(r)
+		if !ok || !strings.HasPrefix(bearer, apiKeyPrefix) {
+			return nil, ErrNoCredentials
+		}
+
+		token = bearer
+	}
+
+	id, secret, err := parseAPIKey(token)
+	if err != nil {
+		return nil, err
+	}
+
+	key, err := a.Store.GetAPIKey(r.Context(), id)
+	if err != nil {
+		if errors.Is(err, ErrUnknownAPIKey) {
+			return nil, fmt.Errorf("%w: %w", ErrInvalidCredentials, err)
+		}
+
+		return nil, fmt.Errorf("error getting api key: %w", err)
+	}
+
+	if subtle.ConstantTimeCompare(key.Hash, hashSecret(secret)) != 1 {
+		return nil, fmt.Errorf("%w: api key secret mismatch", ErrInvalidCredentials)
+	}
+
+	if key.RevokedAt != nil {
+		return nil, fmt.Errorf("%w: api key revoked", ErrInvalidCredentials)
+	}
+
+	return &Principal{
+		ID:     key.ID,
+		Method: MethodAPIKey,
+		Scopes: key.Scopes,
+		Tenant: key.TenantID,
+	}, nil
+}
+
+func bearerToken(r *http.Request) (string, bool) {
+	scheme, token, ok := strings.Cut(r.Header.Get("Authorization"), " ")
+	if !ok || !strings.EqualFold(scheme, "Bearer") {
+		return "", false
+	}
+
+	token = strings.TrimSpace(token)
+
+	return token, token != ""
+}
@@ -0,0 +1,156 @@
+package auth_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/jacekdobrowolski/goshort/internal/auth"
+)
+
+type mockKeyStore map[string]*auth.APIKey
+
+func (m mockKeyStore) GetAPIKey(_ context.Context, id string) (*auth.APIKey, error) {
+	key, ok := m[id]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", auth.ErrUnknownAPIKey, id)
+	}
+
+	return key, nil
+}
+
+func Test_APIKeyMiddleware(t *testing.T) {
+	t.Parallel()
+
+	store := mockKeyStore{}
+
+	createKey, createToken, err := auth.NewAPIKey("creator", "", []auth.Scope{auth.ScopeCreate})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	store[createKey.ID] = createKey
+
+	revokedKey, revokedToken, err := auth.NewAPIKey("revoked", "", []auth.Scope{auth.ScopeCreate})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	revokedAt := time.Now()
+	revokedKey.RevokedAt = &revokedAt
+	store[revokedKey.ID] = revokedKey
+
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+
+	handler := auth.Middleware(
+		auth.Require(auth.ScopeCreate, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			principal, _ := auth.PrincipalFromContext(r.Context())
+			if principal.ID != createKey.ID {
+				t.Errorf("unexpected principal %v", principal)
+			}
+
+			w.WriteHeader(http.StatusCreated)
+		})),
+		logger,
+		auth.APIKeyAuthenticator{Store: store},
+	)
+
+	tests := []struct {
+		name   string
+		header string
+		value  string
+		status int
+	}{
+		{name: "no credentials", status: http.StatusUnauthorized},
+		{name: "valid key header", header: auth.APIKeyHeader, value: createToken, status: http.StatusCreated},
+		{name: "valid bearer", header: "Authorization", value: "Bearer " + createToken, status: http.StatusCreated},
+		{name: "wrong secret", header: auth.APIKeyHeader, value: createToken + "x", status: http.StatusUnauthorized},
+		{name: "unknown key", header: auth.APIKeyHeader, value: "gsk_000000000000.abc", status: http.StatusUnauthorized},
+		{name: "malformed key", header: auth.APIKeyHeader, value: "nope", status: http.StatusUnauthorized},
+		{name: "revoked key", header: auth.APIKeyHeader, value: revokedToken, status: http.StatusUnauthorized},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			r := httptest.NewRequest(http.MethodPost, "/api/v1/links", nil)
+			if tt.header != "" {
+				r.Header.Set(tt.header, tt.value)
+			}
+
+			w := httptest.NewRecorder()
+			handler.ServeHTTP(w, r)
+
+			if w.Code != tt.status {
+				t.Errorf("expected StatusCode %d got %d", tt.status, w.Code)
+			}
+		})
+	}
+}
+
+type failingKeyStore struct{}
+
+func (failingKeyStore) GetAPIKey(context.Context, string) (*auth.APIKey, error) {
+	return nil, errStoreDown
+}
+
+var errStoreDown = errors.New("connection refused")
+
+func Test_APIKeyMiddlewareRejection(t *testing.T) {
+	t.Parallel()
+
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	public := http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusTemporaryRedirect)
+	})
+	protected := auth.Require(auth.ScopeRead, public)
+
+	tests := []struct {
+		name    string
+		store   auth.APIKeyStore
+		handler http.Handler
+		status  int
+	}{
+		{name: "stale key on public route", store: mockKeyStore{}, handler: public, status: http.StatusTemporaryRedirect},
+		{name: "store down on public route", store: failingKeyStore{}, handler: public, status: http.StatusTemporaryRedirect},
+		{name: "stale key", store: mockKeyStore{}, handler: protected, status: http.StatusUnauthorized},
+		{name: "store down", store: failingKeyStore{}, handler: protected, status: http.StatusServiceUnavailable},
+	}
+
+	for _, tt := range tests {
+		r := httptest.NewRequest(http.MethodGet, "/abc", nil)
+		r.Header.Set(auth.APIKeyHeader, "gsk_000000000000.abc")
+
+		w := httptest.NewRecorder()
+		auth.Middleware(tt.handler, logger, auth.APIKeyAuthenticator{Store: tt.store}).ServeHTTP(w, r)
+
+		if w.Code != tt.status {
+			t.Errorf("%s expected StatusCode %d got %d", tt.name, tt.status, w.Code)
+		}
+	}
+}
+
+func Test_RequireScope(t *testing.T) {
+	t.Parallel()
+
+	handler := auth.Require(auth.ScopeDelete, http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	}))
+
+	r := httptest.NewRequest(http.MethodDelete, "/api/v1/links/abc", nil)
+	r = r.WithContext(auth.WithPrincipal(r.Context(), &auth.Principal{ID: "k", Scopes: []auth.Scope{auth.ScopeRead}}))
+
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, r)
+
+	if w.Code != http.StatusForbidden {
+		t.Errorf("expected StatusCode %d got %d", http.StatusForbidden, w.Code)
+	}
+}
@@ -0,0 +1,75 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+)
+
+type Scope string
+
+const (
+	ScopeCreate Scope = "create"
+	ScopeRead   Scope = "read"
+	ScopeDelete Scope = "delete"
+	ScopeStats  Scope = "stats"
+)
+
+var (
+	ErrNoCredentials      = errors.New("no credentials")
+	ErrInvalidCredentials = errors.New("invalid credentials")
+	errUnknownScope       = errors.New("unknown scope")
+)
+
+func ParseScopes(list string) ([]Scope, error) {
+	var scopes []Scope
+
+	for _, name := range strings.Split(list, ",") {
+		name = strings.TrimSpace(name)
+		if name == "" {
+			continue
+		}
+
+		scope := Scope(name)
+
+		switch scope {
+		case ScopeCreate, ScopeRead, ScopeDelete, ScopeStats:
+		default:
+			return nil, fmt.Errorf("%w: %s", errUnknownScope, name)
+		}
+
+		if !slices.Contains(scopes, scope) {
+			scopes = append(scopes, scope)
+		}
+	}
+
+	return scopes, nil
+}
+
+// Principal is the authenticated caller of the management API.
+type Principal struct {
+	// ID identifies the caller, API key ID or token subject.
+	ID     string
+	Method string
+	Scopes []Scope
+	// Tenant owns every link the principal creates, empty for the default tenant.
+	Tenant string
+}
+
+func (p *Principal) HasScope(scope Scope) bool {
+	return p != nil && slices.Contains(p.Scopes, scope)
+}
+
+type principalKey struct{}
+
+func WithPrincipal(ctx context.Context, principal *Principal) context.Context {
+	return context.WithValue(ctx, principalKey{}, principal)
+}
+
+func PrincipalFromContext(ctx context.Context) (*Principal, bool) {
+	principal, ok := ctx.Value(principalKey{}).(*Principal)
+
+	return principal, ok && principal != nil
+}
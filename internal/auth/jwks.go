@@ -0,0 +1,309 @@
+package auth
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	jwksFetchTimeout = 5 * time.Second
+	// URL sources are refetched at most this often
+	jwksMinRefresh = 30 * time.Second
+	jwksMaxSize    = 1 << 20
+)
+
+var (
+	errUnknownKeyID      = errors.New("unknown key id")
+	errUnsupportedJWK    = errors.New("unsupported jwk")
+	errJWKSStatus        = errors.New("unexpected jwks response status")
+	errJWKSNoUsableKeys  = errors.New("jwks contains no usable keys")
+	errAmbiguousKeyID    = errors.New("token has no kid and jwks holds more than one key")
+	errUnsupportedCurve  = errors.New("unsupported curve")
+	errInvalidKeyEncoded = errors.New("invalid key encoding")
+)
+
+type jwk struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+	Crv string `json:"crv"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+}
+
+// JWKS holds signing keys loaded from a file path or an http(s) URL.
+// URL sources are refetched when a token references an unknown key ID
+// and after RefreshInterval, at most once per jwksMinRefresh whether the
+// fetch succeeds or not.
+type JWKS struct {
+	source          string
+	client          *http.Client
+	refreshInterval time.Duration
+
+	mu      sync.RWMutex
+	keys    map[string]crypto.PublicKey
+	fetched time.Time
+	// attempted is the start of the last fetch, failed ones included.
+	attempted time.Time
+	// refreshing is closed when the fetch in flight finishes, nil when idle.
+	refreshing chan struct{}
+}
+
+func NewJWKS(ctx context.Context, source string, client *http.Client, refreshInterval time.Duration) (*JWKS, error) {
+	if client == nil {
+		client = &http.Client{Timeout: jwksFetchTimeout}
+	}
+
+	jwks := &JWKS{
+		source:          source,
+		client:          client,
+		refreshInterval: refreshInterval,
+	}
+
+	if err := jwks.load(ctx); err != nil {
+		return nil, err
+	}
+
+	return jwks, nil
+}
+
+func (j *JWKS) remote() bool {
+	return strings.HasPrefix(j.source, "http://") || strings.HasPrefix(j.source, "https://")
+}
+
+func (j *JWKS) Key(ctx context.Context, kid string) (crypto.PublicKey, error) {
+	j.mu.RLock()
+	key, ok := j.lookup(kid)
+	stale := j.remote() && j.refreshInterval > 0 && time.Since(j.fetched) > j.refreshInterval
+	j.mu.RUnlock()
+
+	if ok && !stale {
+		return key, nil
+	}
+
+	if j.remote() {
+		if err := j.refresh(ctx, !ok); err != nil {
+			if ok {
+				// keep serving the cached key when the identity provider is down
+				return key, nil
+			}
+
+			return nil, err
+		}
+	}
+
+	j.mu.RLock()
+	defer j.mu.RUnlock()
+
+	key, ok = j.lookup(kid)
+	if !ok {
+		if kid == "" {
+			return nil, errAmbiguousKeyID
+		}
+
+		return nil, fmt.Errorf("%w: %s", errUnknownKeyID, kid)
+	}
+
+	return key, nil
+}
+
+// refresh fetches the key set unless it was attempted within jwksMinRefresh.
+// Concurrent callers share the fetch in flight, waiting for it only when wait
+// is set, callers holding a cached key go on with it.
+func (j *JWKS) refresh(ctx context.Context, wait bool) error {
+	j.mu.Lock()
+
+	if done := j.refreshing; done != nil {
+		j.mu.Unlock()
+
+		if !wait {
+			return nil
+		}
+
+		select {
+		case <-done:
+			return nil
+		case <-ctx.Done():
+			return fmt.Errorf("error waiting for jwks refresh: %w", ctx.Err())
+		}
+	}
+
+	if time.Since(j.attempted) < jwksMinRefresh {
+		j.mu.Unlock()
+
+		return nil
+	}
+
+	done := make(chan struct{})
+	j.refreshing = done
+	j.attempted = time.Now()
+	j.mu.Unlock()
+
+	err := j.load(ctx)
+
+	j.mu.Lock()
+	j.refreshing = nil
+	j.mu.Unlock()
+	close(done)
+
+	return err
+}
+
+func (j *JWKS) lookup(kid string) (crypto.PublicKey, bool) {
+	if kid == "" && len(j.keys) == 1 {
+		for _, key := range j.keys {
+			return key, true
+		}
+	}
+
+	key, ok := j.keys[kid]
+
+	return key, ok
+}
+
+func (j *JWKS) load(ctx context.Context) error {
+	raw, err := j.read(ctx)
+	if err != nil {
+		return err
+	}
+
+	var set struct {
+		Keys []jwk `json:"keys"`
+	}
+
+	if err := json.Unmarshal(raw, &set); err != nil {
+		return fmt.Errorf("error decoding jwks: %w", err)
+	}
+
+	keys := make(map[string]crypto.PublicKey, len(set.Keys))
+
+	for _, key := range set.Keys {
+		if key.Use != "" && key.Use != "sig" {
+			continue
+		}
+
+		publicKey, err := key.publicKey()
+		if err != nil {
+			continue
+		}
+
+		keys[key.Kid] = publicKey
+	}
+
+	if len(keys) == 0 {
+		return errJWKSNoUsableKeys
+	}
+
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	j.keys = keys
+	j.fetched = time.Now()
+
+	return nil
+}
+
+func (j *JWKS) read(ctx context.Context) ([]byte, error) {
+	if !j.remote() {
+		raw, err := os.ReadFile(j.source)
+		if err != nil {
+			return nil, fmt.Errorf("error reading jwks file: %w", err)
+		}
+
+		return raw, nil
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, jwksFetchTimeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, j.source, nil)
+	if err != nil {
+		return nil, fmt.Errorf("error creating jwks request: %w", err)
+	}
+
+	resp, err := j.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("error fetching jwks: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("%w: %d", errJWKSStatus, resp.StatusCode)
+	}
+
+	raw, err := io.ReadAll(io.LimitReader(resp.Body, jwksMaxSize))
+	if err != nil {
+		return nil, fmt.Errorf("error reading jwks response: %w", err)
+	}
+
+	return raw, nil
+}
+
+func (k jwk) publicKey() (crypto.PublicKey, error) {
+	switch k.Kty {
+	case "RSA":
+		n, err := decodeBigInt(k.N)
+		if err != nil {
+			return nil, err
+		}
+
+		e, err := decodeBigInt(k.E)
+		if err != nil {
+			return nil, err
+		}
+
+		return &rsa.PublicKey{N: n, E: int(e.Int64())}, nil
+	case "EC":
+		var curve elliptic.Curve
+
+		switch k.Crv {
+		case "P-256":
+			curve = elliptic.P256()
+		case "P-384":
+			curve = elliptic.P384()
+		case "P-521":
+			curve = elliptic.P521()
+		default:
+			return nil, fmt.Errorf("%w: %s", errUnsupportedCurve, k.Crv)
+		}
+
+		x, err := decodeBigInt(k.X)
+		if err != nil {
+			return nil, err
+		}
+
+		y, err := decodeBigInt(k.Y)
+		if err != nil {
+			return nil, err
+		}
+
+		return &ecdsa.PublicKey{Curve: curve, X: x, Y: y}, nil
+	default:
+		return nil, fmt.Errorf("%w: kty %s", errUnsupportedJWK, k.Kty)
+	}
+}
+
+func decodeBigInt(value string) (*big.Int, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(value)
+	if err != nil || len(raw) == 0 {
+		return nil, errInvalidKeyEncoded
+	}
+
+	return new(big.Int).SetBytes(raw), nil
+}
@@ -0,0 +1,171 @@
+package auth
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+const (
+	MethodJWT = "jwt"
+
+	jwtLeeway = 30 * time.Second
+)
+
+var (
+	errMissingSubject        = errors.New("token has no subject")
+	errMalformedScopeMapping = errors.New("malformed scope mapping")
+)
+
+type JWTOptions struct {
+	Issuer   string
+	Audience string
+	// ScopeClaim names the claim holding scopes, either a space separated
+	// string like the OAuth2 "scope" claim or an array like "scp".
+	ScopeClaim string
+	// TenantClaim names the claim holding the tenant ID, "tenant" by default.
+	// Tokens without it belong to the default tenant.
+	TenantClaim string
+	// ScopeMapping translates identity provider scopes or roles into
+	// service scopes. Without it claim values must match Scope names.
+	ScopeMapping map[string][]Scope
+}
+
+// ParseScopeMapping parses "provider:scope=create+read,admin=create+read+delete+stats".
+func ParseScopeMapping(mapping string) (map[string][]Scope, error) {
+	if strings.TrimSpace(mapping) == "" {
+		return nil, nil //nolint: nilnil // no mapping configured
+	}
+
+	result := make(map[string][]Scope)
+
+	for _, entry := range strings.Split(mapping, ",") {
+		claim, scopeList, ok := strings.Cut(strings.TrimSpace(entry), "=")
+		if !ok {
+			return nil, fmt.Errorf("%w: %q", errMalformedScopeMapping, entry)
+		}
+
+		scopes, err := ParseScopes(strings.ReplaceAll(scopeList, "+", ","))
+		if err != nil {
+			return nil, err
+		}
+
+		result[claim] = append(result[claim], scopes...)
+	}
+
+	return result, nil
+}
+
+// JWTAuthenticator validates bearer tokens signed by keys from a JWKS.
+type JWTAuthenticator struct {
+	keys    *JWKS
+	options JWTOptions
+	parser  *jwt.Parser
+}
+
+func NewJWTAuthenticator(keys *JWKS, options JWTOptions) *JWTAuthenticator {
+	if options.ScopeClaim == "" {
+		options.ScopeClaim = "scope"
+	}
+
+	if options.TenantClaim == "" {
+		options.TenantClaim = "tenant"
+	}
+
+	parserOptions := []jwt.ParserOption{
+		jwt.WithValidMethods([]string{"RS256", "RS384", "RS512", "PS256", "PS384", "PS512", "ES256", "ES384", "ES512"}),
+		jwt.WithExpirationRequired(),
+		jwt.WithLeeway(jwtLeeway),
+	}
+
+	if options.Issuer != "" {
+		parserOptions = append(parserOptions, jwt.WithIssuer(options.Issuer))
+	}
+
+	if options.Audience != "" {
+		parserOptions = append(parserOptions, jwt.WithAudience(options.Audience))
+	}
+
+	return &JWTAuthenticator{
+		keys:    keys,
+		options: options,
+		parser:  jwt.NewParser(parserOptions...),
+	}
+}
+
+func (a *JWTAuthenticator) Authenticate(r *http.Request) (*Principal, error) {
+	token, ok := bearerToken(r)
+	if !ok || strings.HasPrefix(token, apiKeyPrefix) {
+		return nil, ErrNoCredentials
+	}
+
+	claims := jwt.MapClaims{}
+
+	var keyErr error
+
+	_, err := a.parser.ParseWithClaims(token, claims, func(t *jwt.Token) (any, error) {
+		kid, _ := t.Header["kid"].(string)
+
+		key, err := a.keys.Key(r.Context(), kid)
+		keyErr = err
+
+		return key, err
+	})
+	if keyErr != nil && !errors.Is(keyErr, errUnknownKeyID) && !errors.Is(keyErr, errAmbiguousKeyID) {
+		// the key set could not be fetched, the token may well be valid
+		return nil, fmt.Errorf("error getting token key: %w", keyErr)
+	}
+
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrInvalidCredentials, err)
+	}
+
+	subject, err := claims.GetSubject()
+	if err != nil || subject == "" {
+		return nil, fmt.Errorf("%w: %w", ErrInvalidCredentials, errMissingSubject)
+	}
+
+	tenant, _ := claims[a.options.TenantClaim].(string)
+
+	return &Principal{
+		ID:     subject,
+		Method: MethodJWT,
+		Scopes: a.scopes(claims),
+		Tenant: tenant,
+	}, nil
+}
+
+func (a *JWTAuthenticator) scopes(claims jwt.MapClaims) []Scope {
+	var values []string
+
+	switch claim := claims[a.options.ScopeClaim].(type) {
+	case string:
+		values = strings.Fields(claim)
+	case []any:
+		for _, value := range claim {
+			if s, ok := value.(string); ok {
+				values = append(values, s)
+			}
+		}
+	}
+
+	var scopes []Scope
+
+	for _, value := range values {
+		if a.options.ScopeMapping != nil {
+			scopes = append(scopes, a.options.ScopeMapping[value]...)
+
+			continue
+		}
+
+		if parsed, err := ParseScopes(value); err == nil {
+			scopes = append(scopes, parsed...)
+		}
+	}
+
+	return scopes
+}
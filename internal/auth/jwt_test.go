@@ -0,0 +1,293 @@
+package auth_test
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"slices"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/jacekdobrowolski/goshort/internal/auth"
+)
+
+func jwksJSON(t *testing.T, kid string, key *rsa.PublicKey) []byte {
+	t.Helper()
+
+	set := map[string]any{
+		"keys": []map[string]string{{
+			"kty": "RSA",
+			"kid": kid,
+			"use": "sig",
+			"n":   base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+			"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+		}},
+	}
+
+	raw, err := json.Marshal(set)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return raw
+}
+
+func signToken(t *testing.T, kid string, key *rsa.PrivateKey, claims jwt.MapClaims) string {
+	t.Helper()
+
+	token := jwt.NewWithClaims(jwt.SigningMethodRS256, claims)
+	token.Header["kid"] = kid
+
+	signed, err := token.SignedString(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return signed
+}
+
+func Test_JWTAuthenticator(t *testing.T) {
+	t.Parallel()
+
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	otherKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	jwksServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write(jwksJSON(t, "k1", &key.PublicKey))
+	}))
+	t.Cleanup(jwksServer.Close)
+
+	jwks, err := auth.NewJWKS(context.Background(), jwksServer.URL, jwksServer.Client(), time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	authenticator := auth.NewJWTAuthenticator(jwks, auth.JWTOptions{
+		Issuer:       "https://idp.test",
+		Audience:     "goshort",
+		ScopeMapping: map[string][]auth.Scope{"links:write": {auth.ScopeCreate, auth.ScopeRead}},
+	})
+
+	validClaims := func() jwt.MapClaims {
+		return jwt.MapClaims{
+			"iss":   "https://idp.test",
+			"aud":   "goshort",
+			"sub":   "user-1",
+			"exp":   time.Now().Add(time.Hour).Unix(),
+			"scope": "openid links:write",
+		}
+	}
+
+	t.Run("valid token", func(t *testing.T) {
+		t.Parallel()
+
+		r := httptest.NewRequest(http.MethodPost, "/api/v1/links", nil)
+		r.Header.Set("Authorization", "Bearer "+signToken(t, "k1", key, validClaims()))
+
+		principal, err := authenticator.Authenticate(r)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if principal.ID != "user-1" || principal.Method != auth.MethodJWT {
+			t.Errorf("unexpected principal %+v", principal)
+		}
+
+		if !slices.Equal(principal.Scopes, []auth.Scope{auth.ScopeCreate, auth.ScopeRead}) {
+			t.Errorf("unexpected scopes %v", principal.Scopes)
+		}
+	})
+
+	invalid := map[string]func() (string, *rsa.PrivateKey, jwt.MapClaims){
+		"expired": func() (string, *rsa.PrivateKey, jwt.MapClaims) {
+			claims := validClaims()
+			claims["exp"] = time.Now().Add(-time.Hour).Unix()
+
+			return "k1", key, claims
+		},
+		"wrong audience": func() (string, *rsa.PrivateKey, jwt.MapClaims) {
+			claims := validClaims()
+			claims["aud"] = "other"
+
+			return "k1", key, claims
+		},
+		"wrong issuer": func() (string, *rsa.PrivateKey, jwt.MapClaims) {
+			claims := validClaims()
+			claims["iss"] = "https://evil.test"
+
+			return "k1", key, claims
+		},
+		"bad signature": func() (string, *rsa.PrivateKey, jwt.MapClaims) {
+			return "k1", otherKey, validClaims()
+		},
+		"unknown kid": func() (string, *rsa.PrivateKey, jwt.MapClaims) {
+			return "k2", key, validClaims()
+		},
+	}
+
+	for name, build := range invalid {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			kid, signingKey, claims := build()
+
+			r := httptest.NewRequest(http.MethodPost, "/api/v1/links", nil)
+			r.Header.Set("Authorization", "Bearer "+signToken(t, kid, signingKey, claims))
+
+			if _, err := authenticator.Authenticate(r); err == nil {
+				t.Error("expected token to be rejected")
+			}
+		})
+	}
+}
+
+func Test_JWKSFile(t *testing.T) {
+	t.Parallel()
+
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	path := filepath.Join(t.TempDir(), "jwks.json")
+	if err := os.WriteFile(path, jwksJSON(t, "file", &key.PublicKey), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	jwks, err := auth.NewJWKS(context.Background(), path, nil, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	authenticator := auth.NewJWTAuthenticator(jwks, auth.JWTOptions{})
+
+	r := httptest.NewRequest(http.MethodGet, "/api/v1/links/abc", nil)
+	r.Header.Set("Authorization", "Bearer "+signToken(t, "file", key, jwt.MapClaims{
+		"sub":   "svc",
+		"exp":   time.Now().Add(time.Minute).Unix(),
+		"scope": "read stats unknown",
+	}))
+
+	principal, err := authenticator.Authenticate(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !slices.Equal(principal.Scopes, []auth.Scope{auth.ScopeRead, auth.ScopeStats}) {
+		t.Errorf("unexpected scopes %v", principal.Scopes)
+	}
+}
+
+func Test_JWKSRefreshFailure(t *testing.T) {
+	t.Parallel()
+
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var fetches atomic.Int32
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		if fetches.Add(1) == 1 {
+			_, _ = w.Write(jwksJSON(t, "idp", &key.PublicKey))
+
+			return
+		}
+
+		// the identity provider is down after the first fetch
+		time.Sleep(50 * time.Millisecond)
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer server.Close()
+
+	jwks, err := auth.NewJWKS(context.Background(), server.URL, nil, time.Nanosecond)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var wg sync.WaitGroup
+
+	for range 20 {
+		wg.Add(1)
+
+		go func() {
+			defer wg.Done()
+
+			if _, err := jwks.Key(context.Background(), "idp"); err != nil {
+				t.Errorf("expected cached key got %v", err)
+			}
+		}()
+	}
+
+	wg.Wait()
+
+	if _, err := jwks.Key(context.Background(), "other"); err == nil {
+		t.Error("expected unknown key id to be rejected")
+	}
+
+	// one refresh for all stale lookups, failures back off like successes
+	if got := fetches.Load(); got != 2 {
+		t.Errorf("expected 2 jwks fetches got %d", got)
+	}
+}
+
+func Test_JWTAuthenticatorJWKSOutage(t *testing.T) {
+	t.Parallel()
+
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var fetches atomic.Int32
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		if fetches.Add(1) == 1 {
+			_, _ = w.Write(jwksJSON(t, "k1", &key.PublicKey))
+
+			return
+		}
+
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer server.Close()
+
+	jwks, err := auth.NewJWKS(context.Background(), server.URL, nil, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	authenticator := auth.NewJWTAuthenticator(jwks, auth.JWTOptions{})
+
+	// a rotated key cannot be fetched, the token is not known to be invalid
+	r := httptest.NewRequest(http.MethodPost, "/api/v1/links", nil)
+	r.Header.Set("Authorization", "Bearer "+signToken(t, "k2", key, jwt.MapClaims{
+		"sub": "user-1",
+		"exp": time.Now().Add(time.Hour).Unix(),
+	}))
+
+	_, err = authenticator.Authenticate(r)
+	if err == nil || errors.Is(err, auth.ErrInvalidCredentials) {
+		t.Errorf("expected jwks fetch error got %v", err)
+	}
+}
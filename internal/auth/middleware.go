@@ -0,0 +1,96 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net/http"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+type Authenticator interface {
+	// Authenticate returns ErrNoCredentials when the request carries
+	// nothing this authenticator understands.
+	Authenticate(r *http.Request) (*Principal, error)
+}
+
+// Middleware resolves the caller into a Principal stored in the request
+// context. Requests without or with rejected credentials pass through
+// unauthenticated so public routes ignore stale keys, use Require on routes
+// that need a caller.
+func Middleware(handler http.Handler, logger *slog.Logger, authenticators ...Authenticator) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		span := trace.SpanFromContext(ctx)
+
+		for _, authenticator := range authenticators {
+			principal, err := authenticator.Authenticate(r)
+			if errors.Is(err, ErrNoCredentials) {
+				continue
+			}
+
+			if err != nil {
+				logger.InfoContext(ctx, "authentication failed", slog.String("err", err.Error()))
+				span.RecordError(err)
+				span.SetStatus(codes.Error, "authentication failed")
+
+				r = r.WithContext(context.WithValue(ctx, authErrorKey{}, err))
+
+				break
+			}
+
+			span.SetAttributes(
+				attribute.String("enduser.id", principal.ID),
+				attribute.String("enduser.auth_method", principal.Method),
+			)
+
+			r = r.WithContext(WithPrincipal(ctx, principal))
+
+			break
+		}
+
+		handler.ServeHTTP(w, r)
+	})
+}
+
+// authErrorKey holds the error of credentials Middleware could not verify.
+type authErrorKey struct{}
+
+// Require responds 401 to anonymous callers and callers with invalid
+// credentials, 503 when credentials could not be verified and 403 to callers
+// without scope.
+func Require(scope Scope, handler http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		principal, ok := PrincipalFromContext(r.Context())
+		if !ok {
+			err, _ := r.Context().Value(authErrorKey{}).(error)
+			if err != nil && !errors.Is(err, ErrInvalidCredentials) {
+				// a storage or identity provider outage says nothing about the credentials
+				w.WriteHeader(http.StatusServiceUnavailable)
+
+				return
+			}
+
+			unauthorized(w)
+
+			return
+		}
+
+		if !principal.HasScope(scope) {
+			trace.SpanFromContext(r.Context()).SetStatus(codes.Error, "missing scope "+string(scope))
+			w.WriteHeader(http.StatusForbidden)
+
+			return
+		}
+
+		handler.ServeHTTP(w, r)
+	})
+}
+
+func unauthorized(w http.ResponseWriter) {
+	w.Header().Set("WWW-Authenticate", `Bearer realm="goshort"`)
+	w.WriteHeader(http.StatusUnauthorized)
+}
@@ -0,0 +1,308 @@
+package links
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/jacekdobrowolski/goshort/internal/auth"
+)
+
+var errUsage = errors.New(`usage:
+  links apikey create -name NAME [-tenant ID] -scopes create,read,delete,stats | revoke -id ID | list
+  links tenant create -id ID -name NAME [-domain HOST] [-max-links N] | list
+      -domain also adds HOST to domains like domain add -host HOST -tenant ID
+  links domain add -host HOST [-tenant ID] [-base-url URL] | list`)
+
+// AdminStore is the storage used by admin commands.
+type AdminStore interface {
+	CreateAPIKey(ctx context.Context, key *auth.APIKey) error
+	RevokeAPIKey(ctx context.Context, id string) error
+	ListAPIKeys(ctx context.Context) ([]*auth.APIKey, error)
+	// CreateTenant creates the tenant and its domains at once.
+	CreateTenant(ctx context.Context, tenant Tenant, domains ...Domain) error
+	ListTenants(ctx context.Context) ([]Tenant, error)
+	CreateDomain(ctx context.Context, domain Domain) error
+	ListDomains(ctx context.Context) ([]Domain, error)
+}
+
+// IsAdminCommand reports whether args, without the program name, name an
+// admin command group handled by RunAdmin.
+func IsAdminCommand(args []string) bool {
+	if len(args) == 0 {
+		return false
+	}
+
+	switch args[0] {
+	case "apikey", "tenant", "domain":
+		return true
+	default:
+		return false
+	}
+}
+
+// RunAdmin manages API keys, tenants and domains from the command line, for example
+// kubectl exec deploy/links-deployment -- ./links apikey create -name ci -scopes create,read.
+func RunAdmin(ctx context.Context, args []string, w io.Writer, env func(string) string) error {
+	if len(args) < 2 {
+		return errUsage
+	}
+
+	logger := slog.New(slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{Level: slog.LevelWarn}))
+
+	pgStore, err := NewPostgresStore(ctx, postgresConnectionString(env, logger), logger)
+	if err != nil {
+		return err
+	}
+
+	return Admin(ctx, pgStore, args, w)
+}
+
+// Admin runs the admin command named by args against store.
+func Admin(ctx context.Context, store AdminStore, args []string, w io.Writer) error {
+	if len(args) < 2 {
+		return errUsage
+	}
+
+	switch args[0] + " " + args[1] {
+	case "apikey create":
+		return createAPIKey(ctx, store, args[2:], w)
+	case "apikey revoke":
+		return revokeAPIKey(ctx, store, args[2:], w)
+	case "apikey list":
+		return listAPIKeys(ctx, store, w)
+	case "tenant create":
+		return createTenant(ctx, store, args[2:], w)
+	case "tenant list":
+		return listTenants(ctx, store, w)
+	case "domain add":
+		return addDomain(ctx, store, args[2:], w)
+	case "domain list":
+		return listDomains(ctx, store, w)
+	default:
+		return errUsage
+	}
+}
+
+func createAPIKey(ctx context.Context, store AdminStore, args []string, w io.Writer) error {
+	flags := flag.NewFlagSet("create", flag.ContinueOnError)
+	name := flags.String("name", "", "human readable key owner")
+	tenant := flags.String("tenant", "", "tenant owning links created with the key")
+	scopeList := flags.String("scopes", string(auth.ScopeRead), "comma separated scopes")
+
+	if err := flags.Parse(args); err != nil {
+		return fmt.Errorf("error parsing flags: %w", err)
+	}
+
+	if *name == "" {
+		return errUsage
+	}
+
+	scopes, err := auth.ParseScopes(*scopeList)
+	if err != nil {
+		return fmt.Errorf("error parsing scopes: %w", err)
+	}
+
+	key, token, err := auth.NewAPIKey(*name, *tenant, scopes)
+	if err != nil {
+		return fmt.Errorf("error creating api key: %w", err)
+	}
+
+	if err := store.CreateAPIKey(ctx, key); err != nil {
+		return err
+	}
+
+	fmt.Fprintf(w, "id: %s\nkey: %s\n", key.ID, token)
+
+	return nil
+}
+
+func revokeAPIKey(ctx context.Context, store AdminStore, args []string, w io.Writer) error {
+	flags := flag.NewFlagSet("revoke", flag.ContinueOnError)
+	id := flags.String("id", "", "id of the key to revoke")
+
+	if err := flags.Parse(args); err != nil {
+		return fmt.Errorf("error parsing flags: %w", err)
+	}
+
+	if *id == "" {
+		return errUsage
+	}
+
+	if err := store.RevokeAPIKey(ctx, *id); err != nil {
+		return err
+	}
+
+	fmt.Fprintf(w, "revoked: %s\n", *id)
+
+	return nil
+}
+
+func listAPIKeys(ctx context.Context, store AdminStore, w io.Writer) error {
+	keys, err := store.ListAPIKeys(ctx)
+	if err != nil {
+		return err
+	}
+
+	table := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(table, "ID\tNAME\tTENANT\tSCOPES\tCREATED\tREVOKED")
+
+	for _, key := range keys {
+		scopes := make([]string, 0, len(key.Scopes))
+		for _, scope := range key.Scopes {
+			scopes = append(scopes, string(scope))
+		}
+
+		revoked := "-"
+		if key.RevokedAt != nil {
+			revoked = key.RevokedAt.Format(time.RFC3339)
+		}
+
+		fmt.Fprintf(table, "%s\t%s\t%s\t%s\t%s\t%s\n",
+			key.ID, key.Name, dashIfEmpty(key.TenantID), strings.Join(scopes, ","),
+			key.CreatedAt.Format(time.RFC3339), revoked)
+	}
+
+	if err := table.Flush(); err != nil {
+		return fmt.Errorf("error writing table: %w", err)
+	}
+
+	return nil
+}
+
+func createTenant(ctx context.Context, store AdminStore, args []string, w io.Writer) error {
+	flags := flag.NewFlagSet("create", flag.ContinueOnError)
+	id := flags.String("id", "", "tenant id")
+	name := flags.String("name", "", "human readable tenant name")
+	host := flags.String("domain", "", "registers a domain of the tenant serving redirects with https base URL")
+	maxLinks := flags.Int("max-links", 0, "link quota, 0 uses the service default")
+
+	if err := flags.Parse(args); err != nil {
+		return fmt.Errorf("error parsing flags: %w", err)
+	}
+
+	if *id == "" || *name == "" {
+		return errUsage
+	}
+
+	tenant := Tenant{
+		ID:       *id,
+		Name:     *name,
+		MaxLinks: sql.NullInt64{Int64: int64(*maxLinks), Valid: *maxLinks > 0},
+	}
+
+	var domains []Domain
+
+	if *host != "" {
+		domain, err := NewDomain(*host, tenant.ID, "")
+		if err != nil {
+			return err
+		}
+
+		domains = append(domains, domain)
+	}
+
+	if err := store.CreateTenant(ctx, tenant, domains...); err != nil {
+		return err
+	}
+
+	fmt.Fprintf(w, "tenant: %s\n", tenant.ID)
+
+	for _, domain := range domains {
+		fmt.Fprintf(w, "domain: %s\n", domain.Host)
+	}
+
+	return nil
+}
+
+func listTenants(ctx context.Context, store AdminStore, w io.Writer) error {
+	tenants, err := store.ListTenants(ctx)
+	if err != nil {
+		return err
+	}
+
+	table := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(table, "ID\tNAME\tMAX LINKS\tCREATED")
+
+	for _, tenant := range tenants {
+		maxLinks := "-"
+		if tenant.MaxLinks.Valid {
+			maxLinks = strconv.FormatInt(tenant.MaxLinks.Int64, 10)
+		}
+
+		fmt.Fprintf(table, "%s\t%s\t%s\t%s\n",
+			tenant.ID, tenant.Name, maxLinks, tenant.CreatedAt.Format(time.RFC3339))
+	}
+
+	if err := table.Flush(); err != nil {
+		return fmt.Errorf("error writing table: %w", err)
+	}
+
+	return nil
+}
+
+func addDomain(ctx context.Context, store AdminStore, args []string, w io.Writer) error {
+	flags := flag.NewFlagSet("add", flag.ContinueOnError)
+	host := flags.String("host", "", "host serving short links")
+	tenant := flags.String("tenant", "", "tenant owning the domain, empty for the default tenant")
+	baseURL := flags.String("base-url", "", "scheme and host of short URLs, https://HOST by default")
+
+	if err := flags.Parse(args); err != nil {
+		return fmt.Errorf("error parsing flags: %w", err)
+	}
+
+	if *host == "" {
+		return errUsage
+	}
+
+	domain, err := NewDomain(*host, *tenant, *baseURL)
+	if err != nil {
+		return err
+	}
+
+	if err := store.CreateDomain(ctx, domain); err != nil {
+		return err
+	}
+
+	fmt.Fprintf(w, "domain: %s\nbase url: %s\n", domain.Host, domain.BaseURL)
+
+	return nil
+}
+
+func listDomains(ctx context.Context, store AdminStore, w io.Writer) error {
+	domains, err := store.ListDomains(ctx)
+	if err != nil {
+		return err
+	}
+
+	table := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(table, "HOST\tTENANT\tBASE URL\tCREATED")
+
+	for _, domain := range domains {
+		fmt.Fprintf(table, "%s\t%s\t%s\t%s\n",
+			domain.Host, dashIfEmpty(domain.Tenant), domain.BaseURL, domain.CreatedAt.Format(time.RFC3339))
+	}
+
+	if err := table.Flush(); err != nil {
+		return fmt.Errorf("error writing table: %w", err)
+	}
+
+	return nil
+}
+
+func dashIfEmpty(s string) string {
+	if s == "" {
+		return "-"
+	}
+
+	return s
+}
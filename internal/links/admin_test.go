@@ -0,0 +1,110 @@
+package links_test
+
+import (
+	"bytes"
+	"context"
+	"testing"
+
+	"github.com/jacekdobrowolski/goshort/internal/links"
+)
+
+// adminStore records tenants and domains, the other admin commands are not used.
+type adminStore struct {
+	links.AdminStore
+
+	tenants []links.Tenant
+	domains []links.Domain
+}
+
+func (as *adminStore) CreateTenant(_ context.Context, tenant links.Tenant, domains ...links.Domain) error {
+	as.tenants = append(as.tenants, tenant)
+	as.domains = append(as.domains, domains...)
+
+	return nil
+}
+
+func (as *adminStore) CreateDomain(_ context.Context, domain links.Domain) error {
+	as.domains = append(as.domains, domain)
+
+	return nil
+}
+
+func Test_isAdminCommand(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		args []string
+		want bool
+	}{
+		{args: []string{"apikey", "create"}, want: true},
+		{args: []string{"tenant", "list"}, want: true},
+		{args: []string{"domain", "add", "-host", "acme.link"}, want: true},
+		{args: []string{"domain"}, want: true},
+		{args: []string{"-port", "3000"}, want: false},
+		{args: nil, want: false},
+	}
+
+	for _, tt := range tests {
+		if got := links.IsAdminCommand(tt.args); got != tt.want {
+			t.Errorf("%v expected %t got %t", tt.args, tt.want, got)
+		}
+	}
+}
+
+func Test_adminDomainAdd(t *testing.T) {
+	t.Parallel()
+
+	args := []string{"domain", "add", "-host", "ACME.link", "-tenant", "acme"}
+	if !links.IsAdminCommand(args) {
+		t.Fatalf("expected %v to be an admin command", args)
+	}
+
+	store := &adminStore{}
+	out := &bytes.Buffer{}
+
+	if err := links.Admin(context.Background(), store, args, out); err != nil {
+		t.Fatal(err)
+	}
+
+	want := links.Domain{Host: "acme.link", Tenant: "acme", BaseURL: "https://acme.link"}
+	if len(store.domains) != 1 || store.domains[0] != want {
+		t.Fatalf("expected domain %+v got %+v", want, store.domains)
+	}
+
+	if out.String() != "domain: acme.link\nbase url: https://acme.link\n" {
+		t.Errorf("unexpected output %q", out.String())
+	}
+
+	if err := links.Admin(context.Background(), store, []string{"domain", "add"}, out); err == nil {
+		t.Errorf("expected usage error without -host")
+	}
+}
+
+func Test_adminTenantCreate(t *testing.T) {
+	t.Parallel()
+
+	store := &adminStore{}
+	out := &bytes.Buffer{}
+
+	// an invalid domain must not leave a tenant without it behind
+	err := links.Admin(context.Background(), store,
+		[]string{"tenant", "create", "-id", "acme", "-name", "Acme", "-domain", "acme.link/path"}, out)
+	if err == nil || len(store.tenants) != 0 {
+		t.Fatalf("expected invalid domain to be rejected before creating the tenant got %v %+v", err, store.tenants)
+	}
+
+	err = links.Admin(context.Background(), store,
+		[]string{"tenant", "create", "-id", "acme", "-name", "Acme", "-domain", "go.acme.test"}, out)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := links.Domain{Host: "go.acme.test", Tenant: "acme", BaseURL: "https://go.acme.test"}
+	if len(store.tenants) != 1 || len(store.domains) != 1 || store.domains[0] != want {
+		t.Fatalf("expected tenant acme with domain %+v got %+v %+v", want, store.tenants, store.domains)
+	}
+
+	if out.String() != "tenant: acme\ndomain: go.acme.test\n" {
+		t.Errorf("unexpected output %q", out.String())
+	}
+}
@@ -0,0 +1,75 @@
+package links_test
+
+import (
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/jacekdobrowolski/goshort/internal/links"
+)
+
+type hostBlocklist map[string]string
+
+func (b hostBlocklist) Blocked(u *url.URL) (string, bool) {
+	list, ok := b[u.Hostname()]
+
+	return list, ok
+}
+
+func Test_redirectBlocklisted(t *testing.T) {
+	t.Parallel()
+
+	store := newMockStore()
+	logger := slog.New(slog.NewTextHandler(io.Discard, &slog.HandlerOptions{Level: slog.LevelError}))
+	redirect := links.HandlerRedirect(logger, store, links.RedirectOptions{
+		Blocklist: hostBlocklist{"evil.test": "phishing.txt"},
+	})
+	create := links.HandlerCreateLink(logger, store, nil)
+
+	for short, target := range map[string]string{"bad": "http://evil.test/login", "good": "http://example.com/"} {
+		if status := createAlias(t, create, "", short, target); status != http.StatusCreated {
+			t.Fatalf("expected StatusCode %d got %d", http.StatusCreated, status)
+		}
+	}
+
+	r := httptest.NewRequest(http.MethodGet, "/bad", nil)
+	r.SetPathValue("short", "bad")
+
+	w := httptest.NewRecorder()
+	redirect(w, r)
+
+	if w.Code != http.StatusForbidden {
+		t.Fatalf("expected StatusCode %d got %d", http.StatusForbidden, w.Code)
+	}
+
+	if location := w.Header().Get("Location"); location != "" {
+		t.Errorf("expected no redirect got %q", location)
+	}
+
+	if body := w.Body.String(); !strings.Contains(body, "phishing.txt") {
+		t.Errorf("expected interstitial naming the list got %s", body)
+	}
+
+	stored, err := store.GetLink(r.Context(), "", "", "bad")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if stored.DisabledReason != "phishing.txt" || stored.Clicks != 0 {
+		t.Errorf("expected link disabled without clicks got %+v", stored)
+	}
+
+	r = httptest.NewRequest(http.MethodGet, "/good", nil)
+	r.SetPathValue("short", "good")
+
+	w = httptest.NewRecorder()
+	redirect(w, r)
+
+	if w.Code != http.StatusTemporaryRedirect {
+		t.Errorf("expected StatusCode %d got %d", http.StatusTemporaryRedirect, w.Code)
+	}
+}
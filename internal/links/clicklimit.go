@@ -0,0 +1,50 @@
+package links
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/jacekdobrowolski/goshort/pkg/urlpolicy"
+)
+
+var (
+	errMaxClicks         = errors.New("maxClicks must not be negative")
+	errExhaustedFallback = errors.New("exhaustedFallback requires maxClicks")
+)
+
+func validateClickLimit(ctx context.Context, policy *urlpolicy.Policy, maxClicks int, fallback string) error {
+	if maxClicks < 0 {
+		return errMaxClicks
+	}
+
+	if fallback == "" {
+		return nil
+	}
+
+	if maxClicks == 0 {
+		return errExhaustedFallback
+	}
+
+	if err := checkDestination(ctx, policy, fallback); err != nil {
+		return fmt.Errorf("exhaustedFallback: %w", err)
+	}
+
+	return nil
+}
+
+// exhausted answers visits of a link without remaining clicks with its
+// fallback or 410 Gone.
+func exhausted(w http.ResponseWriter, r *http.Request, h *redirectHandler, link *StoredLink) {
+	h.logger.InfoContext(r.Context(), "link clicks exhausted", "short", link.Short)
+
+	if link.ExhaustedFallback == "" {
+		w.WriteHeader(http.StatusGone)
+
+		return
+	}
+
+	w.Header().Set("Cache-Control", "private, no-cache")
+	http.Redirect(w, r, link.ExhaustedFallback, http.StatusTemporaryRedirect)
+}
@@ -0,0 +1,136 @@
+package links_test
+
+import (
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/jacekdobrowolski/goshort/internal/links"
+)
+
+func Test_redirectClickLimit(t *testing.T) {
+	t.Parallel()
+
+	store := newMockStore()
+	logger := slog.New(slog.NewTextHandler(io.Discard, &slog.HandlerOptions{Level: slog.LevelError}))
+	create := links.HandlerCreateLink(logger, store, nil)
+	redirect := links.HandlerRedirect(logger, store, links.RedirectOptions{})
+
+	createTests := []struct {
+		body   string
+		status int
+	}{
+		{body: `{"url":"http://example.com/invite","alias":"invite","maxClicks":10}`, status: http.StatusCreated},
+		{
+			body: `{"url":"http://example.com/once","alias":"once","maxClicks":1,` +
+				`"exhaustedFallback":"http://example.com/used"}`,
+			status: http.StatusCreated,
+		},
+		{body: `{"url":"http://example.com/","maxClicks":-1}`, status: http.StatusBadRequest},
+		{body: `{"url":"http://example.com/","exhaustedFallback":"http://example.com/used"}`, status: http.StatusBadRequest},
+		{body: `{"url":"http://example.com/","maxClicks":1,"exhaustedFallback":"not a url"}`, status: http.StatusBadRequest},
+	}
+
+	for _, tt := range createTests {
+		r := httptest.NewRequest(http.MethodPost, "/api/v1/links", strings.NewReader(tt.body))
+		r.Header.Add("Content-Type", "application/json")
+
+		w := httptest.NewRecorder()
+		create(w, r)
+
+		if w.Code != tt.status {
+			t.Fatalf("%s expected StatusCode %d got %d", tt.body, tt.status, w.Code)
+		}
+
+		if tt.status != http.StatusCreated {
+			continue
+		}
+
+		link := links.Link{}
+		if err := json.NewDecoder(w.Body).Decode(&link); err != nil {
+			t.Fatal(err)
+		}
+
+		if link.RemainingClicks == nil || *link.RemainingClicks != link.MaxClicks {
+			t.Errorf("expected %d remaining clicks got %v", link.MaxClicks, link.RemainingClicks)
+		}
+	}
+
+	get := func(short string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(http.MethodGet, "/"+short, nil)
+		r.SetPathValue("short", short)
+
+		w := httptest.NewRecorder()
+		redirect(w, r)
+
+		return w
+	}
+
+	var (
+		wg       sync.WaitGroup
+		mu       sync.Mutex
+		statuses = make(map[int]int)
+	)
+
+	for range 50 {
+		wg.Add(1)
+
+		go func() {
+			defer wg.Done()
+
+			w := get("invite")
+
+			mu.Lock()
+			statuses[w.Code]++
+			mu.Unlock()
+		}()
+	}
+
+	wg.Wait()
+
+	if statuses[http.StatusTemporaryRedirect] != 10 || statuses[http.StatusGone] != 40 {
+		t.Errorf("expected 10 redirects and 40 gone got %v", statuses)
+	}
+
+	// HEAD requests of link unfurlers follow the link without using it up
+	for range 3 {
+		r := httptest.NewRequest(http.MethodHead, "/once", nil)
+		r.SetPathValue("short", "once")
+
+		w := httptest.NewRecorder()
+		redirect(w, r)
+
+		if w.Header().Get("Location") != "http://example.com/once" {
+			t.Errorf("expected HEAD to redirect to destination got %d %q", w.Code, w.Header().Get("Location"))
+		}
+	}
+
+	if w := get("once"); w.Header().Get("Location") != "http://example.com/once" {
+		t.Errorf("expected first visit to redirect to destination got %q", w.Header().Get("Location"))
+	}
+
+	w := get("once")
+	if w.Code != http.StatusTemporaryRedirect || w.Header().Get("Location") != "http://example.com/used" {
+		t.Errorf("expected exhausted link to redirect to fallback got %d %q", w.Code, w.Header().Get("Location"))
+	}
+
+	r := httptest.NewRequest(http.MethodGet, "/api/v1/links/once", nil)
+	r.SetPathValue("short", "once")
+
+	w = httptest.NewRecorder()
+	links.HandlerGetLink(logger, store)(w, r)
+
+	link := links.Link{}
+	if err := json.NewDecoder(w.Body).Decode(&link); err != nil {
+		t.Fatal(err)
+	}
+
+	if link.RemainingClicks == nil || *link.RemainingClicks != 0 {
+		t.Errorf("expected 0 remaining clicks got %v", link.RemainingClicks)
+	}
+}
@@ -0,0 +1,275 @@
+package links
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jacekdobrowolski/goshort/internal/auth"
+	"github.com/jacekdobrowolski/goshort/pkg/logging"
+	"github.com/jacekdobrowolski/goshort/pkg/proxy"
+	"github.com/jacekdobrowolski/goshort/pkg/ratelimit"
+	"github.com/jacekdobrowolski/goshort/pkg/urlpolicy"
+)
+
+// Config holds optional settings read from LINKS_* environment variables.
+type Config struct {
+	AccessLog      *logging.AccessLogOptions
+	TrustedProxies proxy.Trusted
+	// PublicBaseURL is the canonical base of short URLs of links without a
+	// domain, the origin the client used when empty.
+	PublicBaseURL string
+	JWT           *JWTConfig
+	// DefaultMaxLinks caps links per tenant without its own limit, 0 is unlimited.
+	DefaultMaxLinks int
+	// RateLimits maps route groups to limits, groups without one are not limited.
+	RateLimits       map[string]ratelimit.Limit
+	RateLimitBackend string
+	// URLPolicy validates destinations of created and updated links.
+	URLPolicy urlpolicy.Policy
+	Blocklist *BlocklistConfig
+	// CookieSecret signs unlock cookies of password protected links, replicas
+	// must share it.
+	CookieSecret     []byte
+	PasswordAttempts ratelimit.Limit
+	// RedirectStatus is the default status of link redirects.
+	RedirectStatus          int
+	PermanentRedirectMaxAge time.Duration
+	// GeoIPDatabase is the path of a MaxMind format database, Run opens it
+	// as Locator.
+	GeoIPDatabase string
+	Locator       Locator
+	// IdempotencyRetention is how long responses to requests with an
+	// Idempotency-Key are replayed.
+	IdempotencyRetention time.Duration
+}
+
+type BlocklistConfig struct {
+	DomainFiles     []string
+	HashPrefixFiles []string
+	ReloadInterval  time.Duration
+}
+
+const (
+	RateLimitBackendMemory   = "memory"
+	RateLimitBackendPostgres = "postgres"
+)
+
+var (
+	errUnknownRateLimitBackend = errors.New("unknown rate limit backend, expected memory or postgres")
+	errInvalidRedirectStatus   = errors.New("invalid redirect status, expected 301, 302, 307 or 308")
+	errNonPositiveDuration     = errors.New("duration must be positive")
+)
+
+type JWTConfig struct {
+	// JWKS is a file path or http(s) URL of the identity provider key set.
+	JWKS            string
+	RefreshInterval time.Duration
+	Options         auth.JWTOptions
+}
+
+func LoadConfig(env func(string) string) (Config, error) {
+	var cfg Config
+
+	trusted, err := proxy.ParseTrusted(env("LINKS_TRUSTED_PROXIES"))
+	if err != nil {
+		return cfg, fmt.Errorf("error parsing LINKS_TRUSTED_PROXIES: %w", err)
+	}
+
+	trusted.Header, err = proxy.ParseHeader(env("LINKS_TRUSTED_PROXY_HEADER"))
+	if err != nil {
+		return cfg, fmt.Errorf("error parsing LINKS_TRUSTED_PROXY_HEADER: %w", err)
+	}
+
+	cfg.TrustedProxies = trusted
+
+	if baseURL := env("LINKS_PUBLIC_BASE_URL"); baseURL != "" {
+		if cfg.PublicBaseURL, err = ParseBaseURL(baseURL); err != nil {
+			return cfg, fmt.Errorf("error parsing LINKS_PUBLIC_BASE_URL: %w", err)
+		}
+	}
+
+	cfg.URLPolicy = urlpolicy.Default()
+
+	if schemes := env("LINKS_URL_SCHEMES"); schemes != "" {
+		cfg.URLPolicy.Schemes = urlpolicy.ParseList(strings.ToLower(schemes))
+	}
+
+	cfg.URLPolicy.AllowDomains = urlpolicy.ParseList(env("LINKS_URL_ALLOW_DOMAINS"))
+	cfg.URLPolicy.DenyDomains = urlpolicy.ParseList(env("LINKS_URL_DENY_DOMAINS"))
+
+	if allowPrivate := env("LINKS_URL_ALLOW_PRIVATE"); allowPrivate != "" {
+		cfg.URLPolicy.AllowPrivate, err = strconv.ParseBool(allowPrivate)
+		if err != nil {
+			return cfg, fmt.Errorf("error parsing LINKS_URL_ALLOW_PRIVATE: %w", err)
+		}
+	}
+
+	if maxLength := env("LINKS_URL_MAX_LENGTH"); maxLength != "" {
+		cfg.URLPolicy.MaxLength, err = strconv.Atoi(maxLength)
+		if err != nil {
+			return cfg, fmt.Errorf("error parsing LINKS_URL_MAX_LENGTH: %w", err)
+		}
+	}
+
+	if maxLinks := env("LINKS_TENANT_MAX_LINKS"); maxLinks != "" {
+		cfg.DefaultMaxLinks, err = strconv.Atoi(maxLinks)
+		if err != nil {
+			return cfg, fmt.Errorf("error parsing LINKS_TENANT_MAX_LINKS: %w", err)
+		}
+	}
+
+	domainFiles := urlpolicy.ParseList(env("LINKS_BLOCKLIST_DOMAINS"))
+	hashPrefixFiles := urlpolicy.ParseList(env("LINKS_BLOCKLIST_HASH_PREFIXES"))
+
+	if len(domainFiles) > 0 || len(hashPrefixFiles) > 0 {
+		cfg.Blocklist = &BlocklistConfig{
+			DomainFiles:     domainFiles,
+			HashPrefixFiles: hashPrefixFiles,
+			ReloadInterval:  time.Minute,
+		}
+
+		if reload := env("LINKS_BLOCKLIST_RELOAD"); reload != "" {
+			cfg.Blocklist.ReloadInterval, err = parsePositiveDuration(reload)
+			if err != nil {
+				return cfg, fmt.Errorf("error parsing LINKS_BLOCKLIST_RELOAD: %w", err)
+			}
+		}
+	}
+
+	cfg.RedirectStatus = http.StatusTemporaryRedirect
+
+	if status := env("LINKS_REDIRECT_STATUS"); status != "" {
+		cfg.RedirectStatus, err = strconv.Atoi(status)
+		if err != nil || !ValidRedirectStatus(cfg.RedirectStatus) {
+			return cfg, fmt.Errorf("error parsing LINKS_REDIRECT_STATUS: %w", errInvalidRedirectStatus)
+		}
+	}
+
+	cfg.PermanentRedirectMaxAge = 24 * time.Hour
+
+	if maxAge := env("LINKS_PERMANENT_REDIRECT_MAX_AGE"); maxAge != "" {
+		cfg.PermanentRedirectMaxAge, err = time.ParseDuration(maxAge)
+		if err != nil {
+			return cfg, fmt.Errorf("error parsing LINKS_PERMANENT_REDIRECT_MAX_AGE: %w", err)
+		}
+	}
+
+	cfg.CookieSecret = []byte(env("LINKS_COOKIE_SECRET"))
+
+	cfg.GeoIPDatabase = env("LINKS_GEOIP_DATABASE")
+
+	cfg.IdempotencyRetention = DefaultIdempotencyRetention
+
+	if retention := env("LINKS_IDEMPOTENCY_RETENTION"); retention != "" {
+		cfg.IdempotencyRetention, err = parsePositiveDuration(retention)
+		if err != nil {
+			return cfg, fmt.Errorf("error parsing LINKS_IDEMPOTENCY_RETENTION: %w", err)
+		}
+	}
+
+	cfg.PasswordAttempts = ratelimit.Limit{Rate: 5.0 / 60, Burst: 5}
+
+	if attempts := env("LINKS_PASSWORD_ATTEMPTS"); attempts != "" {
+		cfg.PasswordAttempts, err = ratelimit.ParseLimit(attempts)
+		if err != nil {
+			return cfg, fmt.Errorf("error parsing LINKS_PASSWORD_ATTEMPTS: %w", err)
+		}
+	}
+
+	cfg.RateLimits = make(map[string]ratelimit.Limit)
+
+	for group, variable := range map[string]string{
+		RouteGroupCreate:   "LINKS_RATE_LIMIT_CREATE",
+		RouteGroupAPI:      "LINKS_RATE_LIMIT_API",
+		RouteGroupRedirect: "LINKS_RATE_LIMIT_REDIRECT",
+	} {
+		if value := env(variable); value != "" {
+			cfg.RateLimits[group], err = ratelimit.ParseLimit(value)
+			if err != nil {
+				return cfg, fmt.Errorf("error parsing %s: %w", variable, err)
+			}
+		}
+	}
+
+	cfg.RateLimitBackend = env("LINKS_RATE_LIMIT_BACKEND")
+	switch cfg.RateLimitBackend {
+	case "":
+		cfg.RateLimitBackend = RateLimitBackendMemory
+	case RateLimitBackendMemory, RateLimitBackendPostgres:
+	default:
+		return cfg, fmt.Errorf("error parsing LINKS_RATE_LIMIT_BACKEND: %w", errUnknownRateLimitBackend)
+	}
+
+	if format := env("LINKS_ACCESS_LOG_FORMAT"); format != "" {
+		accessLog := logging.AccessLogOptions{
+			TrustedProxies: trusted,
+		}
+
+		accessLog.Format, err = logging.ParseAccessLogFormat(format)
+		if err != nil {
+			return cfg, fmt.Errorf("error parsing LINKS_ACCESS_LOG_FORMAT: %w", err)
+		}
+
+		accessLog.Fields, err = logging.ParseAccessLogFields(env("LINKS_ACCESS_LOG_FIELDS"))
+		if err != nil {
+			return cfg, fmt.Errorf("error parsing LINKS_ACCESS_LOG_FIELDS: %w", err)
+		}
+
+		if sampling := env("LINKS_ACCESS_LOG_SUCCESS_SAMPLING"); sampling != "" {
+			accessLog.SuccessSampling, err = strconv.ParseUint(sampling, 10, 64)
+			if err != nil {
+				return cfg, fmt.Errorf("error parsing LINKS_ACCESS_LOG_SUCCESS_SAMPLING: %w", err)
+			}
+		}
+
+		cfg.AccessLog = &accessLog
+	}
+
+	if jwks := env("LINKS_JWT_JWKS"); jwks != "" {
+		jwtConfig := JWTConfig{
+			JWKS:            jwks,
+			RefreshInterval: time.Hour,
+			Options: auth.JWTOptions{
+				Issuer:      env("LINKS_JWT_ISSUER"),
+				Audience:    env("LINKS_JWT_AUDIENCE"),
+				ScopeClaim:  env("LINKS_JWT_SCOPE_CLAIM"),
+				TenantClaim: env("LINKS_JWT_TENANT_CLAIM"),
+			},
+		}
+
+		jwtConfig.Options.ScopeMapping, err = auth.ParseScopeMapping(env("LINKS_JWT_SCOPE_MAP"))
+		if err != nil {
+			return cfg, fmt.Errorf("error parsing LINKS_JWT_SCOPE_MAP: %w", err)
+		}
+
+		if refresh := env("LINKS_JWT_JWKS_REFRESH"); refresh != "" {
+			jwtConfig.RefreshInterval, err = parsePositiveDuration(refresh)
+			if err != nil {
+				return cfg, fmt.Errorf("error parsing LINKS_JWT_JWKS_REFRESH: %w", err)
+			}
+		}
+
+		cfg.JWT = &jwtConfig
+	}
+
+	return cfg, nil
+}
+
+// parsePositiveDuration parses intervals and retentions, zero or negative
+// values would panic tickers or expire everything immediately.
+func parsePositiveDuration(value string) (time.Duration, error) {
+	duration, err := time.ParseDuration(value)
+	if err != nil {
+		return 0, fmt.Errorf("error parsing duration: %w", err)
+	}
+
+	if duration <= 0 {
+		return 0, fmt.Errorf("%w: %s", errNonPositiveDuration, value)
+	}
+
+	return duration, nil
+}
@@ -0,0 +1,40 @@
+package links_test
+
+import (
+	"testing"
+
+	"github.com/jacekdobrowolski/goshort/internal/links"
+)
+
+func Test_loadConfigDurations(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name  string
+		env   map[string]string
+		valid bool
+	}{
+		{name: "defaults", env: map[string]string{}, valid: true},
+		{
+			name:  "blocklist reload",
+			env:   map[string]string{"LINKS_BLOCKLIST_DOMAINS": "domains.txt", "LINKS_BLOCKLIST_RELOAD": "30s"},
+			valid: true,
+		},
+		{
+			name: "zero blocklist reload",
+			env:  map[string]string{"LINKS_BLOCKLIST_DOMAINS": "domains.txt", "LINKS_BLOCKLIST_RELOAD": "0s"},
+		},
+		{
+			name: "negative jwks refresh",
+			env:  map[string]string{"LINKS_JWT_JWKS": "jwks.json", "LINKS_JWT_JWKS_REFRESH": "-1m"},
+		},
+		{name: "zero idempotency retention", env: map[string]string{"LINKS_IDEMPOTENCY_RETENTION": "0"}},
+	}
+
+	for _, tt := range tests {
+		_, err := links.LoadConfig(func(key string) string { return tt.env[key] })
+		if (err == nil) != tt.valid {
+			t.Errorf("%s expected valid %t got %v", tt.name, tt.valid, err)
+		}
+	}
+}
@@ -0,0 +1,154 @@
+package links_test
+
+import (
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/jacekdobrowolski/goshort/internal/links"
+)
+
+func Test_customDomains(t *testing.T) {
+	t.Parallel()
+
+	store := newMockStore()
+	store.domains["go.acme.test"] = links.Domain{Host: "go.acme.test", Tenant: "acme", BaseURL: "https://go.acme.test"}
+	store.domains["acme.link"] = links.Domain{Host: "acme.link", Tenant: "acme", BaseURL: "http://acme.link:8080"}
+	store.domains["globex.link"] = links.Domain{Host: "globex.link", Tenant: "globex", BaseURL: "http://globex.link"}
+	logger := slog.New(slog.NewTextHandler(io.Discard, &slog.HandlerOptions{Level: slog.LevelError}))
+
+	create := links.HandlerCreateLink(logger, store, nil)
+	redirect := links.HandlerRedirect(logger, store, links.RedirectOptions{})
+
+	createTests := []struct {
+		body   string
+		status int
+		short  string
+	}{
+		{
+			body:   `{"url":"http://acme.test/go","alias":"promo","domain":"go.acme.test"}`,
+			status: http.StatusCreated,
+			short:  "https://go.acme.test/promo",
+		},
+		{
+			body:   `{"url":"http://acme.test/link","alias":"promo","domain":"ACME.link"}`,
+			status: http.StatusCreated,
+			short:  "http://acme.link:8080/promo",
+		},
+		{
+			body:   `{"url":"http://acme.test/any","alias":"promo"}`,
+			status: http.StatusCreated,
+			short:  "http://example.com/promo",
+		},
+		{
+			body:   `{"url":"http://acme.test/any","alias":"other"}`,
+			status: http.StatusCreated,
+			short:  "http://example.com/other",
+		},
+		{body: `{"url":"http://acme.test/go","alias":"promo","domain":"go.acme.test"}`, status: http.StatusConflict},
+		{body: `{"url":"http://acme.test/","domain":"globex.link"}`, status: http.StatusBadRequest},
+		{body: `{"url":"http://acme.test/","domain":"unknown.test"}`, status: http.StatusBadRequest},
+	}
+
+	for _, tt := range createTests {
+		r := httptest.NewRequest(http.MethodPost, "/api/v1/links", strings.NewReader(tt.body))
+		r.Header.Add("Content-Type", "application/json")
+
+		w := httptest.NewRecorder()
+		create(w, asTenant(r, "acme"))
+
+		if w.Code != tt.status {
+			t.Fatalf("%s expected StatusCode %d got %d", tt.body, tt.status, w.Code)
+		}
+
+		if tt.status != http.StatusCreated {
+			continue
+		}
+
+		link := links.Link{}
+		if err := json.NewDecoder(w.Body).Decode(&link); err != nil {
+			t.Fatal(err)
+		}
+
+		if link.Short != tt.short {
+			t.Errorf("%s expected short %s got %s", tt.body, tt.short, link.Short)
+		}
+	}
+
+	tests := []struct {
+		host     string
+		short    string
+		status   int
+		location string
+	}{
+		{host: "go.acme.test", short: "promo", status: http.StatusTemporaryRedirect, location: "http://acme.test/go"},
+		{host: "GO.ACME.TEST:443", short: "promo", status: http.StatusTemporaryRedirect, location: "http://acme.test/go"},
+		{host: "acme.link", short: "promo", status: http.StatusTemporaryRedirect, location: "http://acme.test/link"},
+		// unbound links are served on every domain of the tenant
+		{host: "acme.link", short: "other", status: http.StatusTemporaryRedirect, location: "http://acme.test/any"},
+		{host: "globex.link", short: "promo", status: http.StatusNotFound, location: ""},
+		{host: "unknown.test", short: "promo", status: http.StatusNotFound, location: ""},
+	}
+
+	for _, tt := range tests {
+		r := httptest.NewRequest(http.MethodGet, "http://"+tt.host+"/"+tt.short, nil)
+		r.SetPathValue("short", tt.short)
+
+		w := httptest.NewRecorder()
+		redirect(w, r)
+
+		if w.Code != tt.status {
+			t.Errorf("%s/%s expected StatusCode %d got %d", tt.host, tt.short, tt.status, w.Code)
+		}
+
+		if location := w.Header().Get("Location"); location != tt.location {
+			t.Errorf("%s/%s expected Location %q got %q", tt.host, tt.short, tt.location, location)
+		}
+	}
+
+	r := httptest.NewRequest(http.MethodGet, "/api/v1/links/promo?domain=acme.link", nil)
+	r.SetPathValue("short", "promo")
+
+	w := httptest.NewRecorder()
+	links.HandlerGetLink(logger, store)(w, asTenant(r, "acme"))
+
+	link := links.Link{}
+	if err := json.NewDecoder(w.Body).Decode(&link); err != nil {
+		t.Fatal(err)
+	}
+
+	if link.Domain != "acme.link" || link.Original != "http://acme.test/link" {
+		t.Errorf("expected acme.link link got %s %s", link.Domain, link.Original)
+	}
+}
+
+func Test_newDomain(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		host    string
+		baseURL string
+		want    string
+		err     bool
+	}{
+		{host: "Go.Acme.Test.", want: "https://go.acme.test"},
+		{host: "acme.link", baseURL: "https://acme.link/", want: "https://acme.link"},
+		// redirects are served on /{short} only
+		{host: "acme.link", baseURL: "https://acme.link/s", err: true},
+		{host: "localhost:3000", baseURL: "http://localhost:3000", want: "http://localhost:3000"},
+		{host: "acme.link", baseURL: "ftp://acme.link", err: true},
+		{host: "acme.link", baseURL: "https://acme.link/?a=b", err: true},
+		{host: "acme.link", baseURL: "acme.link", err: true},
+	}
+
+	for _, tt := range tests {
+		domain, err := links.NewDomain(tt.host, "acme", tt.baseURL)
+		if (err != nil) != tt.err || domain.BaseURL != tt.want {
+			t.Errorf("%s %s expected %q error %t got %q %v", tt.host, tt.baseURL, tt.want, tt.err, domain.BaseURL, err)
+		}
+	}
+}
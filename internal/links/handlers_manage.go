@@ -0,0 +1,298 @@
+package links
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/jacekdobrowolski/goshort/pkg/urlpolicy"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+)
+
+const (
+	defaultListLimit = 50
+	maxListLimit     = 1000
+)
+
+type LinkStats struct {
+	Short     string    `json:"short"`
+	Clicks    int64     `json:"clicks"`
+	CreatedAt time.Time `json:"createdAt"`
+	UTM       *UTM      `json:"utm,omitempty"`
+	// Countries counts clicks per ISO 3166-1 alpha-2 code of the client IP.
+	Countries map[string]int64 `json:"countries,omitempty"`
+	// Variants counts clicks per variant name.
+	Variants map[string]int64 `json:"variants,omitempty"`
+}
+
+func HandlerListLinks(logger *slog.Logger, store Store) http.HandlerFunc {
+	tracer := otel.Tracer("handlerlistlinks")
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx, span := tracer.Start(r.Context(), "list_links")
+		defer span.End()
+
+		limit, err := queryInt(r, "limit", defaultListLimit)
+		if err != nil || limit < 1 || limit > maxListLimit {
+			span.SetStatus(codes.Error, "invalid limit")
+			writeError(w, http.StatusBadRequest, "limit must be between 1 and "+strconv.Itoa(maxListLimit))
+
+			return
+		}
+
+		offset, err := queryInt(r, "offset", 0)
+		if err != nil || offset < 0 {
+			span.SetStatus(codes.Error, "invalid offset")
+			writeError(w, http.StatusBadRequest, "offset must not be negative")
+
+			return
+		}
+
+		tenant, _ := callerTenant(ctx)
+
+		stored, err := store.ListLinks(ctx, tenant, limit, offset)
+		if err != nil {
+			logger.ErrorContext(ctx, "error listing links", "err", err)
+			span.RecordError(err)
+			span.SetStatus(codes.Error, "error listing links")
+
+			w.WriteHeader(http.StatusInternalServerError)
+
+			return
+		}
+
+		links := make([]Link, 0, len(stored))
+		for _, link := range stored {
+			links = append(links, linkResponse(r, &link))
+		}
+
+		if err := WriteJSON(w, http.StatusOK, links); err != nil {
+			logger.ErrorContext(ctx, "error writing JSON response", "err", err)
+			span.RecordError(err)
+		}
+	}
+}
+
+func HandlerLinkStats(logger *slog.Logger, store Store) http.HandlerFunc {
+	tracer := otel.Tracer("handlerlinkstats")
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx, span := tracer.Start(r.Context(), "link_stats")
+		defer span.End()
+
+		tenant, _ := callerTenant(ctx)
+
+		link, err := store.GetLink(ctx, tenant, linkDomain(r), r.PathValue("short"))
+		if err != nil {
+			logger.InfoContext(ctx, "unknown link", "short", r.PathValue("short"))
+			span.RecordError(err)
+			span.SetStatus(codes.Error, "unknown link")
+
+			w.WriteHeader(statusForStoreError(err))
+
+			return
+		}
+
+		countries, err := store.CountryClicks(ctx, tenant, link.Domain, link.Short)
+		if err != nil {
+			logger.ErrorContext(ctx, "error reading country clicks", "short", link.Short, "err", err)
+			span.RecordError(err)
+			span.SetStatus(codes.Error, "error reading country clicks")
+
+			w.WriteHeader(statusForStoreError(err))
+
+			return
+		}
+
+		variants, err := store.VariantClicks(ctx, tenant, link.Domain, link.Short)
+		if err != nil {
+			logger.ErrorContext(ctx, "error reading variant clicks", "short", link.Short, "err", err)
+			span.RecordError(err)
+			span.SetStatus(codes.Error, "error reading variant clicks")
+
+			w.WriteHeader(statusForStoreError(err))
+
+			return
+		}
+
+		stats := LinkStats{
+			Short:     link.Short,
+			Clicks:    link.Clicks,
+			CreatedAt: link.CreatedAt,
+			UTM:       link.UTM.orNil(),
+			Countries: countries,
+			Variants:  variants,
+		}
+
+		if err := WriteJSON(w, http.StatusOK, stats); err != nil {
+			logger.ErrorContext(ctx, "error writing JSON response", "err", err)
+			span.RecordError(err)
+		}
+	}
+}
+
+// HandlerCampaignStats reports links and clicks per UTM campaign.
+func HandlerCampaignStats(logger *slog.Logger, store Store) http.HandlerFunc {
+	tracer := otel.Tracer("handlercampaignstats")
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx, span := tracer.Start(r.Context(), "campaign_stats")
+		defer span.End()
+
+		tenant, _ := callerTenant(ctx)
+
+		stats, err := store.CampaignStats(ctx, tenant)
+		if err != nil {
+			logger.ErrorContext(ctx, "error reading campaign stats", "err", err)
+			span.RecordError(err)
+			span.SetStatus(codes.Error, "error reading campaign stats")
+
+			w.WriteHeader(http.StatusInternalServerError)
+
+			return
+		}
+
+		if err := WriteJSON(w, http.StatusOK, stats); err != nil {
+			logger.ErrorContext(ctx, "error writing JSON response", "err", err)
+			span.RecordError(err)
+		}
+	}
+}
+
+// HandlerUpdateLink changes the link destination. Links whose settings
+// depend on the destination are rejected with 409.
+func HandlerUpdateLink(logger *slog.Logger, store Store, policy *urlpolicy.Policy) http.HandlerFunc {
+	tracer := otel.Tracer("handlerupdatelink")
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx, span := tracer.Start(r.Context(), "update_link")
+		defer span.End()
+
+		requestBody := struct {
+			URL string `json:"url"`
+		}{}
+
+		if r.Header.Get("Content-Type") != "application/json" {
+			span.SetStatus(codes.Error, "unexpected content-type")
+			w.WriteHeader(http.StatusBadRequest)
+
+			return
+		}
+
+		if err := json.NewDecoder(r.Body).Decode(&requestBody); err != nil || requestBody.URL == "" {
+			span.SetStatus(codes.Error, "error parsing json request body")
+			w.WriteHeader(http.StatusBadRequest)
+
+			return
+		}
+
+		if err := checkDestination(ctx, policy, requestBody.URL); err != nil {
+			span.SetStatus(codes.Error, "invalid url")
+			span.RecordError(err)
+			writeError(w, http.StatusBadRequest, err.Error())
+
+			return
+		}
+
+		tenant, _ := callerTenant(ctx)
+		domain := linkDomain(r)
+		short := r.PathValue("short")
+
+		existing, err := store.GetLink(ctx, tenant, domain, short)
+		if err != nil {
+			logger.InfoContext(ctx, "error reading link", "short", short, "err", err)
+			span.RecordError(err)
+			span.SetStatus(codes.Error, "error reading link")
+
+			w.WriteHeader(statusForStoreError(err))
+
+			return
+		}
+
+		if existing.dependsOnDestination() {
+			span.SetStatus(codes.Error, "link settings depend on destination")
+			writeError(w, http.StatusConflict,
+				"link has UTM, targets, variants, schedule or passthrough settings, create a new link instead")
+
+			return
+		}
+
+		// disabled links stay disabled unless the blocklist cleared the destination
+		enable := policy != nil && policy.Blocklist != nil
+
+		if err := store.UpdateLink(ctx, tenant, domain, short, requestBody.URL, enable); err != nil {
+			logger.InfoContext(ctx, "error updating link", "short", short, "err", err)
+			span.RecordError(err)
+			span.SetStatus(codes.Error, "error updating link")
+
+			w.WriteHeader(statusForStoreError(err))
+
+			return
+		}
+
+		stored, err := store.GetLink(ctx, tenant, domain, short)
+		if err != nil {
+			logger.ErrorContext(ctx, "error reading updated link", "short", short, "err", err)
+			span.RecordError(err)
+			span.SetStatus(codes.Error, "error reading updated link")
+
+			w.WriteHeader(statusForStoreError(err))
+
+			return
+		}
+
+		if err := WriteJSON(w, http.StatusOK, linkResponse(r, stored)); err != nil {
+			logger.ErrorContext(ctx, "error writing JSON response", "err", err)
+			span.RecordError(err)
+		}
+	}
+}
+
+func HandlerDeleteLink(logger *slog.Logger, store Store) http.HandlerFunc {
+	tracer := otel.Tracer("handlerdeletelink")
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx, span := tracer.Start(r.Context(), "delete_link")
+		defer span.End()
+
+		tenant, _ := callerTenant(ctx)
+		short := r.PathValue("short")
+
+		if err := store.DeleteLink(ctx, tenant, linkDomain(r), short); err != nil {
+			logger.InfoContext(ctx, "error deleting link", "short", short, "err", err)
+			span.RecordError(err)
+			span.SetStatus(codes.Error, "error deleting link")
+
+			w.WriteHeader(statusForStoreError(err))
+
+			return
+		}
+
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+func queryInt(r *http.Request, name string, fallback int) (int, error) {
+	value := r.URL.Query().Get(name)
+	if value == "" {
+		return fallback, nil
+	}
+
+	parsed, err := strconv.Atoi(value)
+	if err != nil {
+		return 0, fmt.Errorf("error parsing %s: %w", name, err)
+	}
+
+	return parsed, nil
+}
+
+// dependsOnDestination reports settings derived from or tied to the link
+// destination that a new destination would contradict.
+func (link *StoredLink) dependsOnDestination() bool {
+	return link.UTM != (UTM{}) || len(link.Targets) > 0 || len(link.Variants) > 0 || link.Schedule != nil ||
+		link.QueryPassthrough != "" || link.PathPassthrough
+}
@@ -3,7 +3,6 @@ package links_test
 import (
 	"context"
 	"encoding/json"
-	"errors"
 	"fmt"
 	"io"
 	"log/slog"
@@ -12,53 +11,312 @@ import (
 	"net/url"
 	"path"
 	"regexp"
+	"sort"
 	"strings"
+	"sync"
 	"testing"
+	"time"
 	"unicode/utf8"
 
 	"github.com/jacekdobrowolski/goshort/internal/links"
+	"github.com/jacekdobrowolski/goshort/pkg/urlpolicy"
 )
 
-type mockRow struct {
-	short    string
-	original string
-}
-
 type mockStore struct {
-	m map[string]mockRow
+	mu sync.Mutex
+	m  map[string]links.StoredLink
+	// limits caps links per tenant, missing tenants are unlimited.
+	limits map[string]int
+	// domains are registered domains by host.
+	domains map[string]links.Domain
+	// countries and variants count clicks per link.
+	countries map[string]map[string]int64
+	variants  map[string]map[string]int64
+	// responses are idempotent responses by tenant, principal and key.
+	responses map[links.IdempotencyKey]links.IdempotentResponse
 }
 
 func newMockStore() *mockStore {
 	return &mockStore{
-		m: make(map[string]mockRow),
+		m:         make(map[string]links.StoredLink),
+		limits:    make(map[string]int),
+		domains:   make(map[string]links.Domain),
+		countries: make(map[string]map[string]int64),
+		variants:  make(map[string]map[string]int64),
+		responses: make(map[links.IdempotencyKey]links.IdempotentResponse),
+	}
+}
+
+func mockKey(tenant, domain, short string) string {
+	return tenant + "/" + domain + "/" + short
+}
+
+func (mps *mockStore) AddLink(_ context.Context, link links.StoredLink) error {
+	mps.mu.Lock()
+	defer mps.mu.Unlock()
+
+	if _, ok := mps.m[mockKey(link.Tenant, link.Domain, link.Short)]; ok {
+		return fmt.Errorf("%w %s", links.ErrShortExists, link.Short)
+	}
+
+	if limit, ok := mps.limits[link.Tenant]; ok {
+		count := 0
+
+		for _, stored := range mps.m {
+			if stored.Tenant == link.Tenant {
+				count++
+			}
+		}
+
+		if count >= limit {
+			return links.ErrQuotaExceeded
+		}
+	}
+
+	link.CreatedAt = time.Now()
+	mps.m[mockKey(link.Tenant, link.Domain, link.Short)] = link
+
+	return nil
+}
+
+func (mps *mockStore) GetLink(_ context.Context, tenant, domain, short string) (*links.StoredLink, error) {
+	mps.mu.Lock()
+	defer mps.mu.Unlock()
+
+	link, ok := mps.m[mockKey(tenant, domain, short)]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", links.ErrLinkNotFound, short)
 	}
+
+	link.BaseURL = mps.domains[link.Domain].BaseURL
+
+	return &link, nil
+}
+
+func (mps *mockStore) ListLinks(_ context.Context, tenant string, limit, offset int) ([]links.StoredLink, error) {
+	mps.mu.Lock()
+	defer mps.mu.Unlock()
+
+	var result []links.StoredLink
+
+	for _, link := range mps.m {
+		if link.Tenant == tenant {
+			result = append(result, link)
+		}
+	}
+
+	sort.Slice(result, func(i, j int) bool { return result[i].Short < result[j].Short })
+
+	if offset > len(result) {
+		offset = len(result)
+	}
+
+	return result[offset:min(offset+limit, len(result))], nil
 }
 
-var errShortExists = errors.New("short already exists")
+func (mps *mockStore) UpdateLink(_ context.Context, tenant, domain, short, original string, enable bool) error {
+	mps.mu.Lock()
+	defer mps.mu.Unlock()
 
-func (mps *mockStore) AddLink(_ context.Context, short, url string) error {
-	_, ok := mps.m[short]
-	if ok {
-		return fmt.Errorf("%w %s", errShortExists, short)
+	link, ok := mps.m[mockKey(tenant, domain, short)]
+	if !ok {
+		return fmt.Errorf("%w: %s", links.ErrLinkNotFound, short)
 	}
 
-	mps.m[short] = mockRow{
-		short:    short,
-		original: url,
+	link.Original = original
+
+	if enable {
+		link.DisabledReason = ""
 	}
 
+	mps.m[mockKey(tenant, domain, short)] = link
+
 	return nil
 }
 
-var errShortDoesntExist = errors.New("short does not exists")
+func (mps *mockStore) DisableLink(_ context.Context, tenant, domain, short, reason string) error {
+	mps.mu.Lock()
+	defer mps.mu.Unlock()
+
+	link, ok := mps.m[mockKey(tenant, domain, short)]
+	if !ok {
+		return fmt.Errorf("%w: %s", links.ErrLinkNotFound, short)
+	}
+
+	link.DisabledReason = reason
+	mps.m[mockKey(tenant, domain, short)] = link
+
+	return nil
+}
+
+func (mps *mockStore) DeleteLink(_ context.Context, tenant, domain, short string) error {
+	mps.mu.Lock()
+	defer mps.mu.Unlock()
+
+	if _, ok := mps.m[mockKey(tenant, domain, short)]; !ok {
+		return fmt.Errorf("%w: %s", links.ErrLinkNotFound, short)
+	}
+
+	delete(mps.m, mockKey(tenant, domain, short))
+
+	return nil
+}
+
+func (mps *mockStore) RecordClick(_ context.Context, tenant, domain, short string, click links.Click) error {
+	mps.mu.Lock()
+	defer mps.mu.Unlock()
+
+	link, ok := mps.m[mockKey(tenant, domain, short)]
+	if !ok {
+		return fmt.Errorf("%w: %s", links.ErrLinkNotFound, short)
+	}
+
+	if link.MaxClicks > 0 {
+		if link.RemainingClicks == 0 {
+			return fmt.Errorf("%w: %s", links.ErrClicksExhausted, short)
+		}
+
+		link.RemainingClicks--
+	}
+
+	link.Clicks++
+	mps.m[mockKey(tenant, domain, short)] = link
+
+	countClick(mps.countries, mockKey(tenant, domain, short), click.Country)
+	countClick(mps.variants, mockKey(tenant, domain, short), click.Variant)
+
+	return nil
+}
+
+func countClick(counts map[string]map[string]int64, link, value string) {
+	if value == "" {
+		return
+	}
+
+	if counts[link] == nil {
+		counts[link] = make(map[string]int64)
+	}
+
+	counts[link][value]++
+}
+
+func (mps *mockStore) clickCounts(
+	counts map[string]map[string]int64,
+	tenant, domain, short string,
+) (map[string]int64, error) {
+	mps.mu.Lock()
+	defer mps.mu.Unlock()
+
+	if _, ok := mps.m[mockKey(tenant, domain, short)]; !ok {
+		return nil, fmt.Errorf("%w: %s", links.ErrLinkNotFound, short)
+	}
+
+	clicks := make(map[string]int64)
+	for key, count := range counts[mockKey(tenant, domain, short)] {
+		clicks[key] = count
+	}
+
+	return clicks, nil
+}
+
+func (mps *mockStore) CountryClicks(_ context.Context, tenant, domain, short string) (map[string]int64, error) {
+	return mps.clickCounts(mps.countries, tenant, domain, short)
+}
+
+func (mps *mockStore) VariantClicks(_ context.Context, tenant, domain, short string) (map[string]int64, error) {
+	return mps.clickCounts(mps.variants, tenant, domain, short)
+}
+
+func (mps *mockStore) CampaignStats(_ context.Context, tenant string) ([]links.CampaignStats, error) {
+	mps.mu.Lock()
+	defer mps.mu.Unlock()
+
+	stats := []links.CampaignStats{}
+	index := make(map[links.UTM]int)
+
+	for _, link := range mps.m {
+		if link.Tenant != tenant || link.UTM.Campaign == "" {
+			continue
+		}
+
+		key := links.UTM{Source: link.UTM.Source, Medium: link.UTM.Medium, Campaign: link.UTM.Campaign}
+		if _, ok := index[key]; !ok {
+			index[key] = len(stats)
+			stats = append(stats, links.CampaignStats{Source: key.Source, Medium: key.Medium, Campaign: key.Campaign})
+		}
+
+		stats[index[key]].Links++
+		stats[index[key]].Clicks += link.Clicks
+	}
+
+	// ordered like the Postgres query
+	sort.Slice(stats, func(i, j int) bool {
+		a, b := stats[i], stats[j]
+		if a.Campaign != b.Campaign {
+			return a.Campaign < b.Campaign
+		}
+
+		if a.Source != b.Source {
+			return a.Source < b.Source
+		}
+
+		return a.Medium < b.Medium
+	})
+
+	return stats, nil
+}
+
+func (mps *mockStore) GetDomain(_ context.Context, host string) (*links.Domain, error) {
+	mps.mu.Lock()
+	defer mps.mu.Unlock()
 
-func (mps *mockStore) GetOriginal(_ context.Context, short string) (*string, error) {
-	row, ok := mps.m[short]
+	domain, ok := mps.domains[strings.ToLower(strings.Split(host, ":")[0])]
 	if !ok {
-		return nil, fmt.Errorf("%w: %s", errShortDoesntExist, short)
+		return nil, fmt.Errorf("%w: %s", links.ErrDomainNotFound, host)
 	}
 
-	return &row.original, nil
+	return &domain, nil
+}
+
+func (mps *mockStore) ReserveIdempotencyKey(
+	_ context.Context,
+	key links.IdempotencyKey,
+	fingerprint string,
+	_ time.Time,
+) (links.IdempotentResponse, bool, error) {
+	mps.mu.Lock()
+	defer mps.mu.Unlock()
+
+	if stored, ok := mps.responses[key]; ok {
+		return stored, false, nil
+	}
+
+	mps.responses[key] = links.IdempotentResponse{Fingerprint: fingerprint}
+
+	return links.IdempotentResponse{}, true, nil
+}
+
+func (mps *mockStore) CompleteIdempotencyKey(
+	_ context.Context,
+	key links.IdempotencyKey,
+	response links.IdempotentResponse,
+	_ time.Time,
+) error {
+	mps.mu.Lock()
+	defer mps.mu.Unlock()
+
+	mps.responses[key] = response
+
+	return nil
+}
+
+func (mps *mockStore) ReleaseIdempotencyKey(_ context.Context, key links.IdempotencyKey) error {
+	mps.mu.Lock()
+	defer mps.mu.Unlock()
+
+	delete(mps.responses, key)
+
+	return nil
 }
 
 func Test_handlerAddLink(t *testing.T) {
@@ -66,7 +324,7 @@ func Test_handlerAddLink(t *testing.T) {
 
 	store := newMockStore()
 	logger := slog.New(slog.NewTextHandler(io.Discard, &slog.HandlerOptions{Level: slog.LevelError}))
-	handlerFunc := links.HandlerCreateLink(logger, store)
+	handlerFunc := links.HandlerCreateLink(logger, store, nil)
 
 	t.Run("Add link to http://example.com", func(t *testing.T) {
 		t.Parallel()
@@ -99,13 +357,13 @@ func Test_handlerAddLink(t *testing.T) {
 
 		_, short := path.Split(responseStruct.Short)
 
-		storedValue, err := store.GetOriginal(r.Context(), short)
+		storedValue, err := store.GetLink(r.Context(), "", "", short)
 		if err != nil {
-			t.Fatalf("cannot retrieve value %v", store.m)
+			t.Fatalf("cannot retrieve value %v", err)
 		}
 
-		if *storedValue != "http://example.com" {
-			t.Errorf("data stored does not match got %s expected %s", *storedValue, "http://example.com")
+		if storedValue.Original != "http://example.com" {
+			t.Errorf("data stored does not match got %s expected %s", storedValue.Original, "http://example.com")
 		}
 	})
 
@@ -184,7 +442,7 @@ func Fuzz_handlerAddLink(f *testing.F) {
 	f.Fuzz(func(t *testing.T, body string, headerKey string, headerValue string) {
 		store := newMockStore()
 
-		handlerFunc := links.HandlerCreateLink(logger, store)
+		handlerFunc := links.HandlerCreateLink(logger, store, nil)
 
 		r := httptest.NewRequest(http.MethodPost, "http://goshort.test/api/v1/links", strings.NewReader(body))
 		r.Header.Add(headerKey, headerValue)
@@ -220,13 +478,13 @@ func Fuzz_handlerAddLink(f *testing.F) {
 			t.Errorf(`returned short value contains non alphanumeric characters %s`, short)
 		}
 
-		storedValue, err := store.GetOriginal(r.Context(), short)
+		storedValue, err := store.GetLink(r.Context(), "", "", short)
 		if err != nil {
-			t.Fatalf("cannot retrieve value %v", store.m)
+			t.Fatalf("cannot retrieve value %v", err)
 		}
 
-		if _, err := url.ParseRequestURI(*storedValue); err != nil {
-			t.Fatalf("stored data is not a valid URL: %s", *storedValue)
+		if _, err := url.ParseRequestURI(storedValue.Original); err != nil {
+			t.Fatalf("stored data is not a valid URL: %s", storedValue.Original)
 		}
 	})
 }
@@ -238,7 +496,7 @@ func Test_handlerGetLink(t *testing.T) {
 	logger := slog.New(slog.NewTextHandler(io.Discard, &slog.HandlerOptions{Level: slog.LevelError}))
 	handlerFunc := links.HandlerGetLink(logger, store)
 
-	err := store.AddLink(context.Background(), "test", "http://example.com")
+	err := store.AddLink(context.Background(), links.StoredLink{Short: "test", Original: "http://example.com"})
 	if err != nil {
 		t.Fatal("error adding link", err)
 	}
@@ -291,9 +549,9 @@ func Test_handlerRedirectLink(t *testing.T) {
 
 	store := newMockStore()
 	logger := slog.New(slog.NewTextHandler(io.Discard, &slog.HandlerOptions{Level: slog.LevelError}))
-	handlerFunc := links.HandlerRedirect(logger, store)
+	handlerFunc := links.HandlerRedirect(logger, store, links.RedirectOptions{})
 
-	err := store.AddLink(context.Background(), "test", "http://example.com")
+	err := store.AddLink(context.Background(), links.StoredLink{Short: "test", Original: "http://example.com"})
 	if err != nil {
 		t.Fatal("error adding link", err)
 	}
@@ -333,3 +591,158 @@ func Test_handlerRedirectLink(t *testing.T) {
 		}
 	})
 }
+
+func Test_handlerAddLinkPolicy(t *testing.T) {
+	t.Parallel()
+
+	store := newMockStore()
+	logger := slog.New(slog.NewTextHandler(io.Discard, &slog.HandlerOptions{Level: slog.LevelError}))
+	policy := urlpolicy.Default()
+	policy.AllowDomains = []string{"*.example.com"}
+	policy.AllowPrivate = true
+	handlerFunc := links.HandlerCreateLink(logger, store, &policy)
+
+	r := httptest.NewRequest(http.MethodPost, "/api/v1/links", strings.NewReader(`{"url":"file:///etc/passwd"}`))
+	r.Header.Add("Content-Type", "application/json")
+
+	w := httptest.NewRecorder()
+	handlerFunc(w, r)
+
+	if w.Code != http.StatusBadRequest {
+		t.Fatalf("expected StatusCode %d got %d", http.StatusBadRequest, w.Code)
+	}
+
+	body := struct {
+		Error string `json:"error"`
+	}{}
+	if err := json.NewDecoder(w.Body).Decode(&body); err != nil {
+		t.Fatal(err)
+	}
+
+	if body.Error != `url rejected: scheme "file" is not allowed` {
+		t.Errorf("unexpected error body %q", body.Error)
+	}
+
+	r = httptest.NewRequest(http.MethodPost, "/api/v1/links", strings.NewReader(`{"url":"https://www.example.com/"}`))
+	r.Header.Add("Content-Type", "application/json")
+
+	w = httptest.NewRecorder()
+	handlerFunc(w, r)
+
+	if w.Code != http.StatusCreated {
+		t.Errorf("expected StatusCode %d got %d", http.StatusCreated, w.Code)
+	}
+}
+
+func Test_handlerAddLinkExistingURL(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name string
+		body string
+	}{
+		{name: "interstitial", body: `{"url":"http://example.com/","interstitial":true}`},
+		{name: "redirect status", body: `{"url":"http://example.com/","redirectStatus":301}`},
+		{name: "query passthrough", body: `{"url":"http://example.com/","queryPassthrough":"merge"}`},
+		{name: "path passthrough", body: `{"url":"http://example.com/","pathPassthrough":true}`},
+	}
+
+	for _, tt := range tests {
+		store := newMockStore()
+		logger := slog.New(slog.NewTextHandler(io.Discard, &slog.HandlerOptions{Level: slog.LevelError}))
+		handlerFunc := links.HandlerCreateLink(logger, store, nil)
+
+		for i, body := range []string{`{"url":"http://example.com/"}`, tt.body} {
+			r := httptest.NewRequest(http.MethodPost, "/api/v1/links", strings.NewReader(body))
+			r.Header.Add("Content-Type", "application/json")
+
+			w := httptest.NewRecorder()
+			handlerFunc(w, r)
+
+			// settings the existing link lacks must not be dropped silently
+			want := http.StatusCreated
+			if i == 1 {
+				want = http.StatusConflict
+			}
+
+			if w.Code != want {
+				t.Errorf("%s expected StatusCode %d got %d", tt.name, want, w.Code)
+			}
+		}
+	}
+}
+
+func Test_handlerUpdateLink(t *testing.T) {
+	t.Parallel()
+
+	store := newMockStore()
+	logger := slog.New(slog.NewTextHandler(io.Discard, &slog.HandlerOptions{Level: slog.LevelError}))
+	create := links.HandlerCreateLink(logger, store, nil)
+
+	for _, body := range []string{
+		`{"url":"http://example.com/","alias":"plain"}`,
+		`{"url":"http://example.com/","alias":"disabled"}`,
+		`{"url":"http://example.com/","alias":"targeted","targets":[{"os":"ios","url":"http://example.com/ios"}]}`,
+		`{"url":"http://example.com/","alias":"campaign","utm":{"source":"news","medium":"email","campaign":"launch"}}`,
+	} {
+		r := httptest.NewRequest(http.MethodPost, "/api/v1/links", strings.NewReader(body))
+		r.Header.Add("Content-Type", "application/json")
+
+		w := httptest.NewRecorder()
+		create(w, r)
+
+		if w.Code != http.StatusCreated {
+			t.Fatalf("%s expected StatusCode %d got %d", body, http.StatusCreated, w.Code)
+		}
+	}
+
+	if err := store.DisableLink(context.Background(), "", "", "disabled", "phishing.txt"); err != nil {
+		t.Fatal(err)
+	}
+
+	withoutBlocklist := &urlpolicy.Policy{Schemes: []string{"http"}, AllowPrivate: true}
+	withBlocklist := &urlpolicy.Policy{Schemes: []string{"http"}, AllowPrivate: true, Blocklist: hostBlocklist{}}
+
+	tests := []struct {
+		short    string
+		policy   *urlpolicy.Policy
+		status   int
+		disabled string
+	}{
+		{short: "plain", policy: withoutBlocklist, status: http.StatusOK},
+		// the destination was not checked against a blocklist
+		{short: "disabled", policy: withoutBlocklist, status: http.StatusOK, disabled: "phishing.txt"},
+		{short: "disabled", policy: withBlocklist, status: http.StatusOK},
+		{short: "targeted", policy: withBlocklist, status: http.StatusConflict},
+		{short: "campaign", policy: withBlocklist, status: http.StatusConflict},
+		{short: "missing", policy: withBlocklist, status: http.StatusNotFound},
+	}
+
+	for _, tt := range tests {
+		r := httptest.NewRequest(http.MethodPatch, "/api/v1/links/"+tt.short,
+			strings.NewReader(`{"url":"http://example.com/new"}`))
+		r.Header.Add("Content-Type", "application/json")
+		r.SetPathValue("short", tt.short)
+
+		w := httptest.NewRecorder()
+		links.HandlerUpdateLink(logger, store, tt.policy)(w, r)
+
+		if w.Code != tt.status {
+			t.Errorf("%s expected StatusCode %d got %d", tt.short, tt.status, w.Code)
+		}
+
+		if tt.status != http.StatusOK {
+			continue
+		}
+
+		stored, err := store.GetLink(r.Context(), "", "", tt.short)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if stored.Original != "http://example.com/new" || stored.DisabledReason != tt.disabled {
+			t.Errorf("%s expected %q disabled %q got %q %q",
+				tt.short, "http://example.com/new", tt.disabled, stored.Original, stored.DisabledReason)
+		}
+	}
+}
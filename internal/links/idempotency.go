@@ -0,0 +1,238 @@
+package links
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"time"
+)
+
+const (
+	// DefaultIdempotencyRetention is how long responses are replayed.
+	DefaultIdempotencyRetention = 24 * time.Hour
+
+	// idempotencyLease is how long a key of a request in flight is held,
+	// a few times the server WriteTimeout so keys of requests lost with
+	// their instance do not block retries for the whole retention.
+	idempotencyLease = time.Minute
+
+	maxIdempotencyKeyLength = 255
+	maxIdempotentBodySize   = 1 << 20
+)
+
+// IdempotencyKey scopes an Idempotency-Key header to the caller.
+type IdempotencyKey struct {
+	Tenant    string
+	Principal string
+	Key       string
+}
+
+// IdempotentResponse is the stored outcome of a request, Status is 0 while
+// the request is in flight.
+type IdempotentResponse struct {
+	// Fingerprint is a hash of the request the key was first used with.
+	Fingerprint string `db:"fingerprint"`
+	Status      int    `db:"status"`
+	ContentType string `db:"content_type"`
+	Body        []byte `db:"body"`
+}
+
+// IdempotencyStore keeps responses of requests sent with an Idempotency-Key.
+type IdempotencyStore interface {
+	// ReserveIdempotencyKey claims key until lease, false with the stored
+	// response when the key is already claimed.
+	ReserveIdempotencyKey(
+		ctx context.Context,
+		key IdempotencyKey,
+		fingerprint string,
+		lease time.Time,
+	) (IdempotentResponse, bool, error)
+	// CompleteIdempotencyKey stores the response and keeps it until expires.
+	CompleteIdempotencyKey(
+		ctx context.Context,
+		key IdempotencyKey,
+		response IdempotentResponse,
+		expires time.Time,
+	) error
+	// ReleaseIdempotencyKey lets a failed request be retried with the same key.
+	ReleaseIdempotencyKey(ctx context.Context, key IdempotencyKey) error
+}
+
+// IdempotencyMiddleware replays the response of the first request with the
+// same Idempotency-Key header for retention. Reusing a key with another
+// request is rejected with 422, retries of requests still in flight with
+// 409. Server errors are not stored so the request can be retried.
+func IdempotencyMiddleware(
+	next http.Handler,
+	logger *slog.Logger,
+	store IdempotencyStore,
+	retention time.Duration,
+) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+
+		value := r.Header.Get("Idempotency-Key")
+		if value == "" {
+			next.ServeHTTP(w, r)
+
+			return
+		}
+
+		if !validIdempotencyKey(value) {
+			writeError(w, http.StatusBadRequest, "Idempotency-Key must be 1 to 255 printable ASCII characters")
+
+			return
+		}
+
+		body, err := io.ReadAll(io.LimitReader(r.Body, maxIdempotentBodySize+1))
+		if err != nil {
+			logger.DebugContext(ctx, "error reading request body", "err", err)
+			w.WriteHeader(http.StatusBadRequest)
+
+			return
+		}
+
+		if len(body) > maxIdempotentBodySize {
+			w.WriteHeader(http.StatusRequestEntityTooLarge)
+
+			return
+		}
+
+		r.Body = io.NopCloser(bytes.NewReader(body))
+
+		tenant, principal := callerTenant(ctx)
+		key := IdempotencyKey{Tenant: tenant, Principal: principal, Key: value}
+		fingerprint := requestFingerprint(r, body)
+
+		stored, reserved, err := store.ReserveIdempotencyKey(ctx, key, fingerprint, time.Now().Add(idempotencyLease))
+		if err != nil {
+			logger.ErrorContext(ctx, "error reserving idempotency key", "err", err)
+			w.WriteHeader(http.StatusInternalServerError)
+
+			return
+		}
+
+		if !reserved {
+			replay(w, stored, fingerprint)
+
+			return
+		}
+
+		// the response is sent, storing it must not depend on the client waiting
+		storeCtx := context.WithoutCancel(ctx)
+
+		release := func() {
+			if err := store.ReleaseIdempotencyKey(storeCtx, key); err != nil {
+				logger.ErrorContext(ctx, "error releasing idempotency key", "err", err)
+			}
+		}
+
+		defer func() {
+			// recovery answers 500 further up, retries must not wait for retention
+			if p := recover(); p != nil {
+				release()
+				panic(p)
+			}
+		}()
+
+		recorder := &recordingWriter{ResponseWriter: w}
+		next.ServeHTTP(recorder, r)
+
+		if recorder.status == 0 {
+			recorder.status = http.StatusOK
+		}
+
+		if recorder.status >= http.StatusInternalServerError {
+			release()
+
+			return
+		}
+
+		err = store.CompleteIdempotencyKey(storeCtx, key, IdempotentResponse{
+			Fingerprint: fingerprint,
+			Status:      recorder.status,
+			ContentType: recorder.Header().Get("Content-Type"),
+			Body:        recorder.body.Bytes(),
+		}, time.Now().Add(retention))
+		if err != nil {
+			logger.ErrorContext(ctx, "error storing idempotent response", "err", err)
+		}
+	})
+}
+
+func replay(w http.ResponseWriter, stored IdempotentResponse, fingerprint string) {
+	switch {
+	case stored.Fingerprint != fingerprint:
+		writeError(w, http.StatusUnprocessableEntity, "Idempotency-Key was used with a different request")
+	case stored.Status == 0:
+		writeError(w, http.StatusConflict, "request with this Idempotency-Key is in progress")
+	default:
+		if stored.ContentType != "" {
+			w.Header().Set("Content-Type", stored.ContentType)
+		}
+
+		w.Header().Set("Idempotent-Replayed", "true")
+		w.WriteHeader(stored.Status)
+		_, _ = w.Write(stored.Body)
+	}
+}
+
+// requestFingerprint identifies the request a key was used with, the
+// Content-Type is included since it decides how the body is read.
+func requestFingerprint(r *http.Request, body []byte) string {
+	hash := sha256.New()
+	fmt.Fprintf(hash, "%s\x00%s\x00%s\x00", r.Method, r.URL.Path, r.Header.Get("Content-Type"))
+	hash.Write(body)
+
+	return hex.EncodeToString(hash.Sum(nil))
+}
+
+func validIdempotencyKey(key string) bool {
+	if len(key) > maxIdempotencyKeyLength {
+		return false
+	}
+
+	for _, c := range key {
+		if c < ' ' || c > '~' {
+			return false
+		}
+	}
+
+	return key != ""
+}
+
+// recordingWriter keeps the status and a copy of the body for replays.
+type recordingWriter struct {
+	http.ResponseWriter
+
+	status int
+	body   bytes.Buffer
+}
+
+func (rw *recordingWriter) WriteHeader(status int) {
+	if rw.status == 0 {
+		rw.status = status
+	}
+
+	rw.ResponseWriter.WriteHeader(status)
+}
+
+func (rw *recordingWriter) Write(b []byte) (int, error) {
+	if rw.status == 0 {
+		rw.WriteHeader(http.StatusOK)
+	}
+
+	rw.body.Write(b)
+
+	n, err := rw.ResponseWriter.Write(b)
+	if err != nil {
+		return n, fmt.Errorf("error writing response: %w", err)
+	}
+
+	return n, nil
+}
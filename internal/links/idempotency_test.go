@@ -0,0 +1,153 @@
+package links_test
+
+import (
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/jacekdobrowolski/goshort/internal/links"
+	"github.com/jacekdobrowolski/goshort/pkg/logging"
+)
+
+func Test_idempotencyMiddleware(t *testing.T) {
+	t.Parallel()
+
+	store := newMockStore()
+	logger := slog.New(slog.NewTextHandler(io.Discard, &slog.HandlerOptions{Level: slog.LevelError}))
+	handler := links.IdempotencyMiddleware(links.HandlerCreateLink(logger, store, nil),
+		logger, store, links.DefaultIdempotencyRetention)
+
+	post := func(tenant, key, body string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(http.MethodPost, "/api/v1/links", strings.NewReader(body))
+		r.Header.Add("Content-Type", "application/json")
+
+		if key != "" {
+			r.Header.Add("Idempotency-Key", key)
+		}
+
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, asTenant(r, tenant))
+
+		return w
+	}
+
+	first := post("acme", "create-1", `{"url":"http://example.com/"}`)
+	if first.Code != http.StatusCreated {
+		t.Fatalf("expected StatusCode %d got %d", http.StatusCreated, first.Code)
+	}
+
+	retry := post("acme", "create-1", `{"url":"http://example.com/"}`)
+	if retry.Code != http.StatusCreated {
+		t.Fatalf("retry expected StatusCode %d got %d", http.StatusCreated, retry.Code)
+	}
+
+	if retry.Body.String() != first.Body.String() {
+		t.Errorf("retry expected body %s got %s", first.Body.String(), retry.Body.String())
+	}
+
+	if replayed := retry.Header().Get("Idempotent-Replayed"); replayed != "true" {
+		t.Errorf("retry expected Idempotent-Replayed true got %q", replayed)
+	}
+
+	if count := len(store.m); count != 1 {
+		t.Errorf("expected 1 link got %d", count)
+	}
+
+	tests := []struct {
+		name   string
+		tenant string
+		key    string
+		body   string
+		status int
+	}{
+		{
+			name:   "different body",
+			tenant: "acme",
+			key:    "create-1",
+			body:   `{"url":"http://example.com/other"}`,
+			status: http.StatusUnprocessableEntity,
+		},
+		{
+			name:   "key of another tenant",
+			tenant: "globex",
+			key:    "create-1",
+			body:   `{"url":"http://example.com/"}`,
+			status: http.StatusCreated,
+		},
+		{
+			name:   "invalid key",
+			tenant: "acme",
+			key:    "createé",
+			body:   `{"url":"http://example.com/"}`,
+			status: http.StatusBadRequest,
+		},
+		{
+			name:   "without key",
+			tenant: "acme",
+			body:   `{"url":"http://example.com/plain"}`,
+			status: http.StatusCreated,
+		},
+		{
+			name:   "client error is stored",
+			tenant: "acme",
+			key:    "create-2",
+			body:   `{"url":`,
+			status: http.StatusBadRequest,
+		},
+	}
+
+	for _, tt := range tests {
+		if w := post(tt.tenant, tt.key, tt.body); w.Code != tt.status {
+			t.Errorf("%s expected StatusCode %d got %d", tt.name, tt.status, w.Code)
+		}
+	}
+
+	if w := post("acme", "create-2", `{"url":`); w.Header().Get("Idempotent-Replayed") != "true" {
+		t.Errorf("expected stored client error to be replayed")
+	}
+
+	if count := len(store.m); count != 3 {
+		t.Errorf("expected 3 links got %d", count)
+	}
+}
+
+func Test_idempotencyMiddlewarePanic(t *testing.T) {
+	t.Parallel()
+
+	store := newMockStore()
+	logger := slog.New(slog.NewTextHandler(io.Discard, &slog.HandlerOptions{Level: slog.LevelError}))
+	panicking := true
+	handler := logging.RecoveryMiddleware(links.IdempotencyMiddleware(
+		http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+			if panicking {
+				panic("boom")
+			}
+
+			w.WriteHeader(http.StatusCreated)
+		}),
+		logger, store, links.DefaultIdempotencyRetention), logger)
+
+	post := func() int {
+		r := httptest.NewRequest(http.MethodPost, "/api/v1/links", strings.NewReader(`{"url":"http://example.com/"}`))
+		r.Header.Add("Idempotency-Key", "create-1")
+
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, asTenant(r, "acme"))
+
+		return w.Code
+	}
+
+	if status := post(); status != http.StatusInternalServerError {
+		t.Fatalf("expected StatusCode %d got %d", http.StatusInternalServerError, status)
+	}
+
+	panicking = false
+
+	// the key is released so the retry runs instead of waiting as in progress
+	if status := post(); status != http.StatusCreated {
+		t.Errorf("retry expected StatusCode %d got %d", http.StatusCreated, status)
+	}
+}
@@ -0,0 +1,35 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE IF NOT EXISTS tenants (
+        id text PRIMARY KEY,
+        name text NOT NULL,
+        domain text UNIQUE,
+        max_links integer,
+        created_at timestamptz NOT NULL DEFAULT now()
+    );
+
+ALTER TABLE links
+    ADD COLUMN IF NOT EXISTS tenant_id text NOT NULL DEFAULT '',
+    ADD COLUMN IF NOT EXISTS clicks bigint NOT NULL DEFAULT 0,
+    DROP CONSTRAINT links_pkey,
+    ADD PRIMARY KEY (tenant_id, short);
+
+ALTER TABLE api_keys
+    ADD COLUMN IF NOT EXISTS tenant_id text NOT NULL DEFAULT '';
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE api_keys
+    DROP COLUMN tenant_id;
+
+DELETE FROM links WHERE tenant_id <> '';
+
+ALTER TABLE links
+    DROP CONSTRAINT links_pkey,
+    ADD PRIMARY KEY (short),
+    DROP COLUMN clicks,
+    DROP COLUMN tenant_id;
+
+DROP TABLE tenants;
+-- +goose StatementEnd
@@ -0,0 +1,14 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE UNLOGGED TABLE IF NOT EXISTS rate_limits (
+        key text PRIMARY KEY,
+        tokens double precision NOT NULL,
+        allowed boolean NOT NULL,
+        updated_at timestamptz NOT NULL
+    );
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE rate_limits;
+-- +goose StatementEnd
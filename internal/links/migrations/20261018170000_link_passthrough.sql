@@ -0,0 +1,14 @@
+-- +goose Up
+-- +goose StatementBegin
+ALTER TABLE links
+    ADD COLUMN IF NOT EXISTS query_passthrough text
+        CHECK (query_passthrough IN ('merge', 'override', 'append')),
+    ADD COLUMN IF NOT EXISTS path_passthrough boolean NOT NULL DEFAULT false;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE links
+    DROP COLUMN path_passthrough,
+    DROP COLUMN query_passthrough;
+-- +goose StatementEnd
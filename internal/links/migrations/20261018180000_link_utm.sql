@@ -0,0 +1,24 @@
+-- +goose Up
+-- +goose StatementBegin
+ALTER TABLE links
+    ADD COLUMN IF NOT EXISTS utm_source text,
+    ADD COLUMN IF NOT EXISTS utm_medium text,
+    ADD COLUMN IF NOT EXISTS utm_campaign text,
+    ADD COLUMN IF NOT EXISTS utm_term text,
+    ADD COLUMN IF NOT EXISTS utm_content text;
+
+CREATE INDEX IF NOT EXISTS links_utm_campaign_idx ON links (tenant_id, utm_campaign)
+    WHERE utm_campaign IS NOT NULL;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP INDEX links_utm_campaign_idx;
+
+ALTER TABLE links
+    DROP COLUMN utm_content,
+    DROP COLUMN utm_term,
+    DROP COLUMN utm_campaign,
+    DROP COLUMN utm_medium,
+    DROP COLUMN utm_source;
+-- +goose StatementEnd
@@ -0,0 +1,16 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE IF NOT EXISTS link_country_clicks (
+        tenant_id text NOT NULL,
+        short text NOT NULL,
+        country text NOT NULL,
+        clicks bigint NOT NULL DEFAULT 0,
+        PRIMARY KEY (tenant_id, short, country),
+        FOREIGN KEY (tenant_id, short) REFERENCES links (tenant_id, short) ON DELETE CASCADE
+    );
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE link_country_clicks;
+-- +goose StatementEnd
@@ -0,0 +1,26 @@
+-- +goose Up
+-- +goose StatementBegin
+ALTER TABLE links
+    ADD COLUMN IF NOT EXISTS variants jsonb
+        CHECK (jsonb_typeof(variants) = 'array'),
+    ADD COLUMN IF NOT EXISTS variant_assignment text
+        CHECK (variant_assignment IN ('cookie', 'hash'));
+
+CREATE TABLE IF NOT EXISTS link_variant_clicks (
+        tenant_id text NOT NULL,
+        short text NOT NULL,
+        variant text NOT NULL,
+        clicks bigint NOT NULL DEFAULT 0,
+        PRIMARY KEY (tenant_id, short, variant),
+        FOREIGN KEY (tenant_id, short) REFERENCES links (tenant_id, short) ON DELETE CASCADE
+    );
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE link_variant_clicks;
+
+ALTER TABLE links
+    DROP COLUMN variant_assignment,
+    DROP COLUMN variants;
+-- +goose StatementEnd
@@ -0,0 +1,15 @@
+-- +goose Up
+-- +goose StatementBegin
+ALTER TABLE links
+    ADD COLUMN IF NOT EXISTS max_clicks integer CHECK (max_clicks > 0),
+    ADD COLUMN IF NOT EXISTS remaining_clicks integer CHECK (remaining_clicks >= 0),
+    ADD COLUMN IF NOT EXISTS exhausted_fallback text;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE links
+    DROP COLUMN exhausted_fallback,
+    DROP COLUMN remaining_clicks,
+    DROP COLUMN max_clicks;
+-- +goose StatementEnd
@@ -0,0 +1,73 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE IF NOT EXISTS domains (
+        host text PRIMARY KEY,
+        tenant_id text NOT NULL DEFAULT '',
+        base_url text NOT NULL,
+        created_at timestamptz NOT NULL DEFAULT now()
+    );
+
+INSERT INTO domains (host, tenant_id, base_url)
+    SELECT domain, id, 'https://' || domain FROM tenants WHERE domain IS NOT NULL;
+
+ALTER TABLE tenants
+    DROP COLUMN domain;
+
+ALTER TABLE link_country_clicks
+    DROP CONSTRAINT link_country_clicks_tenant_id_short_fkey;
+
+ALTER TABLE link_variant_clicks
+    DROP CONSTRAINT link_variant_clicks_tenant_id_short_fkey;
+
+ALTER TABLE links
+    ADD COLUMN IF NOT EXISTS domain text NOT NULL DEFAULT '',
+    DROP CONSTRAINT links_pkey,
+    ADD PRIMARY KEY (tenant_id, domain, short);
+
+ALTER TABLE link_country_clicks
+    ADD COLUMN IF NOT EXISTS domain text NOT NULL DEFAULT '',
+    DROP CONSTRAINT link_country_clicks_pkey,
+    ADD PRIMARY KEY (tenant_id, domain, short, country),
+    ADD FOREIGN KEY (tenant_id, domain, short) REFERENCES links (tenant_id, domain, short) ON DELETE CASCADE;
+
+ALTER TABLE link_variant_clicks
+    ADD COLUMN IF NOT EXISTS domain text NOT NULL DEFAULT '',
+    DROP CONSTRAINT link_variant_clicks_pkey,
+    ADD PRIMARY KEY (tenant_id, domain, short, variant),
+    ADD FOREIGN KEY (tenant_id, domain, short) REFERENCES links (tenant_id, domain, short) ON DELETE CASCADE;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DELETE FROM links WHERE domain <> '';
+
+ALTER TABLE link_variant_clicks
+    DROP CONSTRAINT link_variant_clicks_tenant_id_domain_short_fkey,
+    DROP CONSTRAINT link_variant_clicks_pkey,
+    DROP COLUMN domain,
+    ADD PRIMARY KEY (tenant_id, short, variant);
+
+ALTER TABLE link_country_clicks
+    DROP CONSTRAINT link_country_clicks_tenant_id_domain_short_fkey,
+    DROP CONSTRAINT link_country_clicks_pkey,
+    DROP COLUMN domain,
+    ADD PRIMARY KEY (tenant_id, short, country);
+
+ALTER TABLE links
+    DROP CONSTRAINT links_pkey,
+    DROP COLUMN domain,
+    ADD PRIMARY KEY (tenant_id, short);
+
+ALTER TABLE link_country_clicks
+    ADD FOREIGN KEY (tenant_id, short) REFERENCES links (tenant_id, short) ON DELETE CASCADE;
+
+ALTER TABLE link_variant_clicks
+    ADD FOREIGN KEY (tenant_id, short) REFERENCES links (tenant_id, short) ON DELETE CASCADE;
+
+ALTER TABLE tenants
+    ADD COLUMN IF NOT EXISTS domain text UNIQUE;
+
+UPDATE tenants SET domain = (SELECT min(host) FROM domains WHERE domains.tenant_id = tenants.id);
+
+DROP TABLE domains;
+-- +goose StatementEnd
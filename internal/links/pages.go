@@ -0,0 +1,45 @@
+package links
+
+import (
+	"embed"
+	"html/template"
+	"log/slog"
+	"net/http"
+	"time"
+)
+
+//go:embed templates/*.html
+var templateFS embed.FS
+
+func parsePages() *template.Template {
+	return template.Must(template.ParseFS(templateFS, "templates/*.html"))
+}
+
+// renderPage writes an HTML page that must not be cached since it replaces
+// a redirect.
+func renderPage(w http.ResponseWriter, r *http.Request, logger *slog.Logger,
+	pages *template.Template, status int, name string, data any,
+) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(status)
+
+	if err := pages.ExecuteTemplate(w, name, data); err != nil {
+		logger.ErrorContext(r.Context(), "error rendering page", "page", name, "err", err)
+	}
+}
+
+type blockedPage struct {
+	Short       string
+	Destination string
+	Reason      string
+}
+
+type previewPage struct {
+	ShortURL    string
+	Destination string
+	CreatedAt   time.Time
+	Clicks      int64
+	// ContinueURL follows the link past the interstitial.
+	ContinueURL string
+}
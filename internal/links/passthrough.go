@@ -0,0 +1,82 @@
+package links
+
+import (
+	"net/http"
+	"net/url"
+	"path"
+	"strings"
+)
+
+// Query passthrough modes deciding which value wins when the incoming
+// request and the destination share a query parameter.
+const (
+	// QueryMerge adds incoming parameters the destination does not set.
+	QueryMerge = "merge"
+	// QueryOverride replaces destination parameters with incoming ones.
+	QueryOverride = "override"
+	// QueryAppend keeps the values of both.
+	QueryAppend = "append"
+)
+
+// controlParams steer the redirect handler and are never forwarded.
+var controlParams = []string{"preview", "continue"} //nolint:gochecknoglobals // read only
+
+func validQueryPassthrough(mode string) bool {
+	return mode == "" || mode == QueryMerge || mode == QueryOverride || mode == QueryAppend
+}
+
+// hasTrailingPath reports whether the request continues past the short code.
+func hasTrailingPath(r *http.Request) bool {
+	return strings.Contains(strings.TrimPrefix(r.URL.EscapedPath(), "/"), "/")
+}
+
+// passthrough returns the destination for r with the trailing path and
+// query parameters forwarded as the link allows, false when r has a
+// trailing path the link does not forward.
+func passthrough(link *StoredLink, destination *url.URL, r *http.Request) (*url.URL, bool) {
+	target := *destination
+
+	if hasTrailingPath(r) {
+		if !link.PathPassthrough {
+			return nil, false
+		}
+
+		rest := r.PathValue("rest")
+		// cleaning a rooted path keeps ".." from escaping the destination path
+		cleaned := path.Clean("/" + rest)
+		if strings.HasSuffix(rest, "/") && cleaned != "/" {
+			cleaned += "/"
+		}
+
+		target.Path = strings.TrimSuffix(target.Path, "/") + cleaned
+		target.RawPath = ""
+	}
+
+	incoming := r.URL.Query()
+	for _, param := range controlParams {
+		incoming.Del(param)
+	}
+
+	if link.QueryPassthrough == "" || len(incoming) == 0 {
+		return &target, true
+	}
+
+	query := target.Query()
+
+	for key, values := range incoming {
+		switch link.QueryPassthrough {
+		case QueryMerge:
+			if !query.Has(key) {
+				query[key] = values
+			}
+		case QueryOverride:
+			query[key] = values
+		case QueryAppend:
+			query[key] = append(query[key], values...)
+		}
+	}
+
+	target.RawQuery = query.Encode()
+
+	return &target, true
+}
@@ -0,0 +1,77 @@
+package links_test
+
+import (
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/jacekdobrowolski/goshort/internal/links"
+)
+
+func Test_redirectPassthrough(t *testing.T) {
+	t.Parallel()
+
+	store := newMockStore()
+	logger := slog.New(slog.NewTextHandler(io.Discard, &slog.HandlerOptions{Level: slog.LevelError}))
+	create := links.HandlerCreateLink(logger, store, nil)
+	redirect := links.HandlerRedirect(logger, store, links.RedirectOptions{})
+
+	for _, body := range []string{
+		`{"url":"http://example.com/?a=1","alias":"plain"}`,
+		`{"url":"http://example.com/?a=1","alias":"merge","queryPassthrough":"merge"}`,
+		`{"url":"http://example.com/?a=1","alias":"override","queryPassthrough":"override"}`,
+		`{"url":"http://example.com/?a=1","alias":"append","queryPassthrough":"append"}`,
+		`{"url":"http://example.com/docs/","alias":"docs","pathPassthrough":true}`,
+	} {
+		r := httptest.NewRequest(http.MethodPost, "/api/v1/links", strings.NewReader(body))
+		r.Header.Add("Content-Type", "application/json")
+
+		w := httptest.NewRecorder()
+		create(w, r)
+
+		if w.Code != http.StatusCreated {
+			t.Fatalf("%s expected StatusCode %d got %d", body, http.StatusCreated, w.Code)
+		}
+	}
+
+	tests := []struct {
+		target   string
+		status   int
+		location string
+	}{
+		{target: "/plain?a=2&b=3", status: http.StatusTemporaryRedirect, location: "http://example.com/?a=1"},
+		{target: "/merge?a=2&b=3", status: http.StatusTemporaryRedirect, location: "http://example.com/?a=1&b=3"},
+		{target: "/override?a=2&b=3", status: http.StatusTemporaryRedirect, location: "http://example.com/?a=2&b=3"},
+		{target: "/append?a=2&b=3", status: http.StatusTemporaryRedirect, location: "http://example.com/?a=1&a=2&b=3"},
+		{target: "/merge?continue=1", status: http.StatusTemporaryRedirect, location: "http://example.com/?a=1"},
+		{
+			target:   "/docs/guide/install",
+			status:   http.StatusTemporaryRedirect,
+			location: "http://example.com/docs/guide/install",
+		},
+		{target: "/docs/guide/", status: http.StatusTemporaryRedirect, location: "http://example.com/docs/guide/"},
+		{target: "/docs/../../etc", status: http.StatusTemporaryRedirect, location: "http://example.com/docs/etc"},
+		{target: "/plain/extra", status: http.StatusNotFound, location: ""},
+	}
+
+	for _, tt := range tests {
+		r := httptest.NewRequest(http.MethodGet, tt.target, nil)
+		short, rest, _ := strings.Cut(strings.TrimPrefix(r.URL.Path, "/"), "/")
+		r.SetPathValue("short", short)
+		r.SetPathValue("rest", rest)
+
+		w := httptest.NewRecorder()
+		redirect(w, r)
+
+		if w.Code != tt.status {
+			t.Errorf("%s expected StatusCode %d got %d", tt.target, tt.status, w.Code)
+		}
+
+		if location := w.Header().Get("Location"); location != tt.location {
+			t.Errorf("%s expected Location %q got %q", tt.target, tt.location, location)
+		}
+	}
+}
@@ -0,0 +1,129 @@
+package links
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+const (
+	unlockCookiePrefix = "goshort_unlock_"
+	unlockTTL          = 15 * time.Minute
+	// bcrypt ignores input past 72 bytes
+	maxPasswordLength = 72
+)
+
+var errPasswordTooLong = errors.New("password longer than 72 bytes")
+
+type passwordPage struct {
+	Short string
+	Error string
+}
+
+func hashPassword(password string) (string, error) {
+	if len(password) > maxPasswordLength {
+		return "", errPasswordTooLong
+	}
+
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return "", fmt.Errorf("error hashing password: %w", err)
+	}
+
+	return string(hash), nil
+}
+
+// unlockSignature binds the cookie to the link and its current password so
+// changing the password invalidates issued cookies.
+func unlockSignature(secret []byte, link *StoredLink, expires int64) string {
+	mac := hmac.New(sha256.New, secret)
+	fmt.Fprintf(mac, "%s\x00%s\x00%s\x00%s\x00%d", link.Tenant, link.Domain, link.Short, link.PasswordHash, expires)
+
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+func unlockCookie(r *http.Request, secret []byte, link *StoredLink) *http.Cookie {
+	expires := time.Now().Add(unlockTTL)
+
+	return &http.Cookie{
+		Name:     unlockCookiePrefix + link.Short,
+		Value:    strconv.FormatInt(expires.Unix(), 10) + "." + unlockSignature(secret, link, expires.Unix()),
+		Path:     "/",
+		Expires:  expires,
+		MaxAge:   int(unlockTTL.Seconds()),
+		Secure:   secureOrigin(r),
+		HttpOnly: true,
+		SameSite: http.SameSiteLaxMode,
+	}
+}
+
+func unlocked(r *http.Request, secret []byte, link *StoredLink) bool {
+	cookie, err := r.Cookie(unlockCookiePrefix + link.Short)
+	if err != nil {
+		return false
+	}
+
+	expiresValue, signature, ok := strings.Cut(cookie.Value, ".")
+	if !ok {
+		return false
+	}
+
+	expires, err := strconv.ParseInt(expiresValue, 10, 64)
+	if err != nil || time.Now().Unix() > expires {
+		return false
+	}
+
+	return hmac.Equal([]byte(signature), []byte(unlockSignature(secret, link, expires)))
+}
+
+// handlePassword serves the password form of a locked link and on correct
+// submission sets the unlock cookie and sends the visitor back to the link.
+func handlePassword(w http.ResponseWriter, r *http.Request, h *redirectHandler, link *StoredLink) {
+	ctx := r.Context()
+	page := passwordPage{Short: link.Short}
+
+	if r.Method != http.MethodPost {
+		renderPage(w, r, h.logger, h.pages, http.StatusOK, "password.html", page)
+
+		return
+	}
+
+	if h.options.PasswordLimiter != nil {
+		key := "password:" + link.Tenant + "/" + link.Domain + "/" + link.Short
+
+		result, err := h.options.PasswordLimiter.Allow(ctx, key, h.options.PasswordAttempts)
+		if err != nil {
+			h.logger.ErrorContext(ctx, "error throttling password attempts", "short", link.Short, "err", err)
+		} else if !result.Allowed {
+			h.logger.WarnContext(ctx, "password attempts throttled", "short", link.Short)
+
+			page.Error = "Too many attempts, try again later."
+			w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(result.RetryAfter.Seconds()))))
+			renderPage(w, r, h.logger, h.pages, http.StatusTooManyRequests, "password.html", page)
+
+			return
+		}
+	}
+
+	err := bcrypt.CompareHashAndPassword([]byte(link.PasswordHash), []byte(r.PostFormValue("password")))
+	if err != nil {
+		h.logger.InfoContext(ctx, "incorrect link password", "short", link.Short)
+
+		page.Error = "Incorrect password."
+		renderPage(w, r, h.logger, h.pages, http.StatusForbidden, "password.html", page)
+
+		return
+	}
+
+	http.SetCookie(w, unlockCookie(r, h.options.CookieSecret, link))
+	http.Redirect(w, r, r.URL.RequestURI(), http.StatusSeeOther)
+}
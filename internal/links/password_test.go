@@ -0,0 +1,201 @@
+package links_test
+
+import (
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/jacekdobrowolski/goshort/internal/links"
+	"github.com/jacekdobrowolski/goshort/pkg/proxy"
+	"github.com/jacekdobrowolski/goshort/pkg/ratelimit"
+)
+
+func submitPassword(handler http.HandlerFunc, password string) *httptest.ResponseRecorder {
+	r := httptest.NewRequest(http.MethodPost, "http://goshort.test/doc",
+		strings.NewReader(url.Values{"password": {password}}.Encode()))
+	r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	r.SetPathValue("short", "doc")
+
+	w := httptest.NewRecorder()
+	handler(w, r)
+
+	return w
+}
+
+func Test_redirectPassword(t *testing.T) {
+	t.Parallel()
+
+	store := newMockStore()
+	logger := slog.New(slog.NewTextHandler(io.Discard, &slog.HandlerOptions{Level: slog.LevelError}))
+	redirect := links.HandlerRedirect(logger, store, links.RedirectOptions{
+		CookieSecret:     []byte("secret"),
+		PasswordLimiter:  ratelimit.NewMemoryLimiter(),
+		PasswordAttempts: ratelimit.Limit{Rate: 0.01, Burst: 2},
+	})
+
+	r := httptest.NewRequest(http.MethodPost, "/api/v1/links",
+		strings.NewReader(`{"url":"http://example.com/private","alias":"doc","password":"hunter2"}`))
+	r.Header.Add("Content-Type", "application/json")
+
+	w := httptest.NewRecorder()
+	links.HandlerCreateLink(logger, store, nil)(w, r)
+
+	if w.Code != http.StatusCreated {
+		t.Fatalf("expected StatusCode %d got %d", http.StatusCreated, w.Code)
+	}
+
+	stored, _ := store.GetLink(r.Context(), "", "", "doc")
+	if stored.PasswordHash == "" || strings.Contains(stored.PasswordHash, "hunter2") {
+		t.Fatalf("expected hashed password got %q", stored.PasswordHash)
+	}
+
+	get := func(cookies ...*http.Cookie) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(http.MethodGet, "http://goshort.test/doc", nil)
+		r.SetPathValue("short", "doc")
+
+		for _, cookie := range cookies {
+			r.AddCookie(cookie)
+		}
+
+		w := httptest.NewRecorder()
+		redirect(w, r)
+
+		return w
+	}
+
+	if w := get(); w.Code != http.StatusOK || !strings.Contains(w.Body.String(), `<form method="post">`) {
+		t.Fatalf("expected password form got %d %s", w.Code, w.Body.String())
+	}
+
+	if w := submitPassword(redirect, "wrong"); w.Code != http.StatusForbidden {
+		t.Errorf("wrong password expected StatusCode %d got %d", http.StatusForbidden, w.Code)
+	}
+
+	w = submitPassword(redirect, "hunter2")
+	if w.Code != http.StatusSeeOther {
+		t.Fatalf("correct password expected StatusCode %d got %d", http.StatusSeeOther, w.Code)
+	}
+
+	cookies := w.Result().Cookies()
+	if len(cookies) != 1 || !cookies[0].HttpOnly {
+		t.Fatalf("expected http only unlock cookie got %v", cookies)
+	}
+
+	if w := get(cookies[0]); w.Code != http.StatusTemporaryRedirect {
+		t.Errorf("unlocked expected StatusCode %d got %d", http.StatusTemporaryRedirect, w.Code)
+	}
+
+	forged := *cookies[0]
+	forged.Value = strings.Replace(forged.Value, ".", "0.", 1)
+
+	if w := get(&forged); w.Code != http.StatusOK {
+		t.Errorf("forged cookie expected password form got %d", w.Code)
+	}
+
+	if w := submitPassword(redirect, "hunter2"); w.Code != http.StatusTooManyRequests {
+		t.Errorf("throttled expected StatusCode %d got %d", http.StatusTooManyRequests, w.Code)
+	}
+}
+
+func Test_redirectPasswordPermanent(t *testing.T) {
+	t.Parallel()
+
+	store := newMockStore()
+	logger := slog.New(slog.NewTextHandler(io.Discard, &slog.HandlerOptions{Level: slog.LevelError}))
+	redirect := links.HandlerRedirect(logger, store, links.RedirectOptions{
+		CookieSecret:     []byte("secret"),
+		PasswordLimiter:  ratelimit.NewMemoryLimiter(),
+		PasswordAttempts: ratelimit.Limit{Rate: 1, Burst: 1},
+	})
+
+	r := httptest.NewRequest(http.MethodPost, "/api/v1/links", strings.NewReader(
+		`{"url":"http://example.com/private","alias":"doc","password":"hunter2","redirectStatus":308}`))
+	r.Header.Add("Content-Type", "application/json")
+
+	w := httptest.NewRecorder()
+	links.HandlerCreateLink(logger, store, nil)(w, r)
+
+	if w.Code != http.StatusCreated {
+		t.Fatalf("expected StatusCode %d got %d", http.StatusCreated, w.Code)
+	}
+
+	w = submitPassword(redirect, "hunter2")
+	if w.Code != http.StatusSeeOther {
+		t.Fatalf("correct password expected StatusCode %d got %d", http.StatusSeeOther, w.Code)
+	}
+
+	r = httptest.NewRequest(http.MethodGet, "http://goshort.test/doc", nil)
+	r.SetPathValue("short", "doc")
+	r.AddCookie(w.Result().Cookies()[0])
+
+	w = httptest.NewRecorder()
+	redirect(w, r)
+
+	if w.Code != http.StatusPermanentRedirect {
+		t.Errorf("unlocked expected StatusCode %d got %d", http.StatusPermanentRedirect, w.Code)
+	}
+
+	// shared caches would serve the destination to visitors without the cookie
+	if cacheControl := w.Header().Get("Cache-Control"); cacheControl != "private, no-store" {
+		t.Errorf("expected Cache-Control %q got %q", "private, no-store", cacheControl)
+	}
+}
+
+func Test_unlockCookieBehindProxy(t *testing.T) {
+	t.Parallel()
+
+	store := newMockStore()
+	logger := slog.New(slog.NewTextHandler(io.Discard, &slog.HandlerOptions{Level: slog.LevelError}))
+	redirect := links.HandlerRedirect(logger, store, links.RedirectOptions{
+		CookieSecret:     []byte("secret"),
+		PasswordLimiter:  ratelimit.NewMemoryLimiter(),
+		PasswordAttempts: ratelimit.Limit{Rate: 1, Burst: 5},
+	})
+
+	r := httptest.NewRequest(http.MethodPost, "/api/v1/links",
+		strings.NewReader(`{"url":"http://example.com/private","alias":"doc","password":"hunter2"}`))
+	r.Header.Add("Content-Type", "application/json")
+
+	w := httptest.NewRecorder()
+	links.HandlerCreateLink(logger, store, nil)(w, r)
+
+	if w.Code != http.StatusCreated {
+		t.Fatalf("expected StatusCode %d got %d", http.StatusCreated, w.Code)
+	}
+
+	trusted, err := proxy.ParseTrusted("10.0.0.0/8")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		remoteAddr string
+		secure     bool
+	}{
+		{remoteAddr: "10.0.0.1:1234", secure: true},
+		{remoteAddr: "192.0.2.1:1234", secure: false},
+	}
+
+	for _, tt := range tests {
+		handler := links.PublicURLMiddleware(redirect, "", trusted)
+
+		r := httptest.NewRequest(http.MethodPost, "http://goshort.test/doc",
+			strings.NewReader(url.Values{"password": {"hunter2"}}.Encode()))
+		r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		r.Header.Set("X-Forwarded-Proto", "https")
+		r.RemoteAddr = tt.remoteAddr
+		r.SetPathValue("short", "doc")
+
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, r)
+
+		cookies := w.Result().Cookies()
+		if len(cookies) != 1 || cookies[0].Secure != tt.secure {
+			t.Errorf("%s expected Secure %t unlock cookie got %v", tt.remoteAddr, tt.secure, cookies)
+		}
+	}
+}
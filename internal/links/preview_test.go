@@ -0,0 +1,101 @@
+package links_test
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/jacekdobrowolski/goshort/internal/links"
+)
+
+func Test_redirectPreview(t *testing.T) {
+	t.Parallel()
+
+	store := newMockStore()
+	logger := slog.New(slog.NewTextHandler(io.Discard, &slog.HandlerOptions{Level: slog.LevelError}))
+	redirect := links.HandlerRedirect(logger, store, links.RedirectOptions{})
+	create := links.HandlerCreateLink(logger, store, nil)
+
+	if status := createAlias(t, create, "", "docs", "http://example.com/docs"); status != http.StatusCreated {
+		t.Fatalf("expected StatusCode %d got %d", http.StatusCreated, status)
+	}
+
+	for _, target := range []string{"http://goshort.test/docs+", "http://goshort.test/docs?preview=1"} {
+		r := httptest.NewRequest(http.MethodGet, target, nil)
+		r.SetPathValue("short", strings.TrimPrefix(r.URL.Path, "/"))
+
+		w := httptest.NewRecorder()
+		redirect(w, r)
+
+		if w.Code != http.StatusOK {
+			t.Fatalf("%s expected StatusCode %d got %d", target, http.StatusOK, w.Code)
+		}
+
+		if contentType := w.Header().Get("Content-Type"); !strings.HasPrefix(contentType, "text/html") {
+			t.Errorf("%s expected html got %q", target, contentType)
+		}
+
+		body := w.Body.String()
+		if !strings.Contains(body, "http://example.com/docs") || !strings.Contains(body, "<dd>0</dd>") {
+			t.Errorf("%s expected destination and click count in preview got %s", target, body)
+		}
+	}
+
+	stored, _ := store.GetLink(context.Background(), "", "", "docs")
+	if stored.Clicks != 0 {
+		t.Errorf("expected preview not to count clicks got %d", stored.Clicks)
+	}
+}
+
+func Test_redirectInterstitial(t *testing.T) {
+	t.Parallel()
+
+	store := newMockStore()
+	logger := slog.New(slog.NewTextHandler(io.Discard, &slog.HandlerOptions{Level: slog.LevelError}))
+	redirect := links.HandlerRedirect(logger, store, links.RedirectOptions{})
+
+	for alias, target := range map[string]string{
+		"external": "http://example.com/",
+		"internal": "http://goshort.test/about",
+	} {
+		r := httptest.NewRequest(http.MethodPost, "/api/v1/links",
+			strings.NewReader(`{"url":"`+target+`","alias":"`+alias+`","interstitial":true}`))
+		r.Header.Add("Content-Type", "application/json")
+
+		w := httptest.NewRecorder()
+		links.HandlerCreateLink(logger, store, nil)(w, r)
+
+		if w.Code != http.StatusCreated {
+			t.Fatalf("expected StatusCode %d got %d", http.StatusCreated, w.Code)
+		}
+	}
+
+	tests := []struct {
+		target string
+		status int
+	}{
+		{target: "http://goshort.test/external", status: http.StatusOK},
+		{target: "http://goshort.test/external?continue=1", status: http.StatusTemporaryRedirect},
+		{target: "http://goshort.test/internal", status: http.StatusTemporaryRedirect},
+	}
+
+	for _, tt := range tests {
+		r := httptest.NewRequest(http.MethodGet, tt.target, nil)
+		r.SetPathValue("short", strings.TrimPrefix(r.URL.Path, "/"))
+
+		w := httptest.NewRecorder()
+		redirect(w, r)
+
+		if w.Code != tt.status {
+			t.Errorf("%s expected StatusCode %d got %d", tt.target, tt.status, w.Code)
+		}
+
+		if tt.status == http.StatusOK && !strings.Contains(w.Body.String(), `href="/external?continue=1"`) {
+			t.Errorf("expected continue link got %s", w.Body.String())
+		}
+	}
+}
@@ -0,0 +1,80 @@
+package links
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/jacekdobrowolski/goshort/pkg/proxy"
+)
+
+var errBaseURL = errors.New("base URL must be an absolute http or https URL without path, query or fragment")
+
+type (
+	publicBaseURLKey struct{}
+	originSchemeKey  struct{}
+)
+
+// ParseBaseURL validates an absolute http or https URL short codes are
+// appended to, the trailing slash is removed. Paths are rejected since
+// redirects are only served on /{short}.
+func ParseBaseURL(baseURL string) (string, error) {
+	parsed, err := url.Parse(baseURL)
+	if err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Host == "" ||
+		parsed.User != nil || (parsed.Path != "" && parsed.Path != "/") || parsed.RawQuery != "" ||
+		parsed.Fragment != "" {
+		return "", fmt.Errorf("%w: %q", errBaseURL, baseURL)
+	}
+
+	return strings.TrimSuffix(parsed.String(), "/"), nil
+}
+
+// PublicURLMiddleware sets the base of short URLs built while handling the
+// request, baseURL when configured or the origin the client used resolved
+// through trusted proxies. The origin scheme decides whether cookies are
+// Secure.
+func PublicURLMiddleware(next http.Handler, baseURL string, trusted proxy.Trusted) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		scheme, host := trusted.Origin(r)
+
+		base := baseURL
+		if base == "" {
+			base = scheme + "://" + host
+		}
+
+		ctx := context.WithValue(r.Context(), publicBaseURLKey{}, base)
+		ctx = context.WithValue(ctx, originSchemeKey{}, scheme)
+
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// secureOrigin reports whether the client used https, also when TLS ends at
+// a trusted proxy.
+func secureOrigin(r *http.Request) bool {
+	scheme, ok := r.Context().Value(originSchemeKey{}).(string)
+	if !ok {
+		scheme, _ = proxy.Trusted{}.Origin(r)
+	}
+
+	return scheme == "https"
+}
+
+// shortURL prefixes the short code with the base URL of the link domain,
+// links without one use the public base URL of the request.
+func shortURL(r *http.Request, link *StoredLink) string {
+	if link.BaseURL != "" {
+		return link.BaseURL + "/" + link.Short
+	}
+
+	base, ok := r.Context().Value(publicBaseURLKey{}).(string)
+	if !ok {
+		scheme, host := proxy.Trusted{}.Origin(r)
+		base = scheme + "://" + host
+	}
+
+	return base + "/" + link.Short
+}
@@ -0,0 +1,119 @@
+package links_test
+
+import (
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/jacekdobrowolski/goshort/internal/links"
+	"github.com/jacekdobrowolski/goshort/pkg/proxy"
+)
+
+func Test_publicURL(t *testing.T) {
+	t.Parallel()
+
+	store := newMockStore()
+	logger := slog.New(slog.NewTextHandler(io.Discard, &slog.HandlerOptions{Level: slog.LevelError}))
+
+	create := links.HandlerCreateLink(logger, store, nil)
+
+	if status := createAlias(t, create, "", "docs", "http://example.com/docs"); status != http.StatusCreated {
+		t.Fatalf("expected StatusCode %d got %d", http.StatusCreated, status)
+	}
+
+	trusted, err := proxy.ParseTrusted("10.0.0.0/8")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	get := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		r.SetPathValue("short", "docs")
+		links.HandlerGetLink(logger, store)(w, r)
+	})
+
+	tests := []struct {
+		name       string
+		baseURL    string
+		remoteAddr string
+		family     proxy.Header
+		header     http.Header
+		want       string
+	}{
+		{
+			name:       "request host",
+			remoteAddr: "192.0.2.1:1234",
+			want:       "http://goshort.test/docs",
+		},
+		{
+			name:       "configured base URL",
+			baseURL:    "https://sho.rt",
+			remoteAddr: "10.0.0.1:1234",
+			header:     http.Header{"X-Forwarded-Host": {"evil.test"}},
+			want:       "https://sho.rt/docs",
+		},
+		{
+			name:       "untrusted forwarding headers",
+			remoteAddr: "192.0.2.1:1234",
+			header:     http.Header{"X-Forwarded-Proto": {"https"}, "X-Forwarded-Host": {"evil.test"}},
+			want:       "http://goshort.test/docs",
+		},
+		{
+			name:       "trusted x-forwarded headers",
+			remoteAddr: "10.0.0.1:1234",
+			header:     http.Header{"X-Forwarded-Proto": {"https"}, "X-Forwarded-Host": {"evil.test, sho.rt"}},
+			want:       "https://sho.rt/docs",
+		},
+		{
+			name:       "trusted forwarded header",
+			remoteAddr: "10.0.0.1:1234",
+			family:     proxy.HeaderForwarded,
+			header: http.Header{"Forwarded": {
+				`for=198.51.100.1;proto=https;host="sho.rt", for=10.0.0.2;proto=http;host=internal`,
+			}},
+			want: "https://sho.rt/docs",
+		},
+		{
+			name:       "invalid forwarded host",
+			remoteAddr: "10.0.0.1:1234",
+			header:     http.Header{"X-Forwarded-Proto": {"ftp"}, "X-Forwarded-Host": {"sho.rt/path"}},
+			want:       "http://goshort.test/docs",
+		},
+	}
+
+	for _, tt := range tests {
+		r := httptest.NewRequest(http.MethodGet, "http://goshort.test/api/v1/links/docs", nil)
+		r.RemoteAddr = tt.remoteAddr
+
+		for key, values := range tt.header {
+			r.Header[key] = values
+		}
+
+		w := httptest.NewRecorder()
+		trusted.Header = tt.family
+		links.PublicURLMiddleware(get, tt.baseURL, trusted).ServeHTTP(w, r)
+
+		link := links.Link{}
+		if err := json.NewDecoder(w.Body).Decode(&link); err != nil {
+			t.Fatal(err)
+		}
+
+		if link.Short != tt.want {
+			t.Errorf("%s expected short %s got %s", tt.name, tt.want, link.Short)
+		}
+	}
+
+	r := httptest.NewRequest(http.MethodGet, "http://goshort.test/docs+", nil)
+	r.SetPathValue("short", "docs+")
+
+	w := httptest.NewRecorder()
+	redirect := links.HandlerRedirect(logger, store, links.RedirectOptions{})
+	links.PublicURLMiddleware(redirect, "https://sho.rt", proxy.Trusted{}).ServeHTTP(w, r)
+
+	if body := w.Body.String(); !strings.Contains(body, "https://sho.rt/docs") {
+		t.Errorf("expected canonical short URL in preview got %s", body)
+	}
+}
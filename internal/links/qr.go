@@ -0,0 +1,197 @@
+package links
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"image/color"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strconv"
+
+	"github.com/jacekdobrowolski/goshort/pkg/qr"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+)
+
+const (
+	qrDefaultSize   = 256
+	qrMinSize       = 64
+	qrMaxSize       = 2048
+	qrDefaultMargin = 4
+	qrMaxMargin     = 16
+
+	qrFormatPNG = "png"
+	qrFormatSVG = "svg"
+)
+
+var (
+	errQRFormat = errors.New("format must be png or svg")
+	errQRSize   = errors.New("size must be between 64 and 2048")
+	errQRMargin = errors.New("margin must be between 0 and 16")
+)
+
+type qrRequest struct {
+	format  string
+	level   qr.Level
+	options qr.Options
+}
+
+func parseQRRequest(query url.Values) (qrRequest, error) {
+	req := qrRequest{
+		format: qrFormatPNG,
+		level:  qr.LevelM,
+		options: qr.Options{
+			Size:       qrDefaultSize,
+			Margin:     qrDefaultMargin,
+			Foreground: color.NRGBA{A: 0xff},
+			Background: color.NRGBA{R: 0xff, G: 0xff, B: 0xff, A: 0xff},
+		},
+	}
+
+	var err error
+
+	if format := query.Get("format"); format != "" {
+		if format != qrFormatPNG && format != qrFormatSVG {
+			return req, errQRFormat
+		}
+
+		req.format = format
+	}
+
+	if size := query.Get("size"); size != "" {
+		req.options.Size, err = strconv.Atoi(size)
+		if err != nil || req.options.Size < qrMinSize || req.options.Size > qrMaxSize {
+			return req, errQRSize
+		}
+	}
+
+	if margin := query.Get("margin"); margin != "" {
+		req.options.Margin, err = strconv.Atoi(margin)
+		if err != nil || req.options.Margin < 0 || req.options.Margin > qrMaxMargin {
+			return req, errQRMargin
+		}
+	}
+
+	if level := query.Get("level"); level != "" {
+		if req.level, err = qr.ParseLevel(level); err != nil {
+			return req, fmt.Errorf("level: %w", err)
+		}
+	}
+
+	if fg := query.Get("fg"); fg != "" {
+		if req.options.Foreground, err = qr.ParseColor(fg); err != nil {
+			return req, fmt.Errorf("fg: %w", err)
+		}
+	}
+
+	if bg := query.Get("bg"); bg != "" {
+		if req.options.Background, err = qr.ParseColor(bg); err != nil {
+			return req, fmt.Errorf("bg: %w", err)
+		}
+	}
+
+	return req, nil
+}
+
+// etag identifies the rendered image, it only changes with the encoded
+// content or the rendering parameters.
+func (req qrRequest) etag(content string) string {
+	sum := sha256.Sum256(fmt.Appendf(nil, "%s\x00%s\x00%s\x00%d\x00%d\x00%v\x00%v", content, req.format, req.level,
+		req.options.Size, req.options.Margin, req.options.Foreground, req.options.Background))
+
+	return `"` + hex.EncodeToString(sum[:16]) + `"`
+}
+
+// HandlerLinkQR renders the short URL of a link as a PNG or SVG QR code.
+func HandlerLinkQR(logger *slog.Logger, store Store) http.HandlerFunc {
+	tracer := otel.Tracer("handlerlinkqr")
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx, span := tracer.Start(r.Context(), "link_qr")
+		defer span.End()
+
+		req, err := parseQRRequest(r.URL.Query())
+		if err != nil {
+			logger.InfoContext(ctx, "invalid qr parameters", "err", err)
+			span.SetStatus(codes.Error, "invalid qr parameters")
+			writeError(w, http.StatusBadRequest, err.Error())
+
+			return
+		}
+
+		tenant, _ := callerTenant(ctx)
+
+		link, err := store.GetLink(ctx, tenant, linkDomain(r), r.PathValue("short"))
+		if err != nil {
+			logger.InfoContext(ctx, "unknown link", "short", r.PathValue("short"))
+			span.RecordError(err)
+			span.SetStatus(codes.Error, "unknown link")
+
+			w.WriteHeader(statusForStoreError(err))
+
+			return
+		}
+
+		content := linkResponse(r, link).Short
+		etag := req.etag(content)
+
+		if r.Header.Get("If-None-Match") == etag {
+			setQRCacheHeaders(w, etag)
+			w.WriteHeader(http.StatusNotModified)
+
+			return
+		}
+
+		code, err := qr.New(content, req.level)
+		if err != nil {
+			logger.ErrorContext(ctx, "error encoding qr code", "short", link.Short, "err", err)
+			span.RecordError(err)
+			span.SetStatus(codes.Error, "error encoding qr code")
+			writeError(w, http.StatusInternalServerError, "error encoding qr code")
+
+			return
+		}
+
+		var buf bytes.Buffer
+
+		contentType := "image/png"
+		if req.format == qrFormatSVG {
+			contentType = "image/svg+xml"
+			err = code.SVG(&buf, req.options)
+		} else {
+			err = code.PNG(&buf, req.options)
+		}
+
+		if err != nil {
+			// the code does not fit the requested size with its margin
+			logger.InfoContext(ctx, "error rendering qr code", "short", link.Short, "err", err)
+			span.RecordError(err)
+			span.SetStatus(codes.Error, "error rendering qr code")
+			writeError(w, http.StatusBadRequest, err.Error())
+
+			return
+		}
+
+		setQRCacheHeaders(w, etag)
+		w.Header().Set("Content-Type", contentType)
+		w.Header().Set("Content-Length", strconv.Itoa(buf.Len()))
+		w.WriteHeader(http.StatusOK)
+
+		if _, err := buf.WriteTo(w); err != nil {
+			logger.ErrorContext(ctx, "error writing qr code", "err", err)
+			span.RecordError(err)
+		}
+	}
+}
+
+// setQRCacheHeaders lets only the client keep the image for a while, the
+// response belongs to a tenant and the link can be deleted or moved to
+// another domain. Later requests revalidate with the ETag.
+func setQRCacheHeaders(w http.ResponseWriter, etag string) {
+	w.Header().Set("Cache-Control", "private, max-age=3600")
+	w.Header().Set("ETag", etag)
+}
@@ -0,0 +1,110 @@
+package links_test
+
+import (
+	"image/png"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/jacekdobrowolski/goshort/internal/links"
+)
+
+func Test_handlerLinkQR(t *testing.T) {
+	t.Parallel()
+
+	store := newMockStore()
+	logger := slog.New(slog.NewTextHandler(io.Discard, &slog.HandlerOptions{Level: slog.LevelError}))
+	create := links.HandlerCreateLink(logger, store, nil)
+	handler := links.HandlerLinkQR(logger, store)
+
+	r := httptest.NewRequest(http.MethodPost, "/api/v1/links",
+		strings.NewReader(`{"url":"http://example.com/poster","alias":"poster"}`))
+	r.Header.Add("Content-Type", "application/json")
+
+	w := httptest.NewRecorder()
+	create(w, r)
+
+	if w.Code != http.StatusCreated {
+		t.Fatalf("expected StatusCode %d got %d", http.StatusCreated, w.Code)
+	}
+
+	get := func(short, query, etag string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(http.MethodGet, "/api/v1/links/"+short+"/qr?"+query, nil)
+		r.SetPathValue("short", short)
+
+		if etag != "" {
+			r.Header.Set("If-None-Match", etag)
+		}
+
+		w := httptest.NewRecorder()
+		handler(w, r)
+
+		return w
+	}
+
+	tests := []struct {
+		short       string
+		query       string
+		status      int
+		contentType string
+	}{
+		{short: "poster", query: "", status: http.StatusOK, contentType: "image/png"},
+		{
+			short:       "poster",
+			query:       "format=svg&level=h&fg=%23336699&bg=ffffff00",
+			status:      http.StatusOK,
+			contentType: "image/svg+xml",
+		},
+		{short: "poster", query: "size=64&margin=0&level=L", status: http.StatusOK, contentType: "image/png"},
+		{short: "poster", query: "format=gif", status: http.StatusBadRequest, contentType: "application/json"},
+		{short: "poster", query: "size=10000", status: http.StatusBadRequest, contentType: "application/json"},
+		{short: "poster", query: "margin=-1", status: http.StatusBadRequest, contentType: "application/json"},
+		{short: "poster", query: "level=X", status: http.StatusBadRequest, contentType: "application/json"},
+		{short: "poster", query: "fg=blue", status: http.StatusBadRequest, contentType: "application/json"},
+		{short: "missing", query: "", status: http.StatusNotFound, contentType: ""},
+	}
+
+	for _, tt := range tests {
+		w := get(tt.short, tt.query, "")
+
+		if w.Code != tt.status {
+			t.Errorf("%s?%s expected StatusCode %d got %d", tt.short, tt.query, tt.status, w.Code)
+		}
+
+		if contentType := w.Header().Get("Content-Type"); contentType != tt.contentType {
+			t.Errorf("%s?%s expected Content-Type %q got %q", tt.short, tt.query, tt.contentType, contentType)
+		}
+
+		if cached := w.Header().Get("ETag") != ""; cached != (tt.status == http.StatusOK) {
+			t.Errorf("%s?%s expected ETag only on success got %q", tt.short, tt.query, w.Header().Get("ETag"))
+		}
+	}
+
+	w = get("poster", "size=300", "")
+
+	img, err := png.Decode(w.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if bounds := img.Bounds(); bounds.Dx() != 300 || bounds.Dy() != 300 {
+		t.Errorf("expected 300x300 image got %v", bounds)
+	}
+
+	if cacheControl := w.Header().Get("Cache-Control"); cacheControl != "private, max-age=3600" {
+		t.Errorf("expected private Cache-Control got %q", cacheControl)
+	}
+
+	etag := w.Header().Get("ETag")
+
+	if w := get("poster", "size=300", etag); w.Code != http.StatusNotModified || w.Body.Len() != 0 {
+		t.Errorf("expected StatusCode %d with empty body got %d", http.StatusNotModified, w.Code)
+	}
+
+	if w := get("poster", "size=301", etag); w.Code != http.StatusOK {
+		t.Errorf("expected different size to change the ETag got StatusCode %d", w.Code)
+	}
+}
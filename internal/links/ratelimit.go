@@ -0,0 +1,62 @@
+package links
+
+import (
+	"log/slog"
+	"net/http"
+
+	"github.com/jacekdobrowolski/goshort/internal/auth"
+	"github.com/jacekdobrowolski/goshort/pkg/proxy"
+	"github.com/jacekdobrowolski/goshort/pkg/ratelimit"
+)
+
+// Route groups sharing a rate limit.
+const (
+	RouteGroupCreate   = "create"
+	RouteGroupAPI      = "api"
+	RouteGroupRedirect = "redirect"
+)
+
+// RateLimits applies per route group limits, a nil RateLimits or a group
+// without a limit is not limited.
+type RateLimits struct {
+	Logger  *slog.Logger
+	Limiter ratelimit.Limiter
+	Limits  map[string]ratelimit.Limit
+	Trusted proxy.Trusted
+}
+
+func newRateLimits(logger *slog.Logger, cfg Config, limiter ratelimit.Limiter) *RateLimits {
+	if len(cfg.RateLimits) == 0 {
+		return nil
+	}
+
+	return &RateLimits{
+		Logger:  logger,
+		Limiter: limiter,
+		Limits:  cfg.RateLimits,
+		Trusted: cfg.TrustedProxies,
+	}
+}
+
+func (rl *RateLimits) wrap(group string, handler http.Handler) http.Handler {
+	if rl == nil {
+		return handler
+	}
+
+	limit, ok := rl.Limits[group]
+	if !ok {
+		return handler
+	}
+
+	return ratelimit.Middleware(handler, rl.Logger, rl.Limiter, group, limit, rl.clientKey)
+}
+
+// clientKey identifies authenticated callers by principal and anonymous
+// callers by client IP.
+func (rl *RateLimits) clientKey(r *http.Request) string {
+	if principal, ok := auth.PrincipalFromContext(r.Context()); ok {
+		return "principal:" + principal.ID
+	}
+
+	return "ip:" + rl.Trusted.ClientIP(r).String()
+}
@@ -0,0 +1,367 @@
+package links
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"html/template"
+	"log/slog"
+	"net/http"
+	"net/netip"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jacekdobrowolski/goshort/pkg/geoip"
+	"github.com/jacekdobrowolski/goshort/pkg/proxy"
+	"github.com/jacekdobrowolski/goshort/pkg/ratelimit"
+	"github.com/jacekdobrowolski/goshort/pkg/urlpolicy"
+	"github.com/jacekdobrowolski/goshort/pkg/useragent"
+	"go.opentelemetry.io/otel"
+)
+
+// previewSuffix appended to a short code shows the preview page, it cannot
+// clash with aliases which only allow letters, digits, '-' and '_'.
+const previewSuffix = "+"
+
+// RedirectOptions configures HandlerRedirect, the zero value serves plain
+// redirects.
+type RedirectOptions struct {
+	// Blocklist flags destinations of existing links, optional.
+	Blocklist urlpolicy.Blocklist
+	// CookieSecret signs cookies of unlocked password protected links.
+	CookieSecret []byte
+	// PasswordLimiter throttles password submissions per link, optional.
+	PasswordLimiter  ratelimit.Limiter
+	PasswordAttempts ratelimit.Limit
+	// DefaultStatus applies to links without their own redirect status,
+	// 307 when 0.
+	DefaultStatus int
+	// PermanentMaxAge is how long clients may cache 301 and 308 redirects.
+	PermanentMaxAge time.Duration
+	// Locator resolves client addresses for country targets and click
+	// analytics, optional.
+	Locator Locator
+	// TrustedProxies whose forwarding headers are used to resolve client IP.
+	TrustedProxies proxy.Trusted
+}
+
+// Locator is implemented by *geoip.DB.
+type Locator interface {
+	Locate(addr netip.Addr) (geoip.Location, error)
+}
+
+// ValidRedirectStatus reports whether status can be used for link redirects.
+func ValidRedirectStatus(status int) bool {
+	switch status {
+	case http.StatusMovedPermanently, http.StatusFound, http.StatusTemporaryRedirect, http.StatusPermanentRedirect:
+		return true
+	default:
+		return false
+	}
+}
+
+// redirectStatus picks the link status and matching Cache-Control, clients
+// may cache permanent redirects while temporary ones must reach the service
+// so every click is counted.
+func (h *redirectHandler) redirectStatus(link *StoredLink) (int, string) {
+	status := link.RedirectStatus
+	if status == 0 {
+		status = h.options.DefaultStatus
+	}
+
+	// only visitors who unlocked the link may see where it goes
+	if link.PasswordHash != "" {
+		if !ValidRedirectStatus(status) {
+			status = http.StatusTemporaryRedirect
+		}
+
+		return status, "private, no-store"
+	}
+
+	// scheduled destinations and click limits change without the link changing
+	if link.Schedule != nil || link.MaxClicks > 0 {
+		if !ValidRedirectStatus(status) {
+			status = http.StatusTemporaryRedirect
+		}
+
+		return status, "private, no-cache"
+	}
+
+	switch status {
+	case http.StatusMovedPermanently, http.StatusPermanentRedirect:
+		maxAge := "max-age=" + strconv.Itoa(int(h.options.PermanentMaxAge.Seconds()))
+		// shared caches cannot tell visitors from different countries or
+		// variants apart
+		if link.Targets.located() || len(link.Variants) > 0 {
+			return status, "private, " + maxAge
+		}
+
+		return status, "public, " + maxAge
+	case http.StatusFound:
+		return status, "private, no-cache"
+	default:
+		return http.StatusTemporaryRedirect, "private, no-cache"
+	}
+}
+
+type redirectHandler struct {
+	logger  *slog.Logger
+	pages   *template.Template
+	options RedirectOptions
+}
+
+// destination picks where the visitor goes and the assigned variant. Outside
+// the activation window it is the schedule fallback, otherwise the first
+// matching target, a variant, the current schedule entry or the link
+// destination in that order. False when the link is inactive and has no
+// fallback.
+func (h *redirectHandler) destination(
+	w http.ResponseWriter,
+	r *http.Request,
+	link *StoredLink,
+	v visitor,
+	now time.Time,
+) (string, string, bool) {
+	if link.Schedule != nil && !link.Schedule.active(now) {
+		return link.Schedule.Fallback, "", link.Schedule.Fallback != ""
+	}
+
+	if destination, ok := link.Targets.destination(v); ok {
+		return destination, "", true
+	}
+
+	if len(link.Variants) > 0 {
+		variant := h.variant(w, r, link, v)
+
+		return variant.URL, variant.Name, true
+	}
+
+	if link.Schedule != nil {
+		if destination, ok := link.Schedule.destination(now); ok {
+			return destination, "", true
+		}
+	}
+
+	return link.Original, "", true
+}
+
+// visitor parses the User-Agent and locates the client IP when a Locator
+// is configured, lookup failures leave the location unknown.
+func (h *redirectHandler) visitor(r *http.Request) visitor {
+	v := visitor{
+		agent: useragent.Parse(r.UserAgent()),
+		addr:  h.options.TrustedProxies.ClientIP(r),
+	}
+
+	if h.options.Locator == nil || !v.addr.IsValid() {
+		return v
+	}
+
+	location, err := h.options.Locator.Locate(v.addr)
+	if err != nil {
+		h.logger.DebugContext(r.Context(), "error locating client", "err", err)
+
+		return v
+	}
+
+	v.location = location
+
+	return v
+}
+
+// resolveLink finds short on host, links bound to the host domain take
+// precedence over links of its tenant served on every host. Unregistered
+// hosts serve the default tenant.
+func resolveLink(ctx context.Context, store Store, host, short string) (*StoredLink, error) {
+	var tenant, bound string
+
+	domain, err := store.GetDomain(ctx, host)
+
+	switch {
+	case err == nil:
+		tenant, bound = domain.Tenant, domain.Host
+	case !errors.Is(err, ErrDomainNotFound):
+		return nil, fmt.Errorf("error resolving domain: %w", err)
+	}
+
+	link, err := store.GetLink(ctx, tenant, bound, short)
+	if bound != "" && errors.Is(err, ErrLinkNotFound) {
+		link, err = store.GetLink(ctx, tenant, "", short)
+	}
+
+	if err != nil {
+		return nil, fmt.Errorf("error resolving link: %w", err)
+	}
+
+	return link, nil
+}
+
+// HandlerRedirect redirects to the link destination, links flagged by the
+// blocklist are disabled and show an interstitial instead.
+//
+// GET /{short}/{rest...} forwards the trailing path and query parameters
+// to links allowing passthrough. GET /{short}+ or ?preview=1 shows the destination without following it,
+// links with an interstitial ask for a click-through before leaving for
+// another domain and password protected links ask for the password first.
+// Link targets pick the destination by the visitor User-Agent and country,
+// variants split the remaining visitors by weight and schedules change the
+// destination over time. Links with a click limit stop redirecting once no
+// clicks remain.
+func HandlerRedirect(logger *slog.Logger, store Store, options RedirectOptions) http.HandlerFunc {
+	h := &redirectHandler{
+		logger:  logger,
+		pages:   parsePages(),
+		options: options,
+	}
+
+	meter := otel.Meter("handler_redir")
+
+	histogram, err := meter.Int64Histogram("handler_redir_hist")
+	if err != nil {
+		logger.Error("error creating meter", slog.String("err", err.Error()))
+	}
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+
+		defer func() {
+			histogram.Record(r.Context(), time.Since(start).Milliseconds())
+		}()
+
+		ctx := r.Context()
+
+		short, preview := strings.CutSuffix(r.PathValue("short"), previewSuffix)
+		preview = preview || r.URL.Query().Get("preview") == "1"
+
+		link, err := resolveLink(ctx, store, r.Host, short)
+		if err != nil {
+			logger.InfoContext(ctx, "unknown link", "host", r.Host, "short", short, "err", err)
+
+			w.WriteHeader(statusForStoreError(err))
+
+			return
+		}
+
+		if link.PasswordHash != "" && !unlocked(r, options.CookieSecret, link) {
+			handlePassword(w, r, h, link)
+
+			return
+		}
+
+		if r.Method == http.MethodPost {
+			http.Redirect(w, r, r.URL.RequestURI(), http.StatusSeeOther)
+
+			return
+		}
+
+		if link.MaxClicks > 0 && link.RemainingClicks == 0 {
+			exhausted(w, r, h, link)
+
+			return
+		}
+
+		visitor := h.visitor(r)
+		click := Click{Country: visitor.location.Country}
+
+		rawDestination, variant, active := h.destination(w, r, link, visitor, time.Now())
+		if !active {
+			logger.InfoContext(ctx, "link outside activation window", "short", link.Short)
+
+			w.WriteHeader(http.StatusNotFound)
+
+			return
+		}
+
+		click.Variant = variant
+
+		destination, err := url.Parse(rawDestination)
+		if err != nil {
+			logger.ErrorContext(ctx, "invalid stored destination", "short", link.Short, "err", err)
+
+			w.WriteHeader(http.StatusInternalServerError)
+
+			return
+		}
+
+		target, ok := passthrough(link, destination, r)
+		if !ok {
+			logger.InfoContext(ctx, "trailing path not forwarded", "short", link.Short)
+
+			w.WriteHeader(http.StatusNotFound)
+
+			return
+		}
+
+		if link.DisabledReason == "" && options.Blocklist != nil {
+			if list, blocked := options.Blocklist.Blocked(destination); blocked {
+				logger.WarnContext(ctx, "disabling blocklisted link", "short", link.Short, "list", list)
+
+				link.DisabledReason = list
+				if err := store.DisableLink(ctx, link.Tenant, link.Domain, link.Short, list); err != nil {
+					logger.ErrorContext(ctx, "error disabling link", "short", link.Short, "err", err)
+				}
+			}
+		}
+
+		if link.DisabledReason != "" {
+			renderPage(w, r, logger, h.pages, http.StatusForbidden, "blocked.html", blockedPage{
+				Short:       link.Short,
+				Destination: destination.String(),
+				Reason:      link.DisabledReason,
+			})
+
+			return
+		}
+
+		continueQuery := r.URL.Query()
+		continueQuery.Set("continue", "1")
+
+		page := previewPage{
+			ShortURL:    shortURL(r, link),
+			Destination: target.String(),
+			CreatedAt:   link.CreatedAt,
+			Clicks:      link.Clicks,
+			ContinueURL: r.URL.EscapedPath() + "?" + continueQuery.Encode(),
+		}
+
+		if preview {
+			renderPage(w, r, logger, h.pages, http.StatusOK, "preview.html", page)
+
+			return
+		}
+
+		if link.Interstitial && r.URL.Query().Get("continue") != "1" &&
+			normalizeHost(target.Host) != normalizeHost(r.Host) {
+			renderPage(w, r, logger, h.pages, http.StatusOK, "interstitial.html", page)
+
+			return
+		}
+
+		// HEAD from link unfurlers and mail scanners must not use up clicks
+		if r.Method == http.MethodGet {
+			err = store.RecordClick(ctx, link.Tenant, link.Domain, link.Short, click)
+			if errors.Is(err, ErrClicksExhausted) {
+				// another request took the last click since the link was read
+				exhausted(w, r, h, link)
+
+				return
+			}
+
+			if err != nil {
+				logger.ErrorContext(ctx, "error recording click", "short", link.Short, "err", err)
+			}
+		}
+
+		status, cacheControl := h.redirectStatus(link)
+
+		w.Header().Set("Cache-Control", cacheControl)
+
+		if len(link.Targets) > 0 {
+			w.Header().Set("Vary", "User-Agent")
+		}
+
+		http.Redirect(w, r, target.String(), status)
+	}
+}
@@ -0,0 +1,68 @@
+package links_test
+
+import (
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jacekdobrowolski/goshort/internal/links"
+)
+
+func Test_redirectStatus(t *testing.T) {
+	t.Parallel()
+
+	store := newMockStore()
+	logger := slog.New(slog.NewTextHandler(io.Discard, &slog.HandlerOptions{Level: slog.LevelError}))
+	create := links.HandlerCreateLink(logger, store, nil)
+	redirect := links.HandlerRedirect(logger, store, links.RedirectOptions{
+		DefaultStatus:   http.StatusFound,
+		PermanentMaxAge: time.Hour,
+	})
+
+	for body, status := range map[string]int{
+		`{"url":"http://example.com/","alias":"default"}`:                        http.StatusCreated,
+		`{"url":"http://example.com/","alias":"moved","redirectStatus":301}`:     http.StatusCreated,
+		`{"url":"http://example.com/","alias":"temporary","redirectStatus":307}`: http.StatusCreated,
+		`{"url":"http://example.com/","alias":"invalid","redirectStatus":303}`:   http.StatusBadRequest,
+	} {
+		r := httptest.NewRequest(http.MethodPost, "/api/v1/links", strings.NewReader(body))
+		r.Header.Add("Content-Type", "application/json")
+
+		w := httptest.NewRecorder()
+		create(w, r)
+
+		if w.Code != status {
+			t.Fatalf("%s expected StatusCode %d got %d", body, status, w.Code)
+		}
+	}
+
+	tests := []struct {
+		short        string
+		status       int
+		cacheControl string
+	}{
+		{short: "default", status: http.StatusFound, cacheControl: "private, no-cache"},
+		{short: "moved", status: http.StatusMovedPermanently, cacheControl: "public, max-age=3600"},
+		{short: "temporary", status: http.StatusTemporaryRedirect, cacheControl: "private, no-cache"},
+	}
+
+	for _, tt := range tests {
+		r := httptest.NewRequest(http.MethodGet, "/"+tt.short, nil)
+		r.SetPathValue("short", tt.short)
+
+		w := httptest.NewRecorder()
+		redirect(w, r)
+
+		if w.Code != tt.status {
+			t.Errorf("%s expected StatusCode %d got %d", tt.short, tt.status, w.Code)
+		}
+
+		if cacheControl := w.Header().Get("Cache-Control"); cacheControl != tt.cacheControl {
+			t.Errorf("%s expected Cache-Control %q got %q", tt.short, tt.cacheControl, cacheControl)
+		}
+	}
+}
@@ -12,22 +12,69 @@ import (
 	"log/slog"
 	"net/http"
 	"net/url"
-	"path"
 	"time"
 
+	"github.com/jacekdobrowolski/goshort/internal/auth"
 	"github.com/jacekdobrowolski/goshort/pkg/base62"
+	"github.com/jacekdobrowolski/goshort/pkg/logging"
+	"github.com/jacekdobrowolski/goshort/pkg/ratelimit"
+	"github.com/jacekdobrowolski/goshort/pkg/urlpolicy"
 	"go.opentelemetry.io/otel"
 	"go.opentelemetry.io/otel/codes"
 	"go.opentelemetry.io/otel/trace"
 )
 
-var errMissingURLField = errors.New("missing URL field")
+const maxAliasLength = 64
+
+var (
+	errMissingURLField  = errors.New("missing URL field")
+	errInvalidAlias     = errors.New("alias must be 1 to 64 letters, digits, '-' or '_'")
+	errRedirectStatus   = errors.New("redirectStatus must be 301, 302, 307 or 308")
+	errQueryPassthrough = errors.New("queryPassthrough must be merge, override or append")
+)
+
+func addRoutes(
+	mux *http.ServeMux,
+	logger *slog.Logger,
+	store Store,
+	keys IdempotencyStore,
+	cfg Config,
+	limiter ratelimit.Limiter,
+) {
+	limits := newRateLimits(logger, cfg, limiter)
+	redirect := limits.wrap(RouteGroupRedirect, HandlerRedirect(logger, store, RedirectOptions{
+		Blocklist:        cfg.URLPolicy.Blocklist,
+		CookieSecret:     cfg.CookieSecret,
+		DefaultStatus:    cfg.RedirectStatus,
+		PermanentMaxAge:  cfg.PermanentRedirectMaxAge,
+		PasswordLimiter:  limiter,
+		PasswordAttempts: cfg.PasswordAttempts,
+		Locator:          cfg.Locator,
+		TrustedProxies:   cfg.TrustedProxies,
+	}))
 
-func addRoutes(mux *http.ServeMux, logger *slog.Logger, store Store) {
 	mux.HandleFunc("GET /readyz", handleReadyz)
-	mux.HandleFunc("GET /api/v1/links/{short}", HandlerGetLink(logger, store))
-	mux.HandleFunc("POST /api/v1/links", HandlerCreateLink(logger, store))
-	mux.HandleFunc("GET /{short}", HandlerRedirect(logger, store))
+	mux.Handle("GET /api/v1/links", limits.wrap(RouteGroupAPI,
+		auth.Require(auth.ScopeRead, HandlerListLinks(logger, store))))
+	mux.Handle("GET /api/v1/links/{short}", limits.wrap(RouteGroupAPI,
+		auth.Require(auth.ScopeRead, HandlerGetLink(logger, store))))
+	mux.Handle("GET /api/v1/links/{short}/stats", limits.wrap(RouteGroupAPI,
+		auth.Require(auth.ScopeStats, HandlerLinkStats(logger, store))))
+	mux.Handle("GET /api/v1/links/{short}/qr", limits.wrap(RouteGroupAPI,
+		auth.Require(auth.ScopeRead, HandlerLinkQR(logger, store))))
+	mux.Handle("GET /api/v1/campaigns", limits.wrap(RouteGroupAPI,
+		auth.Require(auth.ScopeStats, HandlerCampaignStats(logger, store))))
+	mux.Handle("POST /api/v1/links", limits.wrap(RouteGroupCreate,
+		auth.Require(auth.ScopeCreate, IdempotencyMiddleware(HandlerCreateLink(logger, store, &cfg.URLPolicy),
+			logger, keys, cfg.IdempotencyRetention))))
+	mux.Handle("PATCH /api/v1/links/{short}", limits.wrap(RouteGroupCreate,
+		auth.Require(auth.ScopeCreate, HandlerUpdateLink(logger, store, &cfg.URLPolicy))))
+	mux.Handle("DELETE /api/v1/links/{short}", limits.wrap(RouteGroupAPI,
+		auth.Require(auth.ScopeDelete, HandlerDeleteLink(logger, store))))
+	mux.Handle("GET /{short}", redirect)
+	mux.Handle("GET /{short}/{rest...}", redirect)
+	// password form submissions of protected links
+	mux.Handle("POST /{short}", redirect)
 }
 
 func handleReadyz(w http.ResponseWriter, _ *http.Request) {
@@ -35,8 +82,121 @@ func handleReadyz(w http.ResponseWriter, _ *http.Request) {
 }
 
 type Link struct {
-	Short    string `json:"short"`
+	Short string `json:"short"`
+	// Domain is the host the link is bound to, empty when served on every
+	// host of the tenant.
+	Domain   string `json:"domain,omitempty"`
 	Original string `json:"original"`
+	Owner    string `json:"owner,omitempty"`
+	// DisabledReason names the blocklist that flagged the destination.
+	DisabledReason string `json:"disabledReason,omitempty"`
+	Interstitial   bool   `json:"interstitial,omitempty"`
+	// PasswordProtected links ask for a password before redirecting.
+	PasswordProtected bool `json:"passwordProtected,omitempty"`
+	RedirectStatus    int  `json:"redirectStatus,omitempty"`
+	// QueryPassthrough is merge, override or append, empty drops the incoming query.
+	QueryPassthrough string `json:"queryPassthrough,omitempty"`
+	PathPassthrough  bool   `json:"pathPassthrough,omitempty"`
+	UTM              *UTM   `json:"utm,omitempty"`
+	// Targets redirect matching devices elsewhere, Original is the default.
+	Targets           TargetRules `json:"targets,omitempty"`
+	Variants          Variants    `json:"variants,omitempty"`
+	VariantAssignment string      `json:"variantAssignment,omitempty"`
+	Schedule          *Schedule   `json:"schedule,omitempty"`
+	MaxClicks         int         `json:"maxClicks,omitempty"`
+	// RemainingClicks is only set for links with MaxClicks.
+	RemainingClicks   *int   `json:"remainingClicks,omitempty"`
+	ExhaustedFallback string `json:"exhaustedFallback,omitempty"`
+}
+
+func linkResponse(r *http.Request, stored *StoredLink) Link {
+	var remaining *int
+	if stored.MaxClicks > 0 {
+		remaining = &stored.RemainingClicks
+	}
+
+	return Link{
+		Short:             shortURL(r, stored),
+		Domain:            stored.Domain,
+		Original:          stored.Original,
+		Owner:             stored.Owner,
+		DisabledReason:    stored.DisabledReason,
+		Interstitial:      stored.Interstitial,
+		PasswordProtected: stored.PasswordHash != "",
+		RedirectStatus:    stored.RedirectStatus,
+		QueryPassthrough:  stored.QueryPassthrough,
+		PathPassthrough:   stored.PathPassthrough,
+		UTM:               stored.UTM.orNil(),
+		Targets:           stored.Targets,
+		Variants:          stored.Variants,
+		VariantAssignment: stored.VariantAssignment,
+		Schedule:          stored.Schedule,
+		MaxClicks:         stored.MaxClicks,
+		RemainingClicks:   remaining,
+		ExhaustedFallback: stored.ExhaustedFallback,
+	}
+}
+
+// linkDomain is the domain of the link addressed by an API request, empty
+// for links not bound to a domain.
+func linkDomain(r *http.Request) string {
+	return normalizeHost(r.URL.Query().Get("domain"))
+}
+
+type errorResponse struct {
+	Error string `json:"error"`
+}
+
+func writeError(w http.ResponseWriter, status int, message string) {
+	_ = WriteJSON(w, status, errorResponse{Error: message})
+}
+
+func statusForStoreError(err error) int {
+	if errors.Is(err, ErrLinkNotFound) {
+		return http.StatusNotFound
+	}
+
+	return http.StatusInternalServerError
+}
+
+// callerTenant returns the tenant and ID of the authenticated principal,
+// unauthenticated callers use the default tenant.
+func callerTenant(ctx context.Context) (string, string) {
+	principal, ok := auth.PrincipalFromContext(ctx)
+	if !ok {
+		return "", ""
+	}
+
+	return principal.Tenant, principal.ID
+}
+
+// checkDestination validates the destination against policy, a nil policy
+// only requires a parsable URL.
+func checkDestination(ctx context.Context, policy *urlpolicy.Policy, destination string) error {
+	if policy == nil {
+		if _, err := url.ParseRequestURI(destination); err != nil {
+			return &urlpolicy.Violation{Reason: "invalid url"}
+		}
+
+		return nil
+	}
+
+	return policy.Check(ctx, destination) //nolint:wrapcheck // violation reason is returned to the client
+}
+
+func validAlias(alias string) bool {
+	// reserved paths shadow the redirect route
+	if len(alias) > maxAliasLength || alias == "readyz" || alias == "api" {
+		return false
+	}
+
+	for _, c := range alias {
+		if !('a' <= c && c <= 'z' || 'A' <= c && c <= 'Z' || '0' <= c && c <= '9' || c == '-' || c == '_') {
+			return false
+		}
+	}
+
+	return alias != ""
 }
 
 func WriteJSON(w http.ResponseWriter, status int, v any) error {
@@ -51,7 +211,130 @@ func WriteJSON(w http.ResponseWriter, status int, v any) error {
 	return nil
 }
 
-func HandlerCreateLink(logger *slog.Logger, store Store) http.HandlerFunc {
+// createLinkRequest is the body of POST /api/v1/links.
+type createLinkRequest struct {
+	URL string `json:"url"`
+	// Domain binds the link to a registered domain of the tenant.
+	Domain string `json:"domain"`
+	// Alias is a custom short code, generated from the URL hash when empty.
+	Alias string `json:"alias"`
+	// Interstitial asks visitors to confirm before leaving for another domain.
+	Interstitial bool `json:"interstitial"`
+	// Password protects the redirect, only its hash is stored.
+	Password string `json:"password"`
+	// RedirectStatus is 301, 302, 307 or 308, the service default when 0.
+	RedirectStatus int `json:"redirectStatus"`
+	// QueryPassthrough forwards incoming query parameters, merge, override or append.
+	QueryPassthrough string `json:"queryPassthrough"`
+	// PathPassthrough forwards /{short}/extra/path to the destination path.
+	PathPassthrough bool `json:"pathPassthrough"`
+	// UTM parameters are merged into URL and every other destination.
+	UTM *UTM `json:"utm"`
+	// Targets are ordered rules picking another URL by visitor device.
+	Targets TargetRules `json:"targets"`
+	// Variants split visitors matching no target by weight.
+	Variants Variants `json:"variants"`
+	// VariantAssignment keeps visitors on their variant, cookie or hash.
+	VariantAssignment string `json:"variantAssignment"`
+	// Schedule changes the destination over time and limits when the link is active.
+	Schedule *Schedule `json:"schedule"`
+	// MaxClicks limits how many times the link redirects, 0 is unlimited.
+	MaxClicks int `json:"maxClicks"`
+	// ExhaustedFallback is where visitors go once no clicks remain, 410 when empty.
+	ExhaustedFallback string `json:"exhaustedFallback"`
+}
+
+// applyUTM validates the UTM parameters and merges them into every
+// destination, before the destinations are checked against the policy.
+func (req *createLinkRequest) applyUTM() error {
+	if req.UTM == nil {
+		return nil
+	}
+
+	if err := req.UTM.validate(); err != nil {
+		return err
+	}
+
+	destinations := []*string{&req.URL, &req.ExhaustedFallback}
+	for i := range req.Targets {
+		destinations = append(destinations, &req.Targets[i].URL)
+	}
+
+	for i := range req.Variants {
+		destinations = append(destinations, &req.Variants[i].URL)
+	}
+
+	if req.Schedule != nil {
+		destinations = append(destinations, &req.Schedule.Fallback)
+		for i := range req.Schedule.Entries {
+			destinations = append(destinations, &req.Schedule.Entries[i].URL)
+		}
+	}
+
+	if err := req.UTM.applyAll(destinations...); err != nil {
+		return &urlpolicy.Violation{Reason: "invalid url"}
+	}
+
+	return nil
+}
+
+// validate checks every setting of the request, the error is returned to
+// the client.
+func (req *createLinkRequest) validate(ctx context.Context, policy *urlpolicy.Policy) error {
+	if req.URL == "" {
+		return errMissingURLField
+	}
+
+	if err := checkDestination(ctx, policy, req.URL); err != nil {
+		return err
+	}
+
+	if err := req.Targets.validate(ctx, policy); err != nil {
+		return err
+	}
+
+	if err := req.Variants.validate(ctx, policy); err != nil {
+		return err
+	}
+
+	if !validVariantAssignment(req.VariantAssignment) {
+		return errVariantAssignment
+	}
+
+	if req.Schedule != nil {
+		if err := req.Schedule.validate(ctx, policy); err != nil {
+			return err
+		}
+	}
+
+	if err := validateClickLimit(ctx, policy, req.MaxClicks, req.ExhaustedFallback); err != nil {
+		return err
+	}
+
+	if req.Alias != "" && !validAlias(req.Alias) {
+		return errInvalidAlias
+	}
+
+	if req.RedirectStatus != 0 && !ValidRedirectStatus(req.RedirectStatus) {
+		return errRedirectStatus
+	}
+
+	if !validQueryPassthrough(req.QueryPassthrough) {
+		return errQueryPassthrough
+	}
+
+	return nil
+}
+
+// customized reports settings beyond the URL, creating a link with them
+// again conflicts with the link of the same URL hash.
+func (req *createLinkRequest) customized() bool {
+	return req.Alias != "" || req.Password != "" || len(req.Targets) > 0 || len(req.Variants) > 0 ||
+		req.Schedule != nil || req.MaxClicks > 0 || req.Interstitial || req.RedirectStatus != 0 ||
+		req.QueryPassthrough != "" || req.PathPassthrough || req.ExhaustedFallback != ""
+}
+
+func HandlerCreateLink(logger *slog.Logger, store Store, policy *urlpolicy.Policy) http.HandlerFunc {
 	tracer := otel.Tracer("handlercreatelink")
 
 	meter := otel.Meter("handler_create")
@@ -71,13 +354,9 @@ func HandlerCreateLink(logger *slog.Logger, store Store) http.HandlerFunc {
 			histogram.Record(ctx, time.Since(start).Milliseconds())
 		}()
 
-		logger = logger.With(
-			slog.String("trace_id", span.SpanContext().TraceID().String()),
-		)
-
 		contentType, ok := r.Header["Content-Type"]
 		if !ok {
-			logger.Debug("no Content-Type header")
+			logger.DebugContext(ctx, "no Content-Type header")
 			span.SetStatus(codes.Error, "missing content-type header")
 
 			w.WriteHeader(http.StatusBadRequest)
@@ -85,12 +364,8 @@ func HandlerCreateLink(logger *slog.Logger, store Store) http.HandlerFunc {
 			return
 		}
 
-		requestBody := struct {
-			URL string `json:"url"`
-		}{}
-
 		if contentType[0] != "application/json" {
-			logger.Debug("unexpected content-type", "type", contentType)
+			logger.DebugContext(ctx, "unexpected content-type", "type", contentType)
 			span.SetStatus(codes.Error, "unexpected content-type")
 
 			w.WriteHeader(http.StatusBadRequest)
@@ -98,9 +373,11 @@ func HandlerCreateLink(logger *slog.Logger, store Store) http.HandlerFunc {
 			return
 		}
 
+		var requestBody createLinkRequest
+
 		decoder := json.NewDecoder(r.Body)
 		if err := decoder.Decode(&requestBody); err != nil {
-			logger.Debug("error parsing json request body no url field")
+			logger.DebugContext(ctx, "error parsing json request body no url field")
 			span.RecordError(err)
 			span.SetStatus(codes.Error, "error parsing json request body no url field")
 
@@ -109,47 +386,143 @@ func HandlerCreateLink(logger *slog.Logger, store Store) http.HandlerFunc {
 			return
 		}
 
-		if len(requestBody.URL) == 0 {
-			logger.Debug("error parsing json request body empty url")
-			span.RecordError(errMissingURLField)
-			span.SetStatus(codes.Error, "error parsing json request body empty url")
+		if err := requestBody.applyUTM(); err != nil {
+			logger.DebugContext(ctx, "invalid utm", "err", err)
+			span.SetStatus(codes.Error, "invalid utm")
 
-			w.WriteHeader(http.StatusBadRequest)
+			writeError(w, http.StatusBadRequest, err.Error())
 
 			return
 		}
 
-		if _, err := url.ParseRequestURI(requestBody.URL); err != nil {
-			logger.Debug("error request body contains invalid url")
-			span.SetStatus(codes.Error, "invalid url")
+		if err := requestBody.validate(ctx, policy); err != nil {
+			logger.DebugContext(ctx, "invalid link", "err", err)
+			span.SetStatus(codes.Error, "invalid link")
 			span.RecordError(err)
 
-			w.WriteHeader(http.StatusBadRequest)
+			writeError(w, http.StatusBadRequest, err.Error())
 
 			return
 		}
 
-		short, err := generateHash(ctx, requestBody.URL, logger, tracer)
-		if err != nil {
-			w.WriteHeader(http.StatusInternalServerError)
+		short := requestBody.Alias
+		if short == "" {
+			short, err = generateHash(ctx, requestBody.URL, logger, tracer)
+			if err != nil {
+				w.WriteHeader(http.StatusInternalServerError)
 
-			return
+				return
+			}
+		}
+
+		var passwordHash string
+
+		if requestBody.Password != "" {
+			passwordHash, err = hashPassword(requestBody.Password)
+			if err != nil {
+				logger.DebugContext(ctx, "invalid password", "err", err)
+				span.SetStatus(codes.Error, "invalid password")
+
+				writeError(w, http.StatusBadRequest, err.Error())
+
+				return
+			}
+		}
+
+		tenant, owner := callerTenant(ctx)
+
+		var domain Domain
+
+		if requestBody.Domain != "" {
+			registered, err := store.GetDomain(ctx, requestBody.Domain)
+
+			switch {
+			case errors.Is(err, ErrDomainNotFound) || err == nil && registered.Tenant != tenant:
+				logger.DebugContext(ctx, "unknown domain", "domain", requestBody.Domain)
+				span.SetStatus(codes.Error, "unknown domain")
+
+				writeError(w, http.StatusBadRequest, "unknown domain")
+
+				return
+			case err != nil:
+				logger.ErrorContext(ctx, "error reading domain", "err", err)
+				span.SetStatus(codes.Error, "error reading domain")
+				span.RecordError(err)
+
+				w.WriteHeader(http.StatusInternalServerError)
+
+				return
+			}
+
+			domain = *registered
+		}
+
+		var utm UTM
+		if requestBody.UTM != nil {
+			utm = *requestBody.UTM
+		}
+
+		stored := &StoredLink{
+			Tenant:            tenant,
+			Domain:            domain.Host,
+			Short:             short,
+			Original:          requestBody.URL,
+			Owner:             owner,
+			Interstitial:      requestBody.Interstitial,
+			PasswordHash:      passwordHash,
+			RedirectStatus:    requestBody.RedirectStatus,
+			QueryPassthrough:  requestBody.QueryPassthrough,
+			PathPassthrough:   requestBody.PathPassthrough,
+			UTM:               utm,
+			Targets:           requestBody.Targets,
+			Variants:          requestBody.Variants,
+			VariantAssignment: requestBody.VariantAssignment,
+			Schedule:          requestBody.Schedule,
+			MaxClicks:         requestBody.MaxClicks,
+			RemainingClicks:   requestBody.MaxClicks,
+			ExhaustedFallback: requestBody.ExhaustedFallback,
+			BaseURL:           domain.BaseURL,
 		}
 
-		if err := store.AddLink(ctx, short, requestBody.URL); err != nil {
-			logger.Error("error adding row into db", "err", err)
+		err = store.AddLink(ctx, *stored)
+
+		switch {
+		case errors.Is(err, ErrShortExists):
+			existing, getErr := store.GetLink(ctx, tenant, domain.Host, short)
+			// the same URL hashes to the same short so creating it again is not a
+			// conflict, unless the link has settings beyond the URL
+			if requestBody.customized() || getErr != nil || existing.Original != requestBody.URL {
+				logger.InfoContext(ctx, "short already exists", "short", short)
+				span.SetStatus(codes.Error, "short already exists")
+
+				writeError(w, http.StatusConflict, "short already exists")
+
+				return
+			}
+
+			stored = existing
+		case errors.Is(err, ErrQuotaExceeded):
+			logger.InfoContext(ctx, "tenant link quota exceeded", "tenant", tenant)
+			span.SetStatus(codes.Error, "quota exceeded")
+
+			writeError(w, http.StatusForbidden, "link quota exceeded")
+
+			return
+		case err != nil:
+			logger.ErrorContext(ctx, "error adding row into db", "err", err)
 			span.SetStatus(codes.Error, "error adding row")
 			span.RecordError(err)
-		}
 
-		link := Link{
-			Short:    "http://" + path.Join(r.Host, short),
-			Original: requestBody.URL,
+			w.WriteHeader(http.StatusInternalServerError)
+
+			return
 		}
 
+		link := linkResponse(r, stored)
+
 		err = WriteJSON(w, http.StatusCreated, link)
 		if err != nil {
-			logger.Error("error writing JSON response", "err", err)
+			logger.ErrorContext(ctx, "error writing JSON response", "err", err)
 			span.SetStatus(codes.Error, "error writing JSON")
 			span.RecordError(err)
 
@@ -161,20 +534,17 @@ func HandlerCreateLink(logger *slog.Logger, store Store) http.HandlerFunc {
 }
 
 func generateHash(ctx context.Context, url string, logger *slog.Logger, tracer trace.Tracer) (string, error) {
-	_, span := tracer.Start(ctx, "generating_hash")
+	ctx, span := tracer.Start(ctx, "generating_hash")
 	defer span.End()
 
-	logger = logger.With(
-		slog.String("trace_id", span.SpanContext().TraceID().String()),
-		slog.String("url", url),
-	)
+	ctx = logging.WithAttrs(ctx, slog.String("url", url))
 
 	//nolint: gosec // md5 is fine for link shortening but probably slower than it could be
 	h := md5.New()
 
 	_, err := io.WriteString(h, url)
 	if err != nil {
-		logger.Error("error writing to hash", "err", err)
+		logger.ErrorContext(ctx, "error writing to hash", "err", err)
 		span.SetStatus(codes.Error, "error writing to hash")
 		span.RecordError(err)
 
@@ -186,7 +556,7 @@ func generateHash(ctx context.Context, url string, logger *slog.Logger, tracer t
 
 	var x uint32
 	if err := binary.Read(reader, binary.LittleEndian, &x); err != nil {
-		logger.Error("error reading bytes", "err", err)
+		logger.ErrorContext(ctx, "error reading bytes", "err", err)
 		span.RecordError(err)
 
 		return "", fmt.Errorf("error reading bytes: %w", err)
@@ -194,7 +564,7 @@ func generateHash(ctx context.Context, url string, logger *slog.Logger, tracer t
 
 	short := base62.Encode(uint64(x))
 
-	logger.Debug("hash generated",
+	logger.DebugContext(ctx, "hash generated",
 		slog.String("hash", short),
 	)
 
@@ -221,29 +591,24 @@ func HandlerGetLink(logger *slog.Logger, store Store) http.HandlerFunc {
 			histogram.Record(ctx, time.Since(start).Milliseconds())
 		}()
 
-		logger = logger.With(
-			slog.String("trace_id", span.SpanContext().TraceID().String()),
-		)
+		tenant, _ := callerTenant(ctx)
 
-		original, err := store.GetOriginal(ctx, r.PathValue("short"))
+		stored, err := store.GetLink(ctx, tenant, linkDomain(r), r.PathValue("short"))
 		if err != nil {
-			logger.Info("unknown link", "short", r.PathValue("short"))
+			logger.InfoContext(ctx, "unknown link", "short", r.PathValue("short"))
 			span.RecordError(err)
 			span.SetStatus(codes.Error, "unknown link")
 
-			w.WriteHeader(http.StatusNotFound)
+			w.WriteHeader(statusForStoreError(err))
 
 			return
 		}
 
-		link := Link{
-			Short:    "http://" + path.Join(r.Host, r.PathValue("short")),
-			Original: *original,
-		}
+		link := linkResponse(r, stored)
 
 		err = WriteJSON(w, http.StatusOK, link)
 		if err != nil {
-			logger.Error("error writing JSON response", "err", err)
+			logger.ErrorContext(ctx, "error writing JSON response", "err", err)
 			span.RecordError(err)
 			span.SetStatus(codes.Error, "error writing JSON response")
 
@@ -253,31 +618,3 @@ func HandlerGetLink(logger *slog.Logger, store Store) http.HandlerFunc {
 		}
 	}
 }
-
-func HandlerRedirect(logger *slog.Logger, store Store) http.HandlerFunc {
-	meter := otel.Meter("handler_redir")
-
-	histogram, err := meter.Int64Histogram("handler_redir_hist")
-	if err != nil {
-		logger.Error("error creating meter", slog.String("err", err.Error()))
-	}
-
-	return func(w http.ResponseWriter, r *http.Request) {
-		start := time.Now()
-
-		defer func() {
-			histogram.Record(r.Context(), time.Since(start).Milliseconds())
-		}()
-
-		original, err := store.GetOriginal(r.Context(), r.PathValue("short"))
-		if err != nil {
-			logger.Info("unknown link", "short", r.PathValue("short"))
-
-			w.WriteHeader(http.StatusNotFound)
-
-			return
-		}
-
-		http.Redirect(w, r, *original, http.StatusTemporaryRedirect)
-	}
-}
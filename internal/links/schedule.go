@@ -0,0 +1,180 @@
+package links
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/jacekdobrowolski/goshort/pkg/urlpolicy"
+)
+
+const maxScheduleEntries = 32
+
+// scheduleLayouts are wall clock times in the schedule time zone, RFC 3339
+// times carry their own offset.
+//
+//nolint:gochecknoglobals // read only
+var scheduleLayouts = []string{time.RFC3339, "2006-01-02T15:04:05", "2006-01-02T15:04"}
+
+var (
+	errScheduleTimeZone = errors.New("unknown time zone")
+	errScheduleTime     = errors.New("expected RFC 3339 or 2006-01-02T15:04 time")
+	errScheduleEntries  = errors.New("at most 32 schedule entries are allowed")
+	errScheduleOrder    = errors.New("schedule entries must start in increasing order")
+	errScheduleWindow   = errors.New("activeUntil must be after activeFrom")
+	errScheduleEmpty    = errors.New("schedule needs entries or an activation window")
+)
+
+// ScheduleEntry makes URL the link destination from Start until the next
+// entry starts.
+type ScheduleEntry struct {
+	// Start is an RFC 3339 time or a wall clock time such as
+	// "2026-11-20T09:00" in the schedule time zone.
+	Start string `json:"start"`
+	URL   string `json:"url"`
+}
+
+// Schedule changes the link destination over time. Wall clock times are
+// resolved in TimeZone on every redirect so daylight saving changes move
+// the boundaries with local time. Schedules are stored as JSON.
+type Schedule struct {
+	// TimeZone is an IANA name such as "Europe/Warsaw", UTC when empty.
+	TimeZone string          `json:"timeZone,omitempty"`
+	Entries  []ScheduleEntry `json:"entries,omitempty"`
+	// ActiveFrom and ActiveUntil bound when the link redirects, either may
+	// be empty for an open window.
+	ActiveFrom  string `json:"activeFrom,omitempty"`
+	ActiveUntil string `json:"activeUntil,omitempty"`
+	// Fallback is where visitors go outside the window, 404 when empty.
+	Fallback string `json:"fallback,omitempty"`
+}
+
+func (s *Schedule) location() (*time.Location, error) {
+	location, err := time.LoadLocation(s.TimeZone)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s", errScheduleTimeZone, s.TimeZone)
+	}
+
+	return location, nil
+}
+
+func parseScheduleTime(value string, location *time.Location) (time.Time, error) {
+	for _, layout := range scheduleLayouts {
+		if parsed, err := time.ParseInLocation(layout, value, location); err == nil {
+			return parsed, nil
+		}
+	}
+
+	return time.Time{}, fmt.Errorf("%w: %q", errScheduleTime, value)
+}
+
+func (s *Schedule) validate(ctx context.Context, policy *urlpolicy.Policy) error {
+	location, err := s.location()
+	if err != nil {
+		return err
+	}
+
+	if len(s.Entries) == 0 && s.ActiveFrom == "" && s.ActiveUntil == "" {
+		return errScheduleEmpty
+	}
+
+	if len(s.Entries) > maxScheduleEntries {
+		return errScheduleEntries
+	}
+
+	var previous time.Time
+
+	for i, entry := range s.Entries {
+		start, err := parseScheduleTime(entry.Start, location)
+		if err == nil && i > 0 && !start.After(previous) {
+			err = errScheduleOrder
+		}
+
+		if err == nil {
+			err = checkDestination(ctx, policy, entry.URL)
+		}
+
+		if err != nil {
+			return fmt.Errorf("schedule entries[%d]: %w", i, err)
+		}
+
+		previous = start
+	}
+
+	var from, until time.Time
+
+	if s.ActiveFrom != "" {
+		if from, err = parseScheduleTime(s.ActiveFrom, location); err != nil {
+			return fmt.Errorf("activeFrom: %w", err)
+		}
+	}
+
+	if s.ActiveUntil != "" {
+		if until, err = parseScheduleTime(s.ActiveUntil, location); err != nil {
+			return fmt.Errorf("activeUntil: %w", err)
+		}
+	}
+
+	if !from.IsZero() && !until.IsZero() && !until.After(from) {
+		return errScheduleWindow
+	}
+
+	if s.Fallback != "" {
+		if err := checkDestination(ctx, policy, s.Fallback); err != nil {
+			return fmt.Errorf("fallback: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// active reports whether now is inside the activation window, the window
+// includes ActiveFrom and excludes ActiveUntil.
+func (s *Schedule) active(now time.Time) bool {
+	location, err := s.location()
+	if err != nil {
+		location = time.UTC
+	}
+
+	if from, err := parseScheduleTime(s.ActiveFrom, location); err == nil && now.Before(from) {
+		return false
+	}
+
+	if until, err := parseScheduleTime(s.ActiveUntil, location); err == nil && !now.Before(until) {
+		return false
+	}
+
+	return true
+}
+
+// destination returns the URL of the last entry started by now, false
+// before the first entry starts.
+func (s *Schedule) destination(now time.Time) (string, bool) {
+	location, err := s.location()
+	if err != nil {
+		location = time.UTC
+	}
+
+	destination, started := "", false
+
+	for _, entry := range s.Entries {
+		start, err := parseScheduleTime(entry.Start, location)
+		if err != nil || now.Before(start) {
+			break
+		}
+
+		destination, started = entry.URL, true
+	}
+
+	return destination, started
+}
+
+func (s Schedule) Value() (driver.Value, error) {
+	return jsonColumnValue(s, false)
+}
+
+func (s *Schedule) Scan(src any) error {
+	return scanJSONColumn(src, s)
+}
@@ -0,0 +1,144 @@
+package links_test
+
+import (
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jacekdobrowolski/goshort/internal/links"
+)
+
+func Test_redirectSchedule(t *testing.T) {
+	t.Parallel()
+
+	store := newMockStore()
+	logger := slog.New(slog.NewTextHandler(io.Discard, &slog.HandlerOptions{Level: slog.LevelError}))
+	create := links.HandlerCreateLink(logger, store, nil)
+	redirect := links.HandlerRedirect(logger, store, links.RedirectOptions{})
+
+	now := time.Now()
+
+	newYork, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	wallClock := func(offset time.Duration) string {
+		return now.Add(offset).In(newYork).Format("2006-01-02T15:04")
+	}
+	rfc3339 := func(offset time.Duration) string {
+		return now.Add(offset).Format(time.RFC3339)
+	}
+	// the current UTC wall clock started 14 hours ago in Kiritimati and
+	// starts in 11 hours in Pago Pago
+	utcWallClock := now.UTC().Format("2006-01-02T15:04:05")
+
+	createTests := []struct {
+		body   string
+		status int
+	}{
+		{
+			body: `{"url":"http://example.com/","alias":"launch","schedule":{"timeZone":"America/New_York","entries":[` +
+				`{"start":"` + wallClock(-2*time.Hour) + `","url":"http://example.com/teaser"},` +
+				`{"start":"` + wallClock(-time.Hour) + `","url":"http://example.com/product"},` +
+				`{"start":"` + wallClock(time.Hour) + `","url":"http://example.com/archive"}]}}`,
+			status: http.StatusCreated,
+		},
+		{
+			body: `{"url":"http://example.com/","alias":"kiritimati","schedule":{"timeZone":"Pacific/Kiritimati",` +
+				`"entries":[{"start":"` + utcWallClock + `","url":"http://example.com/started"}]}}`,
+			status: http.StatusCreated,
+		},
+		{
+			body: `{"url":"http://example.com/","alias":"pago-pago","schedule":{"timeZone":"Pacific/Pago_Pago",` +
+				`"entries":[{"start":"` + utcWallClock + `","url":"http://example.com/started"}]}}`,
+			status: http.StatusCreated,
+		},
+		{
+			body:   `{"url":"http://example.com/","alias":"soon","schedule":{"activeFrom":"` + rfc3339(time.Hour) + `"}}`,
+			status: http.StatusCreated,
+		},
+		{
+			body: `{"url":"http://example.com/","alias":"ended","schedule":{"activeUntil":"` + rfc3339(-time.Hour) +
+				`","fallback":"http://example.com/archive"}}`,
+			status: http.StatusCreated,
+		},
+		{
+			body: `{"url":"http://example.com/","alias":"open","schedule":{"activeFrom":"` + rfc3339(-time.Hour) +
+				`","activeUntil":"` + rfc3339(time.Hour) + `"}}`,
+			status: http.StatusCreated,
+		},
+		{
+			body:   `{"url":"http://example.com/","schedule":{"timeZone":"Mars/Olympus","activeFrom":"2026-01-01T00:00"}}`,
+			status: http.StatusBadRequest,
+		},
+		{
+			body: `{"url":"http://example.com/","schedule":{"entries":[` +
+				`{"start":"2026-02-01T00:00","url":"http://example.com/a"},` +
+				`{"start":"2026-01-01T00:00","url":"http://example.com/b"}]}}`,
+			status: http.StatusBadRequest,
+		},
+		{
+			body:   `{"url":"http://example.com/","schedule":{"entries":[{"start":"tomorrow","url":"http://a.com/"}]}}`,
+			status: http.StatusBadRequest,
+		},
+		{
+			body: `{"url":"http://example.com/","schedule":` +
+				`{"activeFrom":"2026-02-01T00:00","activeUntil":"2026-01-01T00:00"}}`,
+			status: http.StatusBadRequest,
+		},
+		{
+			body:   `{"url":"http://example.com/","schedule":{"timeZone":"UTC"}}`,
+			status: http.StatusBadRequest,
+		},
+	}
+
+	for _, tt := range createTests {
+		r := httptest.NewRequest(http.MethodPost, "/api/v1/links", strings.NewReader(tt.body))
+		r.Header.Add("Content-Type", "application/json")
+
+		w := httptest.NewRecorder()
+		create(w, r)
+
+		if w.Code != tt.status {
+			t.Fatalf("%s expected StatusCode %d got %d", tt.body, tt.status, w.Code)
+		}
+	}
+
+	tests := []struct {
+		short    string
+		status   int
+		location string
+	}{
+		{short: "launch", status: http.StatusTemporaryRedirect, location: "http://example.com/product"},
+		{short: "kiritimati", status: http.StatusTemporaryRedirect, location: "http://example.com/started"},
+		{short: "pago-pago", status: http.StatusTemporaryRedirect, location: "http://example.com/"},
+		{short: "soon", status: http.StatusNotFound, location: ""},
+		{short: "ended", status: http.StatusTemporaryRedirect, location: "http://example.com/archive"},
+		{short: "open", status: http.StatusTemporaryRedirect, location: "http://example.com/"},
+	}
+
+	for _, tt := range tests {
+		r := httptest.NewRequest(http.MethodGet, "/"+tt.short, nil)
+		r.SetPathValue("short", tt.short)
+
+		w := httptest.NewRecorder()
+		redirect(w, r)
+
+		if w.Code != tt.status {
+			t.Errorf("%s expected StatusCode %d got %d", tt.short, tt.status, w.Code)
+		}
+
+		if location := w.Header().Get("Location"); location != tt.location {
+			t.Errorf("%s expected Location %q got %q", tt.short, tt.location, location)
+		}
+
+		if cacheControl := w.Header().Get("Cache-Control"); tt.location != "" && cacheControl != "private, no-cache" {
+			t.Errorf("%s expected Cache-Control %q got %q", tt.short, "private, no-cache", cacheControl)
+		}
+	}
+}
@@ -2,6 +2,7 @@ package links
 
 import (
 	"context"
+	"crypto/rand"
 	"errors"
 	"fmt"
 	"io"
@@ -10,10 +11,15 @@ import (
 	"net/http"
 	"os"
 	"os/signal"
+	"strings"
 	"sync"
 	"time"
 
+	"github.com/jacekdobrowolski/goshort/internal/auth"
+	"github.com/jacekdobrowolski/goshort/pkg/blocklist"
+	"github.com/jacekdobrowolski/goshort/pkg/geoip"
 	"github.com/jacekdobrowolski/goshort/pkg/logging"
+	"github.com/jacekdobrowolski/goshort/pkg/ratelimit"
 	"github.com/jacekdobrowolski/goshort/pkg/telemetry"
 	"go.opentelemetry.io/contrib/bridges/otelslog"
 	"go.opentelemetry.io/otel"
@@ -27,16 +33,60 @@ import (
 	"google.golang.org/grpc/credentials/insecure"
 )
 
-func NewServer(logger *slog.Logger, pgStore *PostgresStore) http.Handler {
+const cookieSecretSize = 32
+
+func NewServer(
+	logger *slog.Logger,
+	accessLog io.Writer,
+	cfg Config,
+	pgStore *PostgresStore,
+	authenticators ...auth.Authenticator,
+) http.Handler {
 	mux := http.NewServeMux()
-	addRoutes(mux, logger, pgStore)
+	addRoutes(mux, logger, pgStore, pgStore, cfg, rateLimiter(cfg, pgStore))
 
 	var handler http.Handler = mux
+	authenticators = append([]auth.Authenticator{auth.APIKeyAuthenticator{Store: pgStore}}, authenticators...)
+	handler = authenticateAPI(handler, logger, authenticators...)
+	handler = PublicURLMiddleware(handler, cfg.PublicBaseURL, cfg.TrustedProxies)
+	handler = logging.RecoveryMiddleware(handler, logger)
 	handler = logging.Middleware(handler, logger)
 
+	if cfg.AccessLog != nil {
+		handler = logging.AccessLogMiddleware(handler, accessLog, *cfg.AccessLog)
+	}
+
+	handler = logging.RequestIDMiddleware(handler)
+
 	return handler
 }
 
+// authenticateAPI looks up credentials of management API requests only,
+// redirects are public and must not cost a key lookup before rate limiting.
+func authenticateAPI(next http.Handler, logger *slog.Logger, authenticators ...auth.Authenticator) http.Handler {
+	authenticated := auth.Middleware(next, logger, authenticators...)
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.HasPrefix(r.URL.Path, "/api/") {
+			authenticated.ServeHTTP(w, r)
+
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// rateLimiter returns the backend shared by route rate limits and password
+// attempt throttling.
+func rateLimiter(cfg Config, pgStore *PostgresStore) ratelimit.Limiter {
+	if cfg.RateLimitBackend == RateLimitBackendPostgres {
+		return pgStore
+	}
+
+	return ratelimit.NewMemoryLimiter()
+}
+
 func Run(ctx context.Context, w io.Writer, env func(string) string) error {
 	ctx, cancel := signal.NotifyContext(ctx, os.Interrupt)
 	defer cancel()
@@ -95,29 +145,69 @@ func Run(ctx context.Context, w io.Writer, env func(string) string) error {
 		}
 	}()
 
-	requireEnv := func(variableName string) string {
-		variable := env(variableName)
-		if len(variable) == 0 {
-			logger.Error("required Environment variable is empty or does not exist", "variable_name", variableName)
-		}
+	connectionString := postgresConnectionString(env, logger)
 
-		return variable
+	cfg, err := LoadConfig(env)
+	if err != nil {
+		return err
 	}
 
-	connectionString := fmt.Sprintf(
-		"user=%s password=%s dbname=%s sslmode=disable host=%s port=%s",
-		requireEnv("LINKS_POSTGRES_USER"),
-		requireEnv("LINKS_POSTGRES_PASSWORD"),
-		requireEnv("LINKS_POSTGRES_DBNAME"),
-		requireEnv("LINKS_POSTGRES_HOST"),
-		requireEnv("LINKS_POSTGRES_PORT"))
-
 	pgStore, err := NewPostgresStore(ctx, connectionString, logger)
 	if err != nil {
 		return err
 	}
 
-	srv := NewServer(logger, pgStore)
+	pgStore.DefaultMaxLinks = cfg.DefaultMaxLinks
+
+	if len(cfg.CookieSecret) == 0 {
+		logger.Warn("LINKS_COOKIE_SECRET not set, unlocked password protected links reset on restart")
+
+		cfg.CookieSecret = make([]byte, cookieSecretSize)
+		if _, err := rand.Read(cfg.CookieSecret); err != nil {
+			return fmt.Errorf("error generating cookie secret: %w", err)
+		}
+	}
+
+	if cfg.Blocklist != nil {
+		blocked, err := blocklist.New(cfg.Blocklist.DomainFiles, cfg.Blocklist.HashPrefixFiles)
+		if err != nil {
+			return fmt.Errorf("error loading blocklist: %w", err)
+		}
+
+		cfg.URLPolicy.Blocklist = blocked
+
+		go blocked.Watch(ctx, cfg.Blocklist.ReloadInterval, logger)
+	}
+
+	if cfg.GeoIPDatabase != "" {
+		db, err := geoip.Open(cfg.GeoIPDatabase)
+		if err != nil {
+			return fmt.Errorf("error loading geoip database: %w", err)
+		}
+
+		defer func() { _ = db.Close() }()
+
+		cfg.Locator = db
+	}
+
+	var authenticators []auth.Authenticator
+
+	if cfg.JWT != nil {
+		jwks, err := auth.NewJWKS(ctx, cfg.JWT.JWKS, nil, cfg.JWT.RefreshInterval)
+		if err != nil {
+			return fmt.Errorf("error loading jwks: %w", err)
+		}
+
+		authenticators = append(authenticators, auth.NewJWTAuthenticator(jwks, cfg.JWT.Options))
+	}
+
+	if cfg.RateLimitBackend == RateLimitBackendPostgres {
+		go prune(ctx, logger, "rate limits", pgStore.PruneRateLimits)
+	}
+
+	go prune(ctx, logger, "idempotency keys", pgStore.PruneIdempotencyKeys)
+
+	srv := NewServer(logger, w, cfg, pgStore, authenticators...)
 
 	//nolint: mnd
 	httpServer := &http.Server{
@@ -158,6 +248,42 @@ func Run(ctx context.Context, w io.Writer, env func(string) string) error {
 	return nil
 }
 
+// prune removes expired rows of a table every hour.
+func prune(ctx context.Context, logger *slog.Logger, name string, remove func(context.Context) error) {
+	ticker := time.NewTicker(time.Hour)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := remove(ctx); err != nil {
+				logger.ErrorContext(ctx, "error pruning "+name, slog.String("err", err.Error()))
+			}
+		}
+	}
+}
+
+func postgresConnectionString(env func(string) string, logger *slog.Logger) string {
+	requireEnv := func(variableName string) string {
+		variable := env(variableName)
+		if len(variable) == 0 {
+			logger.Error("required Environment variable is empty or does not exist", "variable_name", variableName)
+		}
+
+		return variable
+	}
+
+	return fmt.Sprintf(
+		"user=%s password=%s dbname=%s sslmode=disable host=%s port=%s",
+		requireEnv("LINKS_POSTGRES_USER"),
+		requireEnv("LINKS_POSTGRES_PASSWORD"),
+		requireEnv("LINKS_POSTGRES_DBNAME"),
+		requireEnv("LINKS_POSTGRES_HOST"),
+		requireEnv("LINKS_POSTGRES_PORT"))
+}
+
 func initLogger(
 	ctx context.Context,
 	w io.Writer,
@@ -166,7 +292,7 @@ func initLogger(
 ) (*slog.Logger, func(context.Context)) {
 	logExporter, err := otlploggrpc.New(ctx, otlploggrpc.WithGRPCConn(telemetryConn))
 	if err != nil {
-		logger := slog.New(
+		logger := slog.New(logging.NewContextHandler(
 			slog.NewJSONHandler(
 				w,
 				&slog.HandlerOptions{
@@ -174,7 +300,7 @@ func initLogger(
 					Level:     slog.LevelDebug,
 				},
 			),
-		)
+		))
 
 		logger.With(slog.String("application", "links"))
 		logger.Error("otel logger init error", slog.String("err", err.Error()))
@@ -188,7 +314,9 @@ func initLogger(
 			sdklog.NewBatchProcessor(logExporter)),
 	)
 
-	logger := otelslog.NewLogger("links", otelslog.WithLoggerProvider(logProvider))
+	logger := slog.New(logging.NewContextHandler(
+		otelslog.NewHandler("links", otelslog.WithLoggerProvider(logProvider)),
+	))
 
 	logger.Info("otel logger initialized")
 
@@ -3,37 +3,144 @@ package links
 import (
 	"context"
 	"database/sql"
+	"database/sql/driver"
 	"embed"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"log/slog"
 	"time"
 
 	"github.com/jmoiron/sqlx"
-	// Postgres driver give me a break.
-	_ "github.com/lib/pq"
+	"github.com/lib/pq"
 	"github.com/pressly/goose/v3"
 	"go.opentelemetry.io/otel"
 	"go.opentelemetry.io/otel/trace"
 )
 
 const (
-	migrationVersion = 20240305130405
+	migrationVersion = 20261019010000
 
 	insertTimeout = 1 * time.Second
 	selectTimeout = 200 * time.Millisecond
+
+	uniqueViolation = "23505"
+
+	linkColumns = "tenant_id, domain, short, original, COALESCE(owner, '') AS owner, created_at, clicks, " +
+		"COALESCE(disabled_reason, '') AS disabled_reason, interstitial, " +
+		"COALESCE(password_hash, '') AS password_hash, COALESCE(redirect_status, 0) AS redirect_status, " +
+		"COALESCE(query_passthrough, '') AS query_passthrough, path_passthrough, " +
+		`COALESCE(utm_source, '') AS "utm.source", COALESCE(utm_medium, '') AS "utm.medium", ` +
+		`COALESCE(utm_campaign, '') AS "utm.campaign", COALESCE(utm_term, '') AS "utm.term", ` +
+		`COALESCE(utm_content, '') AS "utm.content", targets, variants, ` +
+		"COALESCE(variant_assignment, '') AS variant_assignment, schedule, " +
+		"COALESCE(max_clicks, 0) AS max_clicks, COALESCE(remaining_clicks, 0) AS remaining_clicks, " +
+		"COALESCE(exhausted_fallback, '') AS exhausted_fallback, " +
+		"COALESCE((SELECT base_url FROM domains WHERE domains.host = links.domain), '') AS base_url"
+)
+
+var (
+	ErrLinkNotFound  = errors.New("link not found")
+	ErrShortExists   = errors.New("short already exists")
+	ErrQuotaExceeded = errors.New("tenant link quota exceeded")
+	// ErrClicksExhausted is returned by RecordClick for links without
+	// remaining clicks.
+	ErrClicksExhausted = errors.New("link clicks exhausted")
+
+	errJSONColumnType = errors.New("unexpected json column type")
 )
 
 //go:embed migrations/*.sql
 var embedMigrations embed.FS
 
+type StoredLink struct {
+	// Tenant namespaces short codes, empty for the default tenant.
+	Tenant string `db:"tenant_id"`
+	// Domain is the host the link is bound to, empty links are served on
+	// every host of the tenant.
+	Domain   string `db:"domain"`
+	Short    string `db:"short"`
+	Original string `db:"original"`
+	// Owner is the ID of the principal that created the link.
+	Owner     string    `db:"owner"`
+	CreatedAt time.Time `db:"created_at"`
+	Clicks    int64     `db:"clicks"`
+	// DisabledReason is set when the destination was flagged, disabled
+	// links show an interstitial instead of redirecting.
+	DisabledReason string `db:"disabled_reason"`
+	// Interstitial asks for a click-through before redirecting to another domain.
+	Interstitial bool `db:"interstitial"`
+	// PasswordHash is the bcrypt hash of the link password, empty when the
+	// link is public.
+	PasswordHash string `db:"password_hash"`
+	// RedirectStatus is 301, 302, 307 or 308, 0 uses the service default.
+	RedirectStatus int `db:"redirect_status"`
+	// QueryPassthrough is QueryMerge, QueryOverride or QueryAppend, empty
+	// drops the incoming query.
+	QueryPassthrough string `db:"query_passthrough"`
+	// PathPassthrough appends the path after the short code to the destination.
+	PathPassthrough bool `db:"path_passthrough"`
+	// UTM parameters the destination was built with.
+	UTM UTM `db:"utm"`
+	// Targets pick another destination by visitor device or location, the
+	// link destination is the default.
+	Targets TargetRules `db:"targets"`
+	// Variants split visitors matching no target by weight.
+	Variants Variants `db:"variants"`
+	// VariantAssignment is AssignCookie or AssignHash, empty is AssignCookie.
+	VariantAssignment string `db:"variant_assignment"`
+	// Schedule changes the destination over time, nil when unscheduled.
+	Schedule *Schedule `db:"schedule"`
+	// MaxClicks limits how many times the link redirects, 0 is unlimited.
+	MaxClicks       int `db:"max_clicks"`
+	RemainingClicks int `db:"remaining_clicks"`
+	// ExhaustedFallback is where visitors go once no clicks remain, 410 when
+	// empty.
+	ExhaustedFallback string `db:"exhausted_fallback"`
+	// BaseURL of the link domain, read only.
+	BaseURL string `db:"base_url"`
+}
+
+// Click describes a redirect for analytics, empty fields are unknown.
+type Click struct {
+	// Country is the ISO 3166-1 alpha-2 code of the client IP.
+	Country string
+	// Variant is the name of the variant the visitor was assigned.
+	Variant string
+}
+
 type Store interface {
-	AddLink(ctx context.Context, short, url string) error
-	GetOriginal(ctx context.Context, short string) (*string, error)
+	// AddLink returns ErrShortExists when the tenant already uses the short
+	// code on the link domain and ErrQuotaExceeded when the tenant reached
+	// its link limit.
+	AddLink(ctx context.Context, link StoredLink) error
+	GetLink(ctx context.Context, tenant, domain, short string) (*StoredLink, error)
+	ListLinks(ctx context.Context, tenant string, limit, offset int) ([]StoredLink, error)
+	// UpdateLink changes the destination, a disabled link is re-enabled only
+	// when the destination passed the blocklist.
+	UpdateLink(ctx context.Context, tenant, domain, short, original string, enable bool) error
+	DisableLink(ctx context.Context, tenant, domain, short, reason string) error
+	DeleteLink(ctx context.Context, tenant, domain, short string) error
+	// RecordClick counts the click and takes one of the remaining clicks of
+	// limited links atomically, ErrClicksExhausted when none remain.
+	RecordClick(ctx context.Context, tenant, domain, short string, click Click) error
+	// CountryClicks counts clicks per country, clicks from unknown countries
+	// are only in the link total.
+	CountryClicks(ctx context.Context, tenant, domain, short string) (map[string]int64, error)
+	VariantClicks(ctx context.Context, tenant, domain, short string) (map[string]int64, error)
+	// CampaignStats aggregates links created with UTM parameters.
+	CampaignStats(ctx context.Context, tenant string) ([]CampaignStats, error)
+	// GetDomain returns the registered domain of host, ErrDomainNotFound
+	// for hosts serving only the default tenant.
+	GetDomain(ctx context.Context, host string) (*Domain, error)
 }
 
 type PostgresStore struct {
 	db     *sqlx.DB
 	tracer trace.Tracer
+
+	// DefaultMaxLinks applies to tenants without their own limit, 0 is unlimited.
+	DefaultMaxLinks int
 }
 
 func NewPostgresStore(ctx context.Context, connStr string, logger *slog.Logger) (*PostgresStore, error) {
@@ -67,52 +174,347 @@ func NewPostgresStore(ctx context.Context, connStr string, logger *slog.Logger)
 	}, nil
 }
 
-func (pg *PostgresStore) AddLink(parentCtx context.Context, short, url string) error {
+func (pg *PostgresStore) AddLink(parentCtx context.Context, link StoredLink) error {
 	ctx, span := pg.tracer.Start(parentCtx, "addlink")
 	defer span.End()
 
 	ctx, cancel := context.WithTimeout(ctx, insertTimeout)
 	defer cancel()
 
-	rows, err := pg.db.QueryContext(ctx, "INSERT INTO links (short, original) VALUES ($1, $2)", short, url)
+	tx, err := pg.db.BeginTxx(ctx, nil)
 	if err != nil {
-		return fmt.Errorf("error query addLink: %w", err)
+		return fmt.Errorf("error starting addLink transaction: %w", err)
+	}
+
+	defer func() { _ = tx.Rollback() }()
+
+	var limit sql.NullInt64
+
+	err = tx.GetContext(ctx, &limit, "SELECT max_links FROM tenants WHERE id = $1", link.Tenant)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return fmt.Errorf("error query tenant limit: %w", err)
+	}
+
+	maxLinks := int64(pg.DefaultMaxLinks)
+	if limit.Valid {
+		maxLinks = limit.Int64
+	}
+
+	if maxLinks > 0 {
+		// serializes inserts of tenants with a quota so concurrent requests
+		// cannot overshoot it, unlimited tenants insert concurrently
+		if _, err := tx.ExecContext(ctx, "SELECT pg_advisory_xact_lock(hashtext($1))", link.Tenant); err != nil {
+			return fmt.Errorf("error locking tenant: %w", err)
+		}
+
+		var count int64
+		if err := tx.GetContext(ctx, &count, "SELECT count(*) FROM links WHERE tenant_id = $1", link.Tenant); err != nil {
+			return fmt.Errorf("error counting tenant links: %w", err)
+		}
+
+		if count >= maxLinks {
+			return fmt.Errorf("%w: %d", ErrQuotaExceeded, maxLinks)
+		}
+	}
+
+	_, err = tx.ExecContext(ctx,
+		"INSERT INTO links (tenant_id, short, original, owner, interstitial, password_hash, redirect_status, "+
+			"query_passthrough, path_passthrough, utm_source, utm_medium, utm_campaign, utm_term, utm_content, "+
+			"targets, variants, variant_assignment, schedule, max_clicks, remaining_clicks, exhausted_fallback, domain) "+
+			"VALUES ($1, $2, $3, NULLIF($4, ''), $5, NULLIF($6, ''), NULLIF($7, 0), NULLIF($8, ''), $9, "+
+			"NULLIF($10, ''), NULLIF($11, ''), NULLIF($12, ''), NULLIF($13, ''), NULLIF($14, ''), $15, $16, "+
+			"NULLIF($17, ''), $18, NULLIF($19, 0), NULLIF($19, 0), NULLIF($20, ''), $21)",
+		link.Tenant, link.Short, link.Original, link.Owner, link.Interstitial, link.PasswordHash, link.RedirectStatus,
+		link.QueryPassthrough, link.PathPassthrough,
+		link.UTM.Source, link.UTM.Medium, link.UTM.Campaign, link.UTM.Term, link.UTM.Content,
+		link.Targets, link.Variants, link.VariantAssignment, link.Schedule, link.MaxClicks, link.ExhaustedFallback,
+		link.Domain,
+	)
+
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) && pqErr.Code == uniqueViolation {
+		return fmt.Errorf("%w: %s", ErrShortExists, link.Short)
 	}
 
-	defer rows.Close()
+	if err != nil {
+		return fmt.Errorf("error query addLink: %w", err)
+	}
 
-	if rowsErr := rows.Err(); rowsErr != nil {
-		return fmt.Errorf("addLink query returned error: %w", rowsErr)
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("error committing addLink: %w", err)
 	}
 
 	return nil
 }
 
-func (pg *PostgresStore) GetOriginal(parentCtx context.Context, short string) (*string, error) {
-	ctx, span := pg.tracer.Start(parentCtx, "getoriginal")
+func (pg *PostgresStore) GetLink(parentCtx context.Context, tenant, domain, short string) (*StoredLink, error) {
+	ctx, span := pg.tracer.Start(parentCtx, "getlink")
 	defer span.End()
 
 	ctx, cancel := context.WithTimeout(ctx, selectTimeout)
 	defer cancel()
 
-	rows, err := pg.db.QueryContext(ctx, "SELECT original FROM links WHERE short = $1", short)
+	var link StoredLink
+
+	err := pg.db.GetContext(ctx, &link,
+		"SELECT "+linkColumns+" FROM links WHERE tenant_id = $1 AND domain = $2 AND short = $3", tenant, domain, short)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, fmt.Errorf("%w: %s", ErrLinkNotFound, short)
+	}
+
 	if err != nil {
-		return nil, fmt.Errorf("error executing query getOriginal: %w", err)
+		return nil, fmt.Errorf("error executing query getLink: %w", err)
 	}
 
-	defer rows.Close()
+	return &link, nil
+}
+
+func (pg *PostgresStore) ListLinks(parentCtx context.Context, tenant string, limit, offset int) ([]StoredLink, error) {
+	ctx, span := pg.tracer.Start(parentCtx, "listlinks")
+	defer span.End()
+
+	ctx, cancel := context.WithTimeout(ctx, selectTimeout)
+	defer cancel()
 
-	if rowsErr := rows.Err(); rowsErr != nil {
-		return nil, fmt.Errorf("getOriginal query returned error: %w", rowsErr)
+	links := []StoredLink{}
+
+	err := pg.db.SelectContext(ctx, &links,
+		"SELECT "+linkColumns+" FROM links WHERE tenant_id = $1 ORDER BY created_at, domain, short LIMIT $2 OFFSET $3",
+		tenant, limit, offset,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("error executing query listLinks: %w", err)
 	}
 
-	var original string
+	return links, nil
+}
+
+func (pg *PostgresStore) UpdateLink(
+	parentCtx context.Context,
+	tenant, domain, short, original string,
+	enable bool,
+) error {
+	ctx, span := pg.tracer.Start(parentCtx, "updatelink")
+	defer span.End()
+
+	ctx, cancel := context.WithTimeout(ctx, insertTimeout)
+	defer cancel()
+
+	return pg.execAffectingLink(ctx, short,
+		"UPDATE links SET original = $4, disabled_reason = CASE WHEN $5 THEN NULL ELSE disabled_reason END "+
+			"WHERE tenant_id = $1 AND domain = $2 AND short = $3",
+		tenant, domain, short, original, enable)
+}
+
+func (pg *PostgresStore) DisableLink(parentCtx context.Context, tenant, domain, short, reason string) error {
+	ctx, span := pg.tracer.Start(parentCtx, "disablelink")
+	defer span.End()
+
+	ctx, cancel := context.WithTimeout(ctx, insertTimeout)
+	defer cancel()
+
+	return pg.execAffectingLink(ctx, short,
+		"UPDATE links SET disabled_reason = $4 WHERE tenant_id = $1 AND domain = $2 AND short = $3",
+		tenant, domain, short, reason)
+}
+
+func (pg *PostgresStore) DeleteLink(parentCtx context.Context, tenant, domain, short string) error {
+	ctx, span := pg.tracer.Start(parentCtx, "deletelink")
+	defer span.End()
+
+	ctx, cancel := context.WithTimeout(ctx, insertTimeout)
+	defer cancel()
+
+	return pg.execAffectingLink(ctx, short,
+		"DELETE FROM links WHERE tenant_id = $1 AND domain = $2 AND short = $3", tenant, domain, short)
+}
+
+func (pg *PostgresStore) RecordClick(parentCtx context.Context, tenant, domain, short string, click Click) error {
+	ctx, span := pg.tracer.Start(parentCtx, "recordclick")
+	defer span.End()
+
+	ctx, cancel := context.WithTimeout(ctx, insertTimeout)
+	defer cancel()
 
-	for rows.Next() {
-		if err := rows.Scan(&original); err != nil {
-			return nil, fmt.Errorf("error scaning rows: %w", err)
+	// the condition on remaining clicks makes concurrent redirects of a
+	// limited link take one click each
+	result, err := pg.db.ExecContext(ctx,
+		"UPDATE links SET clicks = clicks + 1, remaining_clicks = remaining_clicks - 1 "+
+			"WHERE tenant_id = $1 AND domain = $2 AND short = $3 AND (remaining_clicks IS NULL OR remaining_clicks > 0)",
+		tenant, domain, short)
+	if err != nil {
+		return fmt.Errorf("error executing recordClick: %w", err)
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("error reading affected rows: %w", err)
+	}
+
+	if affected == 0 {
+		var exists bool
+
+		err := pg.db.GetContext(ctx, &exists,
+			"SELECT EXISTS (SELECT 1 FROM links WHERE tenant_id = $1 AND domain = $2 AND short = $3)",
+			tenant, domain, short)
+		if err != nil {
+			return fmt.Errorf("error checking link: %w", err)
+		}
+
+		if exists {
+			return fmt.Errorf("%w: %s", ErrClicksExhausted, short)
+		}
+
+		return fmt.Errorf("%w: %s", ErrLinkNotFound, short)
+	}
+
+	if click.Country != "" {
+		_, err = pg.db.ExecContext(ctx,
+			"INSERT INTO link_country_clicks (tenant_id, domain, short, country, clicks) VALUES ($1, $2, $3, $4, 1) "+
+				"ON CONFLICT (tenant_id, domain, short, country) DO UPDATE SET clicks = link_country_clicks.clicks + 1",
+			tenant, domain, short, click.Country)
+		if err != nil {
+			return fmt.Errorf("error recording country click: %w", err)
 		}
 	}
 
-	return &original, nil
+	if click.Variant != "" {
+		_, err = pg.db.ExecContext(ctx,
+			"INSERT INTO link_variant_clicks (tenant_id, domain, short, variant, clicks) VALUES ($1, $2, $3, $4, 1) "+
+				"ON CONFLICT (tenant_id, domain, short, variant) DO UPDATE SET clicks = link_variant_clicks.clicks + 1",
+			tenant, domain, short, click.Variant)
+		if err != nil {
+			return fmt.Errorf("error recording variant click: %w", err)
+		}
+	}
+
+	return nil
+}
+
+func (pg *PostgresStore) CountryClicks(
+	parentCtx context.Context,
+	tenant, domain, short string,
+) (map[string]int64, error) {
+	ctx, span := pg.tracer.Start(parentCtx, "countryclicks")
+	defer span.End()
+
+	ctx, cancel := context.WithTimeout(ctx, selectTimeout)
+	defer cancel()
+
+	return pg.clickCounts(ctx,
+		"SELECT country AS key, clicks FROM link_country_clicks WHERE tenant_id = $1 AND domain = $2 AND short = $3",
+		tenant, domain, short)
+}
+
+func (pg *PostgresStore) VariantClicks(
+	parentCtx context.Context,
+	tenant, domain, short string,
+) (map[string]int64, error) {
+	ctx, span := pg.tracer.Start(parentCtx, "variantclicks")
+	defer span.End()
+
+	ctx, cancel := context.WithTimeout(ctx, selectTimeout)
+	defer cancel()
+
+	return pg.clickCounts(ctx,
+		"SELECT variant AS key, clicks FROM link_variant_clicks WHERE tenant_id = $1 AND domain = $2 AND short = $3",
+		tenant, domain, short)
+}
+
+func (pg *PostgresStore) clickCounts(ctx context.Context, query string, args ...any) (map[string]int64, error) {
+	rows := []struct {
+		Key    string `db:"key"`
+		Clicks int64  `db:"clicks"`
+	}{}
+
+	if err := pg.db.SelectContext(ctx, &rows, query, args...); err != nil {
+		return nil, fmt.Errorf("error executing click counts query: %w", err)
+	}
+
+	clicks := make(map[string]int64, len(rows))
+	for _, row := range rows {
+		clicks[row.Key] = row.Clicks
+	}
+
+	return clicks, nil
+}
+
+func (pg *PostgresStore) CampaignStats(parentCtx context.Context, tenant string) ([]CampaignStats, error) {
+	ctx, span := pg.tracer.Start(parentCtx, "campaignstats")
+	defer span.End()
+
+	ctx, cancel := context.WithTimeout(ctx, selectTimeout)
+	defer cancel()
+
+	stats := []CampaignStats{}
+
+	err := pg.db.SelectContext(ctx, &stats, `
+		SELECT COALESCE(utm_source, '') AS source, COALESCE(utm_medium, '') AS medium, utm_campaign AS campaign,
+			count(*) AS links, COALESCE(sum(clicks), 0) AS clicks
+		FROM links
+		WHERE tenant_id = $1 AND utm_campaign IS NOT NULL
+		GROUP BY utm_source, utm_medium, utm_campaign
+		ORDER BY utm_campaign, utm_source, utm_medium`,
+		tenant,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("error executing query campaignStats: %w", err)
+	}
+
+	return stats, nil
+}
+
+func (pg *PostgresStore) execAffectingLink(ctx context.Context, short, query string, args ...any) error {
+	result, err := pg.db.ExecContext(ctx, query, args...)
+	if err != nil {
+		return fmt.Errorf("error executing link query: %w", err)
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("error reading affected rows: %w", err)
+	}
+
+	if affected == 0 {
+		return fmt.Errorf("%w: %s", ErrLinkNotFound, short)
+	}
+
+	return nil
+}
+
+// jsonColumnValue encodes v for a jsonb column, NULL when empty.
+func jsonColumnValue(v any, empty bool) (driver.Value, error) {
+	if empty {
+		return nil, nil //nolint:nilnil // empty values store NULL
+	}
+
+	value, err := json.Marshal(v)
+	if err != nil {
+		return nil, fmt.Errorf("error encoding json column: %w", err)
+	}
+
+	// lib/pq sends []byte parameters as bytea which jsonb does not accept
+	return string(value), nil
+}
+
+// scanJSONColumn decodes a jsonb column into dest, NULL leaves it empty.
+func scanJSONColumn(src, dest any) error {
+	var data []byte
+
+	switch src := src.(type) {
+	case nil:
+		return nil
+	case []byte:
+		data = src
+	case string:
+		data = []byte(src)
+	default:
+		return fmt.Errorf("%w: %T", errJSONColumnType, src)
+	}
+
+	if err := json.Unmarshal(data, dest); err != nil {
+		return fmt.Errorf("error decoding json column: %w", err)
+	}
+
+	return nil
 }
@@ -0,0 +1,121 @@
+package links
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"github.com/jacekdobrowolski/goshort/internal/auth"
+	"github.com/lib/pq"
+)
+
+type apiKeyRow struct {
+	auth.APIKey
+
+	Scopes pq.StringArray `db:"scopes"`
+}
+
+func (row apiKeyRow) toAPIKey() *auth.APIKey {
+	key := row.APIKey
+	key.Scopes = make([]auth.Scope, 0, len(row.Scopes))
+
+	for _, scope := range row.Scopes {
+		key.Scopes = append(key.Scopes, auth.Scope(scope))
+	}
+
+	return &key
+}
+
+func (pg *PostgresStore) CreateAPIKey(parentCtx context.Context, key *auth.APIKey) error {
+	ctx, span := pg.tracer.Start(parentCtx, "createapikey")
+	defer span.End()
+
+	ctx, cancel := context.WithTimeout(ctx, insertTimeout)
+	defer cancel()
+
+	scopes := make(pq.StringArray, 0, len(key.Scopes))
+	for _, scope := range key.Scopes {
+		scopes = append(scopes, string(scope))
+	}
+
+	_, err := pg.db.ExecContext(ctx,
+		"INSERT INTO api_keys (id, name, tenant_id, hash, scopes, created_at) VALUES ($1, $2, $3, $4, $5, $6)",
+		key.ID, key.Name, key.TenantID, key.Hash, scopes, key.CreatedAt,
+	)
+	if err != nil {
+		return fmt.Errorf("error query createAPIKey: %w", err)
+	}
+
+	return nil
+}
+
+func (pg *PostgresStore) GetAPIKey(parentCtx context.Context, id string) (*auth.APIKey, error) {
+	ctx, span := pg.tracer.Start(parentCtx, "getapikey")
+	defer span.End()
+
+	ctx, cancel := context.WithTimeout(ctx, selectTimeout)
+	defer cancel()
+
+	var row apiKeyRow
+
+	err := pg.db.GetContext(ctx, &row,
+		"SELECT id, name, tenant_id, hash, scopes, created_at, revoked_at FROM api_keys WHERE id = $1", id)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, fmt.Errorf("%w: %s", auth.ErrUnknownAPIKey, id)
+	}
+
+	if err != nil {
+		return nil, fmt.Errorf("error executing query getAPIKey: %w", err)
+	}
+
+	return row.toAPIKey(), nil
+}
+
+func (pg *PostgresStore) ListAPIKeys(parentCtx context.Context) ([]*auth.APIKey, error) {
+	ctx, span := pg.tracer.Start(parentCtx, "listapikeys")
+	defer span.End()
+
+	ctx, cancel := context.WithTimeout(ctx, selectTimeout)
+	defer cancel()
+
+	var rows []apiKeyRow
+
+	err := pg.db.SelectContext(ctx, &rows,
+		"SELECT id, name, tenant_id, hash, scopes, created_at, revoked_at FROM api_keys ORDER BY created_at")
+	if err != nil {
+		return nil, fmt.Errorf("error executing query listAPIKeys: %w", err)
+	}
+
+	keys := make([]*auth.APIKey, 0, len(rows))
+	for _, row := range rows {
+		keys = append(keys, row.toAPIKey())
+	}
+
+	return keys, nil
+}
+
+func (pg *PostgresStore) RevokeAPIKey(parentCtx context.Context, id string) error {
+	ctx, span := pg.tracer.Start(parentCtx, "revokeapikey")
+	defer span.End()
+
+	ctx, cancel := context.WithTimeout(ctx, insertTimeout)
+	defer cancel()
+
+	result, err := pg.db.ExecContext(ctx,
+		"UPDATE api_keys SET revoked_at = now() WHERE id = $1 AND revoked_at IS NULL", id)
+	if err != nil {
+		return fmt.Errorf("error query revokeAPIKey: %w", err)
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("error reading revokeAPIKey result: %w", err)
+	}
+
+	if affected == 0 {
+		return fmt.Errorf("%w: %s", auth.ErrUnknownAPIKey, id)
+	}
+
+	return nil
+}
@@ -0,0 +1,116 @@
+package links
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+var (
+	ErrDomainNotFound = errors.New("domain not found")
+	ErrDomainExists   = errors.New("domain already exists")
+)
+
+// Domain is a host serving short links, links bound to it share its base URL.
+type Domain struct {
+	Host   string `db:"host"`
+	Tenant string `db:"tenant_id"`
+	// BaseURL prefixes short codes in short URLs, for example https://go.acme.test.
+	BaseURL   string    `db:"base_url"`
+	CreatedAt time.Time `db:"created_at"`
+}
+
+// NewDomain normalizes host and validates baseURL, https://host when empty.
+func NewDomain(host, tenant, baseURL string) (Domain, error) {
+	domain := Domain{Host: normalizeHost(host), Tenant: tenant}
+
+	if baseURL == "" {
+		baseURL = "https://" + domain.Host
+	}
+
+	var err error
+
+	if domain.BaseURL, err = ParseBaseURL(baseURL); err != nil {
+		return Domain{}, err
+	}
+
+	return domain, nil
+}
+
+func (pg *PostgresStore) CreateDomain(parentCtx context.Context, domain Domain) error {
+	ctx, span := pg.tracer.Start(parentCtx, "createdomain")
+	defer span.End()
+
+	ctx, cancel := context.WithTimeout(ctx, insertTimeout)
+	defer cancel()
+
+	_, err := pg.db.ExecContext(ctx,
+		"INSERT INTO domains (host, tenant_id, base_url) VALUES ($1, $2, $3)",
+		domain.Host, domain.Tenant, domain.BaseURL,
+	)
+
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) && pqErr.Code == uniqueViolation {
+		return fmt.Errorf("%w: %s", ErrDomainExists, domain.Host)
+	}
+
+	if err != nil {
+		return fmt.Errorf("error query createDomain: %w", err)
+	}
+
+	return nil
+}
+
+func (pg *PostgresStore) ListDomains(parentCtx context.Context) ([]Domain, error) {
+	ctx, span := pg.tracer.Start(parentCtx, "listdomains")
+	defer span.End()
+
+	ctx, cancel := context.WithTimeout(ctx, selectTimeout)
+	defer cancel()
+
+	var domains []Domain
+
+	err := pg.db.SelectContext(ctx, &domains,
+		"SELECT host, tenant_id, base_url, created_at FROM domains ORDER BY tenant_id, host")
+	if err != nil {
+		return nil, fmt.Errorf("error executing query listDomains: %w", err)
+	}
+
+	return domains, nil
+}
+
+func (pg *PostgresStore) GetDomain(parentCtx context.Context, host string) (*Domain, error) {
+	ctx, span := pg.tracer.Start(parentCtx, "getdomain")
+	defer span.End()
+
+	ctx, cancel := context.WithTimeout(ctx, selectTimeout)
+	defer cancel()
+
+	var domain Domain
+
+	err := pg.db.GetContext(ctx, &domain,
+		"SELECT host, tenant_id, base_url, created_at FROM domains WHERE host = $1", normalizeHost(host))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, fmt.Errorf("%w: %s", ErrDomainNotFound, host)
+	}
+
+	if err != nil {
+		return nil, fmt.Errorf("error executing query getDomain: %w", err)
+	}
+
+	return &domain, nil
+}
+
+func normalizeHost(host string) string {
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+
+	return strings.TrimSuffix(strings.ToLower(host), ".")
+}
@@ -0,0 +1,132 @@
+package links
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// reserveIdempotencyAttempts bounds retries of keys released between the
+// conflicting insert and reading the stored response.
+const reserveIdempotencyAttempts = 3
+
+var errIdempotencyKeyContended = errors.New("idempotency key kept changing while reserving it")
+
+// reserveIdempotencyKey claims a new key or one whose response expired,
+// keys claimed by other requests are left untouched and return no row.
+const reserveIdempotencyKey = `
+INSERT INTO idempotency_keys AS ik (tenant_id, principal, key, fingerprint, expires_at)
+VALUES ($1, $2, $3, $4, $5)
+ON CONFLICT (tenant_id, principal, key) DO UPDATE SET
+    fingerprint = EXCLUDED.fingerprint,
+    status = NULL,
+    content_type = NULL,
+    body = NULL,
+    expires_at = EXCLUDED.expires_at
+WHERE ik.expires_at < now()
+RETURNING true`
+
+func (pg *PostgresStore) ReserveIdempotencyKey(
+	parentCtx context.Context,
+	key IdempotencyKey,
+	fingerprint string,
+	lease time.Time,
+) (IdempotentResponse, bool, error) {
+	ctx, span := pg.tracer.Start(parentCtx, "reserveidempotencykey")
+	defer span.End()
+
+	ctx, cancel := context.WithTimeout(ctx, insertTimeout)
+	defer cancel()
+
+	for range reserveIdempotencyAttempts {
+		var reserved []bool
+
+		err := pg.db.SelectContext(ctx, &reserved, reserveIdempotencyKey,
+			key.Tenant, key.Principal, key.Key, fingerprint, lease)
+		if err != nil {
+			return IdempotentResponse{}, false, fmt.Errorf("error query reserveIdempotencyKey: %w", err)
+		}
+
+		if len(reserved) > 0 {
+			return IdempotentResponse{}, true, nil
+		}
+
+		var stored IdempotentResponse
+
+		err = pg.db.GetContext(ctx, &stored,
+			"SELECT fingerprint, COALESCE(status, 0) AS status, COALESCE(content_type, '') AS content_type, "+
+				"COALESCE(body, ''::bytea) AS body FROM idempotency_keys "+
+				"WHERE tenant_id = $1 AND principal = $2 AND key = $3",
+			key.Tenant, key.Principal, key.Key)
+		if errors.Is(err, sql.ErrNoRows) {
+			// released or pruned after the insert conflicted, claim it again
+			continue
+		}
+
+		if err != nil {
+			return IdempotentResponse{}, false, fmt.Errorf("error query idempotentResponse: %w", err)
+		}
+
+		return stored, false, nil
+	}
+
+	return IdempotentResponse{}, false, errIdempotencyKeyContended
+}
+
+func (pg *PostgresStore) CompleteIdempotencyKey(
+	parentCtx context.Context,
+	key IdempotencyKey,
+	response IdempotentResponse,
+	expires time.Time,
+) error {
+	ctx, span := pg.tracer.Start(parentCtx, "completeidempotencykey")
+	defer span.End()
+
+	ctx, cancel := context.WithTimeout(ctx, insertTimeout)
+	defer cancel()
+
+	_, err := pg.db.ExecContext(ctx,
+		"UPDATE idempotency_keys SET status = $4, content_type = $5, body = $6, expires_at = $7 "+
+			"WHERE tenant_id = $1 AND principal = $2 AND key = $3",
+		key.Tenant, key.Principal, key.Key, response.Status, response.ContentType, response.Body, expires)
+	if err != nil {
+		return fmt.Errorf("error query completeIdempotencyKey: %w", err)
+	}
+
+	return nil
+}
+
+func (pg *PostgresStore) ReleaseIdempotencyKey(parentCtx context.Context, key IdempotencyKey) error {
+	ctx, span := pg.tracer.Start(parentCtx, "releaseidempotencykey")
+	defer span.End()
+
+	ctx, cancel := context.WithTimeout(ctx, insertTimeout)
+	defer cancel()
+
+	_, err := pg.db.ExecContext(ctx,
+		"DELETE FROM idempotency_keys WHERE tenant_id = $1 AND principal = $2 AND key = $3",
+		key.Tenant, key.Principal, key.Key)
+	if err != nil {
+		return fmt.Errorf("error query releaseIdempotencyKey: %w", err)
+	}
+
+	return nil
+}
+
+// PruneIdempotencyKeys removes keys past their retention.
+func (pg *PostgresStore) PruneIdempotencyKeys(parentCtx context.Context) error {
+	ctx, span := pg.tracer.Start(parentCtx, "pruneidempotencykeys")
+	defer span.End()
+
+	ctx, cancel := context.WithTimeout(ctx, insertTimeout)
+	defer cancel()
+
+	_, err := pg.db.ExecContext(ctx, "DELETE FROM idempotency_keys WHERE expires_at < now()")
+	if err != nil {
+		return fmt.Errorf("error query pruneIdempotencyKeys: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,65 @@
+package links
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/jacekdobrowolski/goshort/pkg/ratelimit"
+)
+
+// rateLimitRetention is how long idle buckets are kept, longer than any
+// bucket takes to refill.
+const rateLimitRetention = 24 * time.Hour
+
+// takeToken refills the bucket for the time elapsed since the last request
+// and takes a token if one is available, in a single statement so replicas
+// sharing the table cannot race.
+const takeToken = `
+INSERT INTO rate_limits AS rl (key, tokens, allowed, updated_at)
+VALUES ($1, $2::float8 - 1, true, now())
+ON CONFLICT (key) DO UPDATE SET
+    tokens = CASE
+        WHEN LEAST($2, rl.tokens + EXTRACT(EPOCH FROM now() - rl.updated_at) * $3) >= 1
+        THEN LEAST($2, rl.tokens + EXTRACT(EPOCH FROM now() - rl.updated_at) * $3) - 1
+        ELSE LEAST($2, rl.tokens + EXTRACT(EPOCH FROM now() - rl.updated_at) * $3)
+    END,
+    allowed = LEAST($2, rl.tokens + EXTRACT(EPOCH FROM now() - rl.updated_at) * $3) >= 1,
+    updated_at = now()
+RETURNING tokens, allowed`
+
+// Allow implements ratelimit.Limiter so limits hold across replicas.
+func (pg *PostgresStore) Allow(parentCtx context.Context, key string, limit ratelimit.Limit) (ratelimit.Result, error) {
+	ctx, span := pg.tracer.Start(parentCtx, "ratelimit")
+	defer span.End()
+
+	ctx, cancel := context.WithTimeout(ctx, selectTimeout)
+	defer cancel()
+
+	var bucket struct {
+		Tokens  float64 `db:"tokens"`
+		Allowed bool    `db:"allowed"`
+	}
+
+	if err := pg.db.GetContext(ctx, &bucket, takeToken, key, limit.Burst, limit.Rate); err != nil {
+		return ratelimit.Result{}, fmt.Errorf("error query rateLimit: %w", err)
+	}
+
+	return ratelimit.ResultFromTokens(bucket.Allowed, bucket.Tokens, limit), nil
+}
+
+// PruneRateLimits removes buckets idle for longer than rateLimitRetention.
+func (pg *PostgresStore) PruneRateLimits(parentCtx context.Context) error {
+	ctx, span := pg.tracer.Start(parentCtx, "pruneratelimits")
+	defer span.End()
+
+	ctx, cancel := context.WithTimeout(ctx, insertTimeout)
+	defer cancel()
+
+	_, err := pg.db.ExecContext(ctx, "DELETE FROM rate_limits WHERE updated_at < $1", time.Now().Add(-rateLimitRetention))
+	if err != nil {
+		return fmt.Errorf("error query pruneRateLimits: %w", err)
+	}
+
+	return nil
+}
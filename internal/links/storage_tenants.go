@@ -0,0 +1,83 @@
+package links
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+type Tenant struct {
+	ID        string        `db:"id"`
+	Name      string        `db:"name"`
+	MaxLinks  sql.NullInt64 `db:"max_links"`
+	CreatedAt time.Time     `db:"created_at"`
+}
+
+// CreateTenant inserts the tenant together with its domains, neither is
+// created when one of them fails.
+func (pg *PostgresStore) CreateTenant(parentCtx context.Context, tenant Tenant, domains ...Domain) error {
+	ctx, span := pg.tracer.Start(parentCtx, "createtenant")
+	defer span.End()
+
+	ctx, cancel := context.WithTimeout(ctx, insertTimeout)
+	defer cancel()
+
+	tx, err := pg.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("error starting createTenant transaction: %w", err)
+	}
+
+	defer func() { _ = tx.Rollback() }()
+
+	_, err = tx.ExecContext(ctx,
+		"INSERT INTO tenants (id, name, max_links) VALUES ($1, $2, $3)",
+		tenant.ID, tenant.Name, tenant.MaxLinks,
+	)
+	if err != nil {
+		return fmt.Errorf("error query createTenant: %w", err)
+	}
+
+	for _, domain := range domains {
+		_, err = tx.ExecContext(ctx,
+			"INSERT INTO domains (host, tenant_id, base_url) VALUES ($1, $2, $3)",
+			domain.Host, domain.Tenant, domain.BaseURL,
+		)
+
+		var pqErr *pq.Error
+		if errors.As(err, &pqErr) && pqErr.Code == uniqueViolation {
+			return fmt.Errorf("%w: %s", ErrDomainExists, domain.Host)
+		}
+
+		if err != nil {
+			return fmt.Errorf("error query createDomain: %w", err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("error committing createTenant: %w", err)
+	}
+
+	return nil
+}
+
+func (pg *PostgresStore) ListTenants(parentCtx context.Context) ([]Tenant, error) {
+	ctx, span := pg.tracer.Start(parentCtx, "listtenants")
+	defer span.End()
+
+	ctx, cancel := context.WithTimeout(ctx, selectTimeout)
+	defer cancel()
+
+	var tenants []Tenant
+
+	err := pg.db.SelectContext(ctx, &tenants,
+		"SELECT id, name, max_links, created_at FROM tenants ORDER BY id")
+	if err != nil {
+		return nil, fmt.Errorf("error executing query listTenants: %w", err)
+	}
+
+	return tenants, nil
+}
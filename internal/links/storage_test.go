@@ -0,0 +1,112 @@
+package links_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/jacekdobrowolski/goshort/internal/links"
+)
+
+// postgresStore connects to the database from LINKS_POSTGRES_* variables,
+// tests using it are skipped without one.
+func postgresStore(t *testing.T) *links.PostgresStore {
+	t.Helper()
+
+	if os.Getenv("LINKS_POSTGRES_HOST") == "" {
+		t.Skip("LINKS_POSTGRES_HOST is not set")
+	}
+
+	logger := slog.New(slog.NewTextHandler(io.Discard, &slog.HandlerOptions{Level: slog.LevelError}))
+
+	store, err := links.NewPostgresStore(context.Background(), fmt.Sprintf(
+		"user=%s password=%s dbname=%s sslmode=disable host=%s port=%s",
+		os.Getenv("LINKS_POSTGRES_USER"),
+		os.Getenv("LINKS_POSTGRES_PASSWORD"),
+		os.Getenv("LINKS_POSTGRES_DBNAME"),
+		os.Getenv("LINKS_POSTGRES_HOST"),
+		os.Getenv("LINKS_POSTGRES_PORT"),
+	), logger)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return store
+}
+
+func Test_postgresRecordClickLimit(t *testing.T) {
+	t.Parallel()
+
+	store := postgresStore(t)
+	ctx := context.Background()
+	tenant := "test-" + strconv.FormatInt(time.Now().UnixNano(), 36)
+
+	err := store.AddLink(ctx, links.StoredLink{
+		Tenant:          tenant,
+		Short:           "limited",
+		Original:        "http://example.com/",
+		MaxClicks:       10,
+		RemainingClicks: 10,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Cleanup(func() {
+		if err := store.DeleteLink(ctx, tenant, "", "limited"); err != nil {
+			t.Error(err)
+		}
+	})
+
+	var (
+		wg        sync.WaitGroup
+		mu        sync.Mutex
+		recorded  int
+		exhausted int
+	)
+
+	// the conditional update is what keeps concurrent redirects within the limit
+	for range 50 {
+		wg.Add(1)
+
+		go func() {
+			defer wg.Done()
+
+			err := store.RecordClick(ctx, tenant, "", "limited", links.Click{})
+
+			mu.Lock()
+			defer mu.Unlock()
+
+			switch {
+			case err == nil:
+				recorded++
+			case errors.Is(err, links.ErrClicksExhausted):
+				exhausted++
+			default:
+				t.Error(err)
+			}
+		}()
+	}
+
+	wg.Wait()
+
+	if recorded != 10 || exhausted != 40 {
+		t.Errorf("expected 10 recorded and 40 exhausted clicks got %d and %d", recorded, exhausted)
+	}
+
+	link, err := store.GetLink(ctx, tenant, "", "limited")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if link.RemainingClicks != 0 || link.Clicks != 10 {
+		t.Errorf("expected 0 remaining and 10 clicks got %d and %d", link.RemainingClicks, link.Clicks)
+	}
+}
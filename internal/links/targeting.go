@@ -0,0 +1,156 @@
+package links
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"net/netip"
+	"strings"
+
+	"github.com/jacekdobrowolski/goshort/pkg/geoip"
+	"github.com/jacekdobrowolski/goshort/pkg/urlpolicy"
+	"github.com/jacekdobrowolski/goshort/pkg/useragent"
+)
+
+const maxTargetRules = 32
+
+var (
+	errTooManyTargets  = errors.New("at most 32 targets are allowed")
+	errTargetCondition = errors.New("target needs at least one condition")
+	errTargetOS        = errors.New("unknown os")
+	errTargetDevice    = errors.New("device must be mobile, tablet or desktop")
+	errTargetCountry   = errors.New("country must be an ISO 3166-1 alpha-2 code")
+	errTargetRegion    = errors.New("region must be an ISO 3166-2 code e.g. US-CA")
+)
+
+// TargetRule sends visitors matching all of its conditions to URL instead
+// of the link destination, empty conditions match everyone. Country and
+// region rules need a GeoIP database.
+type TargetRule struct {
+	// OS is ios, android, windows, macos, linux, chromeos or other.
+	OS string `json:"os,omitempty"`
+	// Device is mobile, tablet or desktop.
+	Device string `json:"device,omitempty"`
+	// Bot matches crawlers and link preview fetchers when true and
+	// everyone else when false.
+	Bot *bool `json:"bot,omitempty"`
+	// Country is the ISO 3166-1 alpha-2 code of the visitor IP e.g. "DE".
+	Country string `json:"country,omitempty"`
+	// Region is the ISO 3166-2 code of the visitor IP e.g. "US-CA".
+	Region string `json:"region,omitempty"`
+	URL    string `json:"url"`
+}
+
+// visitor is what target rules match on.
+type visitor struct {
+	agent useragent.Agent
+	// addr is the client IP, invalid when unknown.
+	addr     netip.Addr
+	location geoip.Location
+}
+
+func (t TargetRule) matches(v visitor) bool {
+	return (t.OS == "" || t.OS == v.agent.OS) &&
+		(t.Device == "" || t.Device == v.agent.Device) &&
+		(t.Bot == nil || *t.Bot == v.agent.Bot) &&
+		(t.Country == "" || strings.EqualFold(t.Country, v.location.Country)) &&
+		(t.Region == "" || strings.EqualFold(t.Region, v.location.Region))
+}
+
+// TargetRules are evaluated in order, the first match wins and visitors
+// matching none go to the link destination. They are stored as JSON.
+type TargetRules []TargetRule
+
+func (t TargetRules) validate(ctx context.Context, policy *urlpolicy.Policy) error {
+	if len(t) > maxTargetRules {
+		return errTooManyTargets
+	}
+
+	for i, rule := range t {
+		var err error
+
+		switch {
+		case rule.OS == "" && rule.Device == "" && rule.Bot == nil && rule.Country == "" && rule.Region == "":
+			err = errTargetCondition
+		case rule.OS != "" && !useragent.ValidOS(rule.OS):
+			err = errTargetOS
+		case rule.Device != "" && !useragent.ValidDevice(rule.Device):
+			err = errTargetDevice
+		case rule.Country != "" && !validCountry(rule.Country):
+			err = errTargetCountry
+		case rule.Region != "" && !validRegion(rule.Region):
+			err = errTargetRegion
+		default:
+			err = checkDestination(ctx, policy, rule.URL)
+		}
+
+		if err != nil {
+			return fmt.Errorf("targets[%d]: %w", i, err)
+		}
+	}
+
+	return nil
+}
+
+// located reports whether any rule needs the visitor location.
+func (t TargetRules) located() bool {
+	for _, rule := range t {
+		if rule.Country != "" || rule.Region != "" {
+			return true
+		}
+	}
+
+	return false
+}
+
+// destination returns the URL of the first rule matching v, false when
+// none does.
+func (t TargetRules) destination(v visitor) (string, bool) {
+	for _, rule := range t {
+		if rule.matches(v) {
+			return rule.URL, true
+		}
+	}
+
+	return "", false
+}
+
+func (t TargetRules) Value() (driver.Value, error) {
+	return jsonColumnValue(t, len(t) == 0)
+}
+
+func (t *TargetRules) Scan(src any) error {
+	return scanJSONColumn(src, t)
+}
+
+func validCountry(code string) bool {
+	if len(code) != 2 {
+		return false
+	}
+
+	for _, c := range code {
+		if !('a' <= c && c <= 'z' || 'A' <= c && c <= 'Z') {
+			return false
+		}
+	}
+
+	return true
+}
+
+// validRegion accepts ISO 3166-2 codes, a country code and up to three
+// letters or digits.
+func validRegion(code string) bool {
+	country, subdivision, ok := strings.Cut(code, "-")
+	if !ok || !validCountry(country) || subdivision == "" || len(subdivision) > 3 {
+		return false
+	}
+
+	for _, c := range subdivision {
+		if !('a' <= c && c <= 'z' || 'A' <= c && c <= 'Z' || '0' <= c && c <= '9') {
+			return false
+		}
+	}
+
+	return true
+}
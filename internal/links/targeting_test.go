@@ -0,0 +1,253 @@
+package links_test
+
+import (
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"strings"
+	"testing"
+
+	"github.com/jacekdobrowolski/goshort/internal/links"
+	"github.com/jacekdobrowolski/goshort/pkg/geoip"
+	"github.com/jacekdobrowolski/goshort/pkg/proxy"
+)
+
+// mapLocator locates addresses from a fixed map.
+type mapLocator map[netip.Addr]geoip.Location
+
+func (m mapLocator) Locate(addr netip.Addr) (geoip.Location, error) {
+	return m[addr], nil
+}
+
+func Test_redirectTargets(t *testing.T) {
+	t.Parallel()
+
+	store := newMockStore()
+	logger := slog.New(slog.NewTextHandler(io.Discard, &slog.HandlerOptions{Level: slog.LevelError}))
+	create := links.HandlerCreateLink(logger, store, nil)
+	redirect := links.HandlerRedirect(logger, store, links.RedirectOptions{})
+
+	createTests := []struct {
+		body   string
+		status int
+	}{
+		{
+			body: `{"url":"http://example.com/","alias":"app","targets":[` +
+				`{"bot":true,"url":"http://example.com/about"},` +
+				`{"os":"ios","url":"https://apps.apple.com/app/id1"},` +
+				`{"os":"android","device":"mobile","url":"https://play.google.com/store/apps"}]}`,
+			status: http.StatusCreated,
+		},
+		{body: `{"url":"http://example.com/","targets":[{"url":"http://example.com/a"}]}`, status: http.StatusBadRequest},
+		{
+			body:   `{"url":"http://example.com/","targets":[{"os":"beos","url":"http://a.com/"}]}`,
+			status: http.StatusBadRequest,
+		},
+		{
+			body:   `{"url":"http://example.com/","targets":[{"device":"tv","url":"http://a.com/"}]}`,
+			status: http.StatusBadRequest,
+		},
+		{body: `{"url":"http://example.com/","targets":[{"os":"ios","url":"not a url"}]}`, status: http.StatusBadRequest},
+	}
+
+	for _, tt := range createTests {
+		r := httptest.NewRequest(http.MethodPost, "/api/v1/links", strings.NewReader(tt.body))
+		r.Header.Add("Content-Type", "application/json")
+
+		w := httptest.NewRecorder()
+		create(w, r)
+
+		if w.Code != tt.status {
+			t.Fatalf("%s expected StatusCode %d got %d", tt.body, tt.status, w.Code)
+		}
+	}
+
+	tests := []struct {
+		name      string
+		userAgent string
+		location  string
+	}{
+		{
+			name:      "iphone",
+			userAgent: "Mozilla/5.0 (iPhone; CPU iPhone OS 17_4 like Mac OS X) Mobile/15E148 Safari/604.1",
+			location:  "https://apps.apple.com/app/id1",
+		},
+		{
+			name:      "android phone",
+			userAgent: "Mozilla/5.0 (Linux; Android 14; Pixel 8) Chrome/124.0.0.0 Mobile Safari/537.36",
+			location:  "https://play.google.com/store/apps",
+		},
+		{
+			name:      "android tablet",
+			userAgent: "Mozilla/5.0 (Linux; Android 13; SM-X710) Chrome/124.0.0.0 Safari/537.36",
+			location:  "http://example.com/",
+		},
+		{
+			name:      "desktop",
+			userAgent: "Mozilla/5.0 (Windows NT 10.0; Win64; x64; rv:125.0) Gecko/20100101 Firefox/125.0",
+			location:  "http://example.com/",
+		},
+		{
+			name:      "crawler",
+			userAgent: "Mozilla/5.0 (compatible; Googlebot/2.1; +http://www.google.com/bot.html)",
+			location:  "http://example.com/about",
+		},
+	}
+
+	for _, tt := range tests {
+		r := httptest.NewRequest(http.MethodGet, "/app", nil)
+		r.Header.Set("User-Agent", tt.userAgent)
+		r.SetPathValue("short", "app")
+
+		w := httptest.NewRecorder()
+		redirect(w, r)
+
+		if w.Code != http.StatusTemporaryRedirect {
+			t.Fatalf("%s expected StatusCode %d got %d", tt.name, http.StatusTemporaryRedirect, w.Code)
+		}
+
+		if location := w.Header().Get("Location"); location != tt.location {
+			t.Errorf("%s expected Location %q got %q", tt.name, tt.location, location)
+		}
+
+		if vary := w.Header().Get("Vary"); vary != "User-Agent" {
+			t.Errorf("%s expected Vary User-Agent got %q", tt.name, vary)
+		}
+	}
+}
+
+func Test_redirectCountryTargets(t *testing.T) {
+	t.Parallel()
+
+	store := newMockStore()
+	logger := slog.New(slog.NewTextHandler(io.Discard, &slog.HandlerOptions{Level: slog.LevelError}))
+	create := links.HandlerCreateLink(logger, store, nil)
+	redirect := links.HandlerRedirect(logger, store, links.RedirectOptions{
+		Locator: mapLocator{
+			netip.MustParseAddr("81.2.69.160"):   {Country: "GB", Region: "GB-ENG"},
+			netip.MustParseAddr("2.125.160.216"): {Country: "DE"},
+			netip.MustParseAddr("198.51.100.7"):  {Country: "US", Region: "US-CA"},
+		},
+		TrustedProxies: proxy.Trusted{Networks: []netip.Prefix{netip.MustParsePrefix("10.0.0.0/8")}},
+	})
+
+	body := `{"url":"http://example.com/","alias":"sale","redirectStatus":308,"targets":[` +
+		`{"region":"US-CA","url":"http://example.com/us/ca"},` +
+		`{"country":"gb","url":"http://example.co.uk/"},` +
+		`{"country":"DE","device":"mobile","url":"http://m.example.de/"}]}`
+
+	for _, tt := range []struct {
+		body   string
+		status int
+	}{
+		{body: body, status: http.StatusCreated},
+		{
+			body:   `{"url":"http://example.com/","targets":[{"country":"GBR","url":"http://a.com/"}]}`,
+			status: http.StatusBadRequest,
+		},
+		{
+			body:   `{"url":"http://example.com/","targets":[{"region":"CA","url":"http://a.com/"}]}`,
+			status: http.StatusBadRequest,
+		},
+	} {
+		r := httptest.NewRequest(http.MethodPost, "/api/v1/links", strings.NewReader(tt.body))
+		r.Header.Add("Content-Type", "application/json")
+
+		w := httptest.NewRecorder()
+		create(w, r)
+
+		if w.Code != tt.status {
+			t.Fatalf("%s expected StatusCode %d got %d", tt.body, tt.status, w.Code)
+		}
+	}
+
+	tests := []struct {
+		remoteAddr   string
+		forwardedFor string
+		userAgent    string
+		location     string
+		cacheControl string
+	}{
+		{
+			remoteAddr:   "81.2.69.160:1234",
+			location:     "http://example.co.uk/",
+			cacheControl: "private, max-age=0",
+		},
+		{
+			remoteAddr:   "10.1.1.1:1234",
+			forwardedFor: "198.51.100.7",
+			location:     "http://example.com/us/ca",
+			cacheControl: "private, max-age=0",
+		},
+		{
+			remoteAddr:   "2.125.160.216:1234",
+			userAgent:    "Mozilla/5.0 (Linux; Android 14; Pixel 8) Chrome/124.0.0.0 Mobile Safari/537.36",
+			location:     "http://m.example.de/",
+			cacheControl: "private, max-age=0",
+		},
+		{
+			remoteAddr:   "2.125.160.216:1234",
+			location:     "http://example.com/",
+			cacheControl: "private, max-age=0",
+		},
+		{
+			// forwarding headers from untrusted peers are ignored
+			remoteAddr:   "192.0.2.1:1234",
+			forwardedFor: "198.51.100.7",
+			location:     "http://example.com/",
+			cacheControl: "private, max-age=0",
+		},
+	}
+
+	for _, tt := range tests {
+		r := httptest.NewRequest(http.MethodGet, "/sale", nil)
+		r.RemoteAddr = tt.remoteAddr
+		r.Header.Set("User-Agent", tt.userAgent)
+
+		if tt.forwardedFor != "" {
+			r.Header.Set("X-Forwarded-For", tt.forwardedFor)
+		}
+
+		r.SetPathValue("short", "sale")
+
+		w := httptest.NewRecorder()
+		redirect(w, r)
+
+		if w.Code != http.StatusPermanentRedirect {
+			t.Fatalf("%s expected StatusCode %d got %d", tt.remoteAddr, http.StatusPermanentRedirect, w.Code)
+		}
+
+		if location := w.Header().Get("Location"); location != tt.location {
+			t.Errorf("%s expected Location %q got %q", tt.remoteAddr, tt.location, location)
+		}
+
+		if cacheControl := w.Header().Get("Cache-Control"); cacheControl != tt.cacheControl {
+			t.Errorf("%s expected Cache-Control %q got %q", tt.remoteAddr, tt.cacheControl, cacheControl)
+		}
+	}
+
+	r := httptest.NewRequest(http.MethodGet, "/api/v1/links/sale/stats", nil)
+	r.SetPathValue("short", "sale")
+
+	w := httptest.NewRecorder()
+	links.HandlerLinkStats(logger, store)(w, r)
+
+	stats := links.LinkStats{}
+	if err := json.NewDecoder(w.Body).Decode(&stats); err != nil {
+		t.Fatal(err)
+	}
+
+	want := map[string]int64{"GB": 1, "US": 1, "DE": 2}
+	for country, clicks := range want {
+		if stats.Countries[country] != clicks {
+			t.Errorf("expected %d clicks from %s got %d", clicks, country, stats.Countries[country])
+		}
+	}
+
+	if stats.Clicks != 5 || len(stats.Countries) != len(want) {
+		t.Errorf("expected 5 clicks from %v got %d from %v", want, stats.Clicks, stats.Countries)
+	}
+}
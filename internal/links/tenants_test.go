@@ -0,0 +1,140 @@
+package links_test
+
+import (
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/jacekdobrowolski/goshort/internal/auth"
+	"github.com/jacekdobrowolski/goshort/internal/links"
+)
+
+func asTenant(r *http.Request, tenant string) *http.Request {
+	return r.WithContext(auth.WithPrincipal(r.Context(), &auth.Principal{
+		ID:     tenant + "-key",
+		Tenant: tenant,
+		Scopes: []auth.Scope{auth.ScopeCreate, auth.ScopeRead, auth.ScopeDelete, auth.ScopeStats},
+	}))
+}
+
+func createAlias(t *testing.T, handler http.HandlerFunc, tenant, alias, target string) int {
+	t.Helper()
+
+	r := httptest.NewRequest(http.MethodPost, "/api/v1/links",
+		strings.NewReader(`{"url":"`+target+`","alias":"`+alias+`"}`))
+	r.Header.Add("Content-Type", "application/json")
+
+	w := httptest.NewRecorder()
+	handler(w, asTenant(r, tenant))
+
+	return w.Code
+}
+
+func Test_tenantIsolation(t *testing.T) {
+	t.Parallel()
+
+	store := newMockStore()
+	store.domains["go.acme.test"] = links.Domain{Host: "go.acme.test", Tenant: "acme", BaseURL: "https://go.acme.test"}
+	logger := slog.New(slog.NewTextHandler(io.Discard, &slog.HandlerOptions{Level: slog.LevelError}))
+
+	create := links.HandlerCreateLink(logger, store, nil)
+
+	if status := createAlias(t, create, "acme", "promo", "http://acme.test/promo"); status != http.StatusCreated {
+		t.Fatalf("expected StatusCode %d got %d", http.StatusCreated, status)
+	}
+
+	if status := createAlias(t, create, "globex", "promo", "http://globex.test/promo"); status != http.StatusCreated {
+		t.Fatalf("same alias in other tenant expected StatusCode %d got %d", http.StatusCreated, status)
+	}
+
+	if status := createAlias(t, create, "acme", "promo", "http://acme.test/other"); status != http.StatusConflict {
+		t.Errorf("duplicate alias expected StatusCode %d got %d", http.StatusConflict, status)
+	}
+
+	t.Run("get is scoped to tenant", func(t *testing.T) {
+		t.Parallel()
+
+		r := httptest.NewRequest(http.MethodGet, "/api/v1/links/promo", nil)
+		r.SetPathValue("short", "promo")
+
+		w := httptest.NewRecorder()
+		links.HandlerGetLink(logger, store)(w, asTenant(r, "globex"))
+
+		link := links.Link{}
+		if err := json.NewDecoder(w.Body).Decode(&link); err != nil {
+			t.Fatal(err)
+		}
+
+		if link.Original != "http://globex.test/promo" {
+			t.Errorf("expected globex link got %s", link.Original)
+		}
+	})
+
+	t.Run("other tenant cannot delete", func(t *testing.T) {
+		t.Parallel()
+
+		r := httptest.NewRequest(http.MethodDelete, "/api/v1/links/promo", nil)
+		r.SetPathValue("short", "promo")
+
+		w := httptest.NewRecorder()
+		links.HandlerDeleteLink(logger, store)(w, asTenant(r, "initech"))
+
+		if w.Code != http.StatusNotFound {
+			t.Errorf("expected StatusCode %d got %d", http.StatusNotFound, w.Code)
+		}
+	})
+
+	t.Run("list is scoped to tenant", func(t *testing.T) {
+		t.Parallel()
+
+		r := httptest.NewRequest(http.MethodGet, "/api/v1/links", nil)
+
+		w := httptest.NewRecorder()
+		links.HandlerListLinks(logger, store)(w, asTenant(r, "acme"))
+
+		var listed []links.Link
+		if err := json.NewDecoder(w.Body).Decode(&listed); err != nil {
+			t.Fatal(err)
+		}
+
+		if len(listed) != 1 || listed[0].Original != "http://acme.test/promo" {
+			t.Errorf("unexpected list %v", listed)
+		}
+	})
+
+	t.Run("redirect resolves tenant by host", func(t *testing.T) {
+		t.Parallel()
+
+		r := httptest.NewRequest(http.MethodGet, "http://go.acme.test/promo", nil)
+		r.SetPathValue("short", "promo")
+
+		w := httptest.NewRecorder()
+		links.HandlerRedirect(logger, store, links.RedirectOptions{})(w, r)
+
+		if location := w.Header().Get("Location"); location != "http://acme.test/promo" {
+			t.Errorf("expected redirect to acme link got %q", location)
+		}
+	})
+}
+
+func Test_tenantQuota(t *testing.T) {
+	t.Parallel()
+
+	store := newMockStore()
+	store.limits["small"] = 1
+	logger := slog.New(slog.NewTextHandler(io.Discard, &slog.HandlerOptions{Level: slog.LevelError}))
+
+	create := links.HandlerCreateLink(logger, store, nil)
+
+	if status := createAlias(t, create, "small", "a", "http://example.com/a"); status != http.StatusCreated {
+		t.Fatalf("expected StatusCode %d got %d", http.StatusCreated, status)
+	}
+
+	if status := createAlias(t, create, "small", "b", "http://example.com/b"); status != http.StatusForbidden {
+		t.Errorf("expected StatusCode %d got %d", http.StatusForbidden, status)
+	}
+}
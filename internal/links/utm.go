@@ -0,0 +1,131 @@
+package links
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+	"unicode"
+)
+
+const maxUTMLength = 256
+
+var (
+	errUTMRequired = errors.New("utm source, medium and campaign are required")
+	errUTMTooLong  = errors.New("longer than 256 bytes")
+	errUTMControl  = errors.New("contains control characters")
+)
+
+// UTM holds campaign parameters merged into the destination on create and
+// stored separately for campaign reporting.
+type UTM struct {
+	Source   string `db:"source"   json:"source,omitempty"`
+	Medium   string `db:"medium"   json:"medium,omitempty"`
+	Campaign string `db:"campaign" json:"campaign,omitempty"`
+	Term     string `db:"term"     json:"term,omitempty"`
+	Content  string `db:"content"  json:"content,omitempty"`
+}
+
+// CampaignStats aggregates links sharing UTM source, medium and campaign.
+type CampaignStats struct {
+	Source   string `db:"source"   json:"source"`
+	Medium   string `db:"medium"   json:"medium"`
+	Campaign string `db:"campaign" json:"campaign"`
+	Links    int64  `db:"links"    json:"links"`
+	Clicks   int64  `db:"clicks"   json:"clicks"`
+}
+
+func (u UTM) params() [][2]string {
+	return [][2]string{
+		{"utm_source", u.Source},
+		{"utm_medium", u.Medium},
+		{"utm_campaign", u.Campaign},
+		{"utm_term", u.Term},
+		{"utm_content", u.Content},
+	}
+}
+
+func (u UTM) validate() error {
+	if u.Source == "" || u.Medium == "" || u.Campaign == "" {
+		return errUTMRequired
+	}
+
+	for _, param := range u.params() {
+		if len(param[1]) > maxUTMLength {
+			return fmt.Errorf("%s %w", param[0], errUTMTooLong)
+		}
+
+		for _, c := range param[1] {
+			if unicode.IsControl(c) {
+				return fmt.Errorf("%s %w", param[0], errUTMControl)
+			}
+		}
+	}
+
+	return nil
+}
+
+// apply sets the UTM parameters on destination replacing existing ones,
+// the rest of the query is kept as it was encoded.
+func (u UTM) apply(destination string) (string, error) {
+	target, err := url.Parse(destination)
+	if err != nil {
+		return "", fmt.Errorf("error parsing destination: %w", err)
+	}
+
+	set := make(map[string]bool)
+
+	var query []string
+
+	for _, param := range u.params() {
+		if param[1] != "" {
+			set[param[0]] = true
+			query = append(query, param[0]+"="+url.QueryEscape(param[1]))
+		}
+	}
+
+	var kept []string
+
+	for _, pair := range strings.Split(target.RawQuery, "&") {
+		key, _, _ := strings.Cut(pair, "=")
+		if name, err := url.QueryUnescape(key); err == nil {
+			key = name
+		}
+
+		if pair != "" && !set[key] {
+			kept = append(kept, pair)
+		}
+	}
+
+	target.RawQuery = strings.Join(append(kept, query...), "&")
+
+	return target.String(), nil
+}
+
+// applyAll applies the parameters to every non-empty destination so the
+// campaign is attributed whichever URL a visitor is sent to.
+func (u UTM) applyAll(destinations ...*string) error {
+	for _, destination := range destinations {
+		if *destination == "" {
+			continue
+		}
+
+		applied, err := u.apply(*destination)
+		if err != nil {
+			return err
+		}
+
+		*destination = applied
+	}
+
+	return nil
+}
+
+// orNil omits UTM from responses of links created without it.
+func (u UTM) orNil() *UTM {
+	if u == (UTM{}) {
+		return nil
+	}
+
+	return &u
+}
@@ -0,0 +1,121 @@
+package links_test
+
+import (
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/jacekdobrowolski/goshort/internal/links"
+)
+
+func Test_createLinkUTM(t *testing.T) {
+	t.Parallel()
+
+	store := newMockStore()
+	logger := slog.New(slog.NewTextHandler(io.Discard, &slog.HandlerOptions{Level: slog.LevelError}))
+	create := links.HandlerCreateLink(logger, store, nil)
+
+	tests := []struct {
+		body     string
+		status   int
+		original string
+	}{
+		{
+			body: `{"url":"http://example.com/?ref=a&utm_source=old","alias":"spring-mail",` +
+				`"utm":{"source":"newsletter","medium":"email","campaign":"spring sale"}}`,
+			status:   http.StatusCreated,
+			original: "http://example.com/?ref=a&utm_source=newsletter&utm_medium=email&utm_campaign=spring+sale",
+		},
+		{
+			body: `{"url":"http://example.com/","alias":"spring-ad",` +
+				`"utm":{"source":"google","medium":"cpc","campaign":"spring sale","term":"shoes"}}`,
+			status:   http.StatusCreated,
+			original: "http://example.com/?utm_source=google&utm_medium=cpc&utm_campaign=spring+sale&utm_term=shoes",
+		},
+		{
+			body: `{"url":"http://example.com/?z=%7E&a=1&utm_term=kept#top","alias":"spring-kept",` +
+				`"utm":{"source":"newsletter","medium":"email","campaign":"spring sale"}}`,
+			status: http.StatusCreated,
+			original: "http://example.com/?z=%7E&a=1&utm_term=kept" +
+				"&utm_source=newsletter&utm_medium=email&utm_campaign=spring+sale#top",
+		},
+		{
+			body:   `{"url":"http://example.com/","utm":{"source":"newsletter"}}`,
+			status: http.StatusBadRequest,
+		},
+		{
+			body:   `{"url":"http://example.com/","utm":{"source":"a\nb","medium":"email","campaign":"x"}}`,
+			status: http.StatusBadRequest,
+		},
+	}
+
+	for _, tt := range tests {
+		r := httptest.NewRequest(http.MethodPost, "/api/v1/links", strings.NewReader(tt.body))
+		r.Header.Add("Content-Type", "application/json")
+
+		w := httptest.NewRecorder()
+		create(w, r)
+
+		if w.Code != tt.status {
+			t.Fatalf("%s expected StatusCode %d got %d", tt.body, tt.status, w.Code)
+		}
+
+		if tt.status != http.StatusCreated {
+			continue
+		}
+
+		link := links.Link{}
+		if err := json.NewDecoder(w.Body).Decode(&link); err != nil {
+			t.Fatal(err)
+		}
+
+		if link.Original != tt.original {
+			t.Errorf("expected destination %q got %q", tt.original, link.Original)
+		}
+
+		if link.UTM == nil || link.UTM.Campaign != "spring sale" {
+			t.Errorf("expected utm in response got %+v", link.UTM)
+		}
+	}
+
+	r := httptest.NewRequest(http.MethodPost, "/api/v1/links", strings.NewReader(`{"url":"http://example.com/",`+
+		`"alias":"spring-targets","targets":[{"bot":true,"url":"http://example.com/bot?x=1"}],`+
+		`"variants":[{"name":"a","url":"http://example.com/a","weight":1},`+
+		`{"name":"b","url":"http://example.com/b","weight":1}],`+
+		`"maxClicks":1,"exhaustedFallback":"http://example.com/over",`+
+		`"utm":{"source":"newsletter","medium":"email","campaign":"spring sale"}}`))
+	r.Header.Add("Content-Type", "application/json")
+
+	w := httptest.NewRecorder()
+	create(w, r)
+
+	link := links.Link{}
+	if err := json.NewDecoder(w.Body).Decode(&link); err != nil {
+		t.Fatal(err)
+	}
+
+	const utm = "utm_source=newsletter&utm_medium=email&utm_campaign=spring+sale"
+
+	if len(link.Targets) != 1 || link.Targets[0].URL != "http://example.com/bot?x=1&"+utm ||
+		len(link.Variants) != 2 || link.Variants[1].URL != "http://example.com/b?"+utm ||
+		link.ExhaustedFallback != "http://example.com/over?"+utm {
+		t.Errorf("expected utm on every destination got %+v", link)
+	}
+
+	r = httptest.NewRequest(http.MethodGet, "/api/v1/campaigns", nil)
+	w = httptest.NewRecorder()
+	links.HandlerCampaignStats(logger, store)(w, r)
+
+	var stats []links.CampaignStats
+	if err := json.NewDecoder(w.Body).Decode(&stats); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(stats) != 2 || stats[0].Campaign != "spring sale" || stats[0].Links != 1 {
+		t.Errorf("expected stats per source and medium got %+v", stats)
+	}
+}
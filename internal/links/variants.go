@@ -0,0 +1,171 @@
+package links
+
+import (
+	"context"
+	"crypto/sha256"
+	"database/sql/driver"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math/rand/v2"
+	"net/http"
+
+	"github.com/jacekdobrowolski/goshort/pkg/urlpolicy"
+)
+
+// Variant assignment modes keeping visitors on the same variant.
+const (
+	// AssignCookie picks a random variant and remembers it in a cookie.
+	AssignCookie = "cookie"
+	// AssignHash picks the variant from a hash of the client IP and
+	// User-Agent without setting cookies.
+	AssignHash = "hash"
+)
+
+const (
+	variantCookiePrefix = "goshort_variant_"
+	variantCookieMaxAge = 30 * 24 * 60 * 60
+	maxVariants         = 16
+	maxVariantWeight    = 1000
+)
+
+var (
+	errVariantCount      = errors.New("between 2 and 16 variants are required")
+	errVariantName       = errors.New("variant name must be 1 to 64 letters, digits, '-' or '_'")
+	errVariantDuplicate  = errors.New("duplicate variant name")
+	errVariantWeight     = errors.New("variant weight must be between 0 and 1000")
+	errVariantZeroWeight = errors.New("at least one variant needs a positive weight")
+	errVariantAssignment = errors.New("variantAssignment must be cookie or hash")
+)
+
+// Variant receives a share of visitors proportional to its weight.
+type Variant struct {
+	Name   string `json:"name"`
+	URL    string `json:"url"`
+	Weight int    `json:"weight"`
+}
+
+// Variants split visitors matching no target across destinations, they are
+// stored as JSON.
+type Variants []Variant
+
+func validVariantAssignment(mode string) bool {
+	return mode == "" || mode == AssignCookie || mode == AssignHash
+}
+
+func (v Variants) validate(ctx context.Context, policy *urlpolicy.Policy) error {
+	if len(v) == 0 {
+		return nil
+	}
+
+	if len(v) < 2 || len(v) > maxVariants {
+		return errVariantCount
+	}
+
+	names := make(map[string]bool, len(v))
+	total := 0
+
+	for i, variant := range v {
+		var err error
+
+		switch {
+		case !validAlias(variant.Name):
+			err = errVariantName
+		case names[variant.Name]:
+			err = errVariantDuplicate
+		case variant.Weight < 0 || variant.Weight > maxVariantWeight:
+			err = errVariantWeight
+		default:
+			err = checkDestination(ctx, policy, variant.URL)
+		}
+
+		if err != nil {
+			return fmt.Errorf("variants[%d]: %w", i, err)
+		}
+
+		names[variant.Name] = true
+		total += variant.Weight
+	}
+
+	if total == 0 {
+		return errVariantZeroWeight
+	}
+
+	return nil
+}
+
+func (v Variants) named(name string) (Variant, bool) {
+	for _, variant := range v {
+		if variant.Name == name && variant.Weight > 0 {
+			return variant, true
+		}
+	}
+
+	return Variant{}, false
+}
+
+// pick returns the variant owning point of the weight range, point is
+// taken modulo the total weight.
+func (v Variants) pick(point uint64) Variant {
+	total := 0
+	for _, variant := range v {
+		total += variant.Weight
+	}
+
+	point %= uint64(total)
+
+	for _, variant := range v {
+		if point < uint64(variant.Weight) {
+			return variant
+		}
+
+		point -= uint64(variant.Weight)
+	}
+
+	return v[len(v)-1]
+}
+
+func (v Variants) Value() (driver.Value, error) {
+	return jsonColumnValue(v, len(v) == 0)
+}
+
+func (v *Variants) Scan(src any) error {
+	return scanJSONColumn(src, v)
+}
+
+// variant returns the variant for the visitor. Cookie assignment keeps a
+// variant named in the cookie while it still has weight, otherwise picks
+// one at random and sets the cookie on GET requests.
+func (h *redirectHandler) variant(w http.ResponseWriter, r *http.Request, link *StoredLink, v visitor) Variant {
+	if link.VariantAssignment == AssignHash {
+		hash := sha256.Sum256([]byte(link.Tenant + "\x00" + link.Short + "\x00" +
+			v.addr.String() + "\x00" + r.UserAgent()))
+
+		return link.Variants.pick(binary.BigEndian.Uint64(hash[:8]))
+	}
+
+	if cookie, err := r.Cookie(variantCookiePrefix + link.Short); err == nil {
+		if variant, ok := link.Variants.named(cookie.Value); ok {
+			return variant
+		}
+	}
+
+	variant := link.Variants.pick(rand.Uint64()) //nolint:gosec // assignment is not security sensitive
+
+	// only visitors following the link are assigned, not HEAD probes
+	if r.Method != http.MethodGet {
+		return variant
+	}
+
+	http.SetCookie(w, &http.Cookie{
+		Name:     variantCookiePrefix + link.Short,
+		Value:    variant.Name,
+		Path:     "/",
+		MaxAge:   variantCookieMaxAge,
+		Secure:   secureOrigin(r),
+		HttpOnly: true,
+		SameSite: http.SameSiteLaxMode,
+	})
+
+	return variant
+}
@@ -0,0 +1,205 @@
+package links_test
+
+import (
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/jacekdobrowolski/goshort/internal/links"
+	"github.com/jacekdobrowolski/goshort/pkg/proxy"
+)
+
+func Test_redirectVariants(t *testing.T) {
+	t.Parallel()
+
+	store := newMockStore()
+	logger := slog.New(slog.NewTextHandler(io.Discard, &slog.HandlerOptions{Level: slog.LevelError}))
+	create := links.HandlerCreateLink(logger, store, nil)
+	redirect := links.HandlerRedirect(logger, store, links.RedirectOptions{})
+
+	variants := `"variants":[{"name":"a","url":"http://example.com/a","weight":1},` +
+		`{"name":"b","url":"http://example.com/b","weight":1},{"name":"off","url":"http://example.com/off","weight":0}]`
+
+	createTests := []struct {
+		body   string
+		status int
+	}{
+		{
+			body:   `{"url":"http://example.com/","alias":"split",` + variants + `}`,
+			status: http.StatusCreated,
+		},
+		{
+			body:   `{"url":"http://example.com/","alias":"hashed","variantAssignment":"hash",` + variants + `}`,
+			status: http.StatusCreated,
+		},
+		{
+			body: `{"url":"http://example.com/","alias":"bots","targets":[{"bot":true,"url":"http://example.com/bot"}],` +
+				variants + `}`,
+			status: http.StatusCreated,
+		},
+		{
+			body:   `{"url":"http://example.com/","variants":[{"name":"a","url":"http://example.com/a","weight":1}]}`,
+			status: http.StatusBadRequest,
+		},
+		{
+			body: `{"url":"http://example.com/","variants":[{"name":"a","url":"http://example.com/a","weight":1},` +
+				`{"name":"a","url":"http://example.com/b","weight":1}]}`,
+			status: http.StatusBadRequest,
+		},
+		{
+			body: `{"url":"http://example.com/","variants":[{"name":"a","url":"http://example.com/a","weight":0},` +
+				`{"name":"b","url":"http://example.com/b","weight":0}]}`,
+			status: http.StatusBadRequest,
+		},
+		{
+			body:   `{"url":"http://example.com/","variantAssignment":"random",` + variants + `}`,
+			status: http.StatusBadRequest,
+		},
+	}
+
+	for _, tt := range createTests {
+		r := httptest.NewRequest(http.MethodPost, "/api/v1/links", strings.NewReader(tt.body))
+		r.Header.Add("Content-Type", "application/json")
+
+		w := httptest.NewRecorder()
+		create(w, r)
+
+		if w.Code != tt.status {
+			t.Fatalf("%s expected StatusCode %d got %d", tt.body, tt.status, w.Code)
+		}
+	}
+
+	get := func(short, userAgent string, cookies ...*http.Cookie) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(http.MethodGet, "/"+short, nil)
+		r.Header.Set("User-Agent", userAgent)
+		r.SetPathValue("short", short)
+
+		for _, cookie := range cookies {
+			r.AddCookie(cookie)
+		}
+
+		w := httptest.NewRecorder()
+		redirect(w, r)
+
+		if w.Code != http.StatusTemporaryRedirect {
+			t.Fatalf("%s expected StatusCode %d got %d", short, http.StatusTemporaryRedirect, w.Code)
+		}
+
+		return w
+	}
+
+	const browser = "Mozilla/5.0 (Windows NT 10.0; Win64; x64; rv:125.0) Gecko/20100101 Firefox/125.0"
+
+	seen := make(map[string]int)
+
+	for range 100 {
+		w := get("split", browser)
+		seen[w.Header().Get("Location")]++
+
+		cookies := w.Result().Cookies()
+		if len(cookies) != 1 || "http://example.com/"+cookies[0].Value != w.Header().Get("Location") {
+			t.Fatalf("expected variant cookie matching %s got %v", w.Header().Get("Location"), cookies)
+		}
+	}
+
+	if seen["http://example.com/a"] == 0 || seen["http://example.com/b"] == 0 || len(seen) != 2 {
+		t.Errorf("expected visitors split across a and b got %v", seen)
+	}
+
+	w := get("split", browser, &http.Cookie{Name: "goshort_variant_split", Value: "b"})
+	if location := w.Header().Get("Location"); location != "http://example.com/b" {
+		t.Errorf("expected sticky variant b got %q", location)
+	}
+
+	if cookies := w.Result().Cookies(); len(cookies) != 0 {
+		t.Errorf("expected no new cookie for assigned visitor got %v", cookies)
+	}
+
+	w = get("split", browser, &http.Cookie{Name: "goshort_variant_split", Value: "off"})
+	if location := w.Header().Get("Location"); location == "http://example.com/off" {
+		t.Error("expected visitor of variant without weight to be reassigned")
+	}
+
+	first := get("hashed", browser).Header().Get("Location")
+	for range 10 {
+		w := get("hashed", browser)
+		if location := w.Header().Get("Location"); location != first {
+			t.Errorf("expected hashed assignment %q got %q", first, location)
+		}
+
+		if cookies := w.Result().Cookies(); len(cookies) != 0 {
+			t.Errorf("expected no cookie with hashed assignment got %v", cookies)
+		}
+	}
+
+	r := httptest.NewRequest(http.MethodHead, "/split", nil)
+	r.SetPathValue("short", "split")
+
+	w = httptest.NewRecorder()
+	redirect(w, r)
+
+	if cookies := w.Result().Cookies(); len(cookies) != 0 {
+		t.Errorf("expected no variant cookie for HEAD got %v", cookies)
+	}
+
+	if location := get("bots", "curl/8.5.0").Header().Get("Location"); location != "http://example.com/bot" {
+		t.Errorf("expected targets to take precedence over variants got %q", location)
+	}
+
+	r = httptest.NewRequest(http.MethodGet, "/api/v1/links/split/stats", nil)
+	r.SetPathValue("short", "split")
+
+	w = httptest.NewRecorder()
+	links.HandlerLinkStats(logger, store)(w, r)
+
+	stats := links.LinkStats{}
+	if err := json.NewDecoder(w.Body).Decode(&stats); err != nil {
+		t.Fatal(err)
+	}
+
+	if stats.Variants["a"]+stats.Variants["b"] != stats.Clicks || stats.Clicks != 102 {
+		t.Errorf("expected 102 clicks split across variants got %d %v", stats.Clicks, stats.Variants)
+	}
+}
+
+func Test_variantCookieBehindProxy(t *testing.T) {
+	t.Parallel()
+
+	store := newMockStore()
+	logger := slog.New(slog.NewTextHandler(io.Discard, &slog.HandlerOptions{Level: slog.LevelError}))
+
+	r := httptest.NewRequest(http.MethodPost, "/api/v1/links", strings.NewReader(`{"url":"http://example.com/",`+
+		`"alias":"split","variants":[{"name":"a","url":"http://example.com/a","weight":1},`+
+		`{"name":"b","url":"http://example.com/b","weight":1}]}`))
+	r.Header.Add("Content-Type", "application/json")
+
+	w := httptest.NewRecorder()
+	links.HandlerCreateLink(logger, store, nil)(w, r)
+
+	if w.Code != http.StatusCreated {
+		t.Fatalf("expected StatusCode %d got %d", http.StatusCreated, w.Code)
+	}
+
+	trusted, err := proxy.ParseTrusted("10.0.0.0/8")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	handler := links.PublicURLMiddleware(links.HandlerRedirect(logger, store, links.RedirectOptions{}), "", trusted)
+
+	r = httptest.NewRequest(http.MethodGet, "http://goshort.test/split", nil)
+	r.Header.Set("X-Forwarded-Proto", "https")
+	r.RemoteAddr = "10.0.0.1:1234"
+	r.SetPathValue("short", "split")
+
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, r)
+
+	if cookies := w.Result().Cookies(); len(cookies) != 1 || !cookies[0].Secure {
+		t.Errorf("expected Secure variant cookie got %v", cookies)
+	}
+}
@@ -0,0 +1,276 @@
+package blocklist
+
+import (
+	"bufio"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/netip"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+var errInvalidPrefix = errors.New("invalid hash prefix, expected 4 to 32 hex encoded bytes")
+
+const (
+	minPrefixLength = 4
+	maxHostSuffixes = 5
+	maxPathPrefixes = 4
+)
+
+// Blocklist matches URLs against domain and hash-prefix lists loaded from
+// local files.
+//
+// Domain files hold one domain per line, a domain also blocks its
+// subdomains. Lines in hosts file format such as "0.0.0.0 evil.test" use
+// the last field.
+//
+// Hash-prefix files hold hex encoded SHA-256 prefixes of 4 to 32 bytes of
+// Safe Browsing URL expressions, "host/path" combinations of the host
+// suffixes and path prefixes of the URL.
+type Blocklist struct {
+	domainFiles []string
+	hashFiles   []string
+
+	lists   atomic.Pointer[lists]
+	modTime map[string]time.Time
+}
+
+type lists struct {
+	domains map[string]string
+	// hashes maps prefixes to the file they were loaded from
+	hashes        map[string]string
+	prefixLengths []int
+}
+
+func New(domainFiles, hashFiles []string) (*Blocklist, error) {
+	b := &Blocklist{
+		domainFiles: domainFiles,
+		hashFiles:   hashFiles,
+		modTime:     make(map[string]time.Time),
+	}
+
+	if err := b.Load(); err != nil {
+		return nil, err
+	}
+
+	return b, nil
+}
+
+// Load reads all files, the previous lists stay active on error.
+func (b *Blocklist) Load() error {
+	loaded := &lists{
+		domains: make(map[string]string),
+		hashes:  make(map[string]string),
+	}
+
+	lengths := make(map[int]bool)
+
+	for _, file := range b.domainFiles {
+		err := readLines(file, func(line string) error {
+			fields := strings.Fields(line)
+			loaded.domains[canonicalHost(fields[len(fields)-1])] = filepath.Base(file)
+
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	for _, file := range b.hashFiles {
+		err := readLines(file, func(line string) error {
+			prefix, err := hex.DecodeString(line)
+			if err != nil || len(prefix) < minPrefixLength || len(prefix) > sha256.Size {
+				return fmt.Errorf("%w: %q", errInvalidPrefix, line)
+			}
+
+			loaded.hashes[string(prefix)] = filepath.Base(file)
+			lengths[len(prefix)] = true
+
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	for length := range lengths {
+		loaded.prefixLengths = append(loaded.prefixLengths, length)
+	}
+
+	b.lists.Store(loaded)
+
+	return nil
+}
+
+// Watch reloads the lists whenever a file modification time changes until
+// ctx is done.
+func (b *Blocklist) Watch(ctx context.Context, interval time.Duration, logger *slog.Logger) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	b.changed()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if !b.changed() {
+				continue
+			}
+
+			if err := b.Load(); err != nil {
+				logger.ErrorContext(ctx, "error reloading blocklist", slog.String("err", err.Error()))
+
+				continue
+			}
+
+			logger.InfoContext(ctx, "blocklist reloaded")
+		}
+	}
+}
+
+func (b *Blocklist) changed() bool {
+	changed := false
+
+	for _, file := range append(append([]string{}, b.domainFiles...), b.hashFiles...) {
+		info, err := os.Stat(file)
+		if err != nil {
+			continue
+		}
+
+		if !info.ModTime().Equal(b.modTime[file]) {
+			b.modTime[file] = info.ModTime()
+			changed = true
+		}
+	}
+
+	return changed
+}
+
+// Blocked returns the name of the list matching u.
+func (b *Blocklist) Blocked(u *url.URL) (string, bool) {
+	loaded := b.lists.Load()
+	host := canonicalHost(u.Hostname())
+
+	for _, suffix := range domainSuffixes(host) {
+		if list, ok := loaded.domains[suffix]; ok {
+			return list, true
+		}
+	}
+
+	if len(loaded.hashes) == 0 {
+		return "", false
+	}
+
+	for _, expression := range Expressions(u) {
+		hash := sha256.Sum256([]byte(expression))
+
+		for _, length := range loaded.prefixLengths {
+			if list, ok := loaded.hashes[string(hash[:length])]; ok {
+				return list, true
+			}
+		}
+	}
+
+	return "", false
+}
+
+// Expressions returns the host suffix and path prefix combinations of u
+// hashed for hash-prefix lookups.
+func Expressions(u *url.URL) []string {
+	host := canonicalHost(u.Hostname())
+
+	hosts := []string{host}
+	if _, err := netip.ParseAddr(host); err != nil {
+		suffixes := domainSuffixes(host)
+		// skip the exact host and the top level domain
+		for i := max(1, len(suffixes)-maxHostSuffixes); i < len(suffixes)-1; i++ {
+			hosts = append(hosts, suffixes[i])
+		}
+	}
+
+	urlPath := u.EscapedPath()
+	if urlPath == "" {
+		urlPath = "/"
+	}
+
+	paths := []string{}
+	if u.RawQuery != "" {
+		paths = append(paths, urlPath+"?"+u.RawQuery)
+	}
+
+	paths = append(paths, urlPath)
+
+	prefix := "/"
+	for i, segment := range strings.Split(strings.Trim(urlPath, "/"), "/") {
+		if i >= maxPathPrefixes || prefix == urlPath {
+			break
+		}
+
+		paths = append(paths, prefix)
+		prefix += segment + "/"
+	}
+
+	expressions := make([]string, 0, len(hosts)*len(paths))
+
+	for _, h := range hosts {
+		for _, p := range paths {
+			expressions = append(expressions, h+p)
+		}
+	}
+
+	return expressions
+}
+
+// domainSuffixes returns host followed by its parent domains.
+func domainSuffixes(host string) []string {
+	suffixes := []string{host}
+
+	for i := range len(host) {
+		if host[i] == '.' {
+			suffixes = append(suffixes, host[i+1:])
+		}
+	}
+
+	return suffixes
+}
+
+func canonicalHost(host string) string {
+	return strings.Trim(strings.ToLower(host), ".")
+}
+
+func readLines(file string, handle func(line string) error) error {
+	f, err := os.Open(file)
+	if err != nil {
+		return fmt.Errorf("error opening blocklist: %w", err)
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		if err := handle(line); err != nil {
+			return fmt.Errorf("error parsing blocklist %s: %w", file, err)
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("error reading blocklist %s: %w", file, err)
+	}
+
+	return nil
+}
@@ -0,0 +1,132 @@
+package blocklist_test
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"net/url"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+
+	"github.com/jacekdobrowolski/goshort/pkg/blocklist"
+)
+
+func writeList(t *testing.T, name, content string) string {
+	t.Helper()
+
+	file := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(file, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	return file
+}
+
+func blocked(t *testing.T, list *blocklist.Blocklist, raw string) string {
+	t.Helper()
+
+	u, err := url.Parse(raw)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	name, _ := list.Blocked(u)
+
+	return name
+}
+
+func Test_BlocklistDomains(t *testing.T) {
+	t.Parallel()
+
+	domains := writeList(t, "phishing.txt", "# comment\nevil.test\n0.0.0.0 Tracker.Example.\n")
+
+	list, err := blocklist.New([]string{domains}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for raw, want := range map[string]string{
+		"http://evil.test/login":         "phishing.txt",
+		"https://www.evil.test/":         "phishing.txt",
+		"https://tracker.example/pixel":  "phishing.txt",
+		"https://notevil.test/":          "",
+		"https://example/":               "",
+		"https://evil.test.example.com/": "",
+	} {
+		if got := blocked(t, list, raw); got != want {
+			t.Errorf("%s expected %q got %q", raw, want, got)
+		}
+	}
+}
+
+func Test_BlocklistHashPrefixes(t *testing.T) {
+	t.Parallel()
+
+	hash := sha256.Sum256([]byte("malware.test/downloads/"))
+	prefixes := writeList(t, "malware.txt", hex.EncodeToString(hash[:4])+"\n")
+
+	list, err := blocklist.New(nil, []string{prefixes})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for raw, want := range map[string]string{
+		"http://malware.test/downloads/setup.exe?id=1": "malware.txt",
+		"http://cdn.malware.test/downloads/":           "malware.txt",
+		"http://malware.test/":                         "",
+		"http://malware.test/other/setup.exe":          "",
+	} {
+		if got := blocked(t, list, raw); got != want {
+			t.Errorf("%s expected %q got %q", raw, want, got)
+		}
+	}
+}
+
+func Test_BlocklistReload(t *testing.T) {
+	t.Parallel()
+
+	domains := writeList(t, "domains.txt", "evil.test\n")
+
+	list, err := blocklist.New([]string{domains}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := os.WriteFile(domains, []byte("new-evil.test\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := list.Load(); err != nil {
+		t.Fatal(err)
+	}
+
+	if got := blocked(t, list, "http://new-evil.test/"); got != "domains.txt" {
+		t.Errorf("expected reloaded domain blocked got %q", got)
+	}
+
+	if got := blocked(t, list, "http://evil.test/"); got != "" {
+		t.Errorf("expected removed domain allowed got %q", got)
+	}
+
+	invalid := writeList(t, "invalid.txt", "zz\n")
+
+	if _, err := blocklist.New(nil, []string{invalid}); err == nil {
+		t.Error("expected error for invalid hash prefix")
+	}
+}
+
+func Test_Expressions(t *testing.T) {
+	t.Parallel()
+
+	u, _ := url.Parse("http://a.b.c/1/2.html?param=1")
+
+	want := []string{
+		"a.b.c/1/2.html?param=1", "a.b.c/1/2.html", "a.b.c/", "a.b.c/1/",
+		"b.c/1/2.html?param=1", "b.c/1/2.html", "b.c/", "b.c/1/",
+	}
+
+	if got := blocklist.Expressions(u); !slices.Equal(got, want) {
+		t.Errorf("expected %v got %v", want, got)
+	}
+}
@@ -0,0 +1,75 @@
+package geoip
+
+import (
+	"fmt"
+	"net"
+	"net/netip"
+
+	"github.com/oschwald/maxminddb-golang"
+)
+
+// Location of an address, empty fields are unknown.
+type Location struct {
+	// Country is the ISO 3166-1 alpha-2 code e.g. "DE".
+	Country string
+	// Region is the ISO 3166-2 code of the first subdivision e.g. "US-CA",
+	// only City databases have it.
+	Region string
+}
+
+// DB reads a local MaxMind format database such as GeoLite2-Country,
+// GeoIP2-City or their DB-IP equivalents.
+type DB struct {
+	reader *maxminddb.Reader
+}
+
+type record struct {
+	Country struct {
+		ISOCode string `maxminddb:"iso_code"`
+	} `maxminddb:"country"`
+	// RegisteredCountry is used for addresses without a known country.
+	RegisteredCountry struct {
+		ISOCode string `maxminddb:"iso_code"`
+	} `maxminddb:"registered_country"`
+	Subdivisions []struct {
+		ISOCode string `maxminddb:"iso_code"`
+	} `maxminddb:"subdivisions"`
+}
+
+func Open(path string) (*DB, error) {
+	reader, err := maxminddb.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("error opening geoip database: %w", err)
+	}
+
+	return &DB{reader: reader}, nil
+}
+
+// Locate returns the location of addr, the zero Location when the database
+// has no entry for it.
+func (db *DB) Locate(addr netip.Addr) (Location, error) {
+	var rec record
+
+	if err := db.reader.Lookup(net.IP(addr.Unmap().AsSlice()), &rec); err != nil {
+		return Location{}, fmt.Errorf("error looking up %s: %w", addr, err)
+	}
+
+	location := Location{Country: rec.Country.ISOCode}
+	if location.Country == "" {
+		location.Country = rec.RegisteredCountry.ISOCode
+	}
+
+	if location.Country != "" && len(rec.Subdivisions) > 0 && rec.Subdivisions[0].ISOCode != "" {
+		location.Region = location.Country + "-" + rec.Subdivisions[0].ISOCode
+	}
+
+	return location, nil
+}
+
+func (db *DB) Close() error {
+	if err := db.reader.Close(); err != nil {
+		return fmt.Errorf("error closing geoip database: %w", err)
+	}
+
+	return nil
+}
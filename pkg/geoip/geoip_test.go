@@ -0,0 +1,238 @@
+package geoip_test
+
+import (
+	"bytes"
+	"encoding/binary"
+	"net/netip"
+	"os"
+	"path/filepath"
+	"sort"
+	"testing"
+
+	"github.com/jacekdobrowolski/goshort/pkg/geoip"
+)
+
+// MaxMind DB data section types.
+const (
+	typeString = 2
+	typeUint16 = 5
+	typeUint32 = 6
+	typeMap    = 7
+	typeUint64 = 9
+	typeArray  = 11
+)
+
+// encodeControl writes the control byte, sizes up to 284 are enough for
+// test records.
+func encodeControl(buf *bytes.Buffer, dataType, size int) {
+	var control byte
+	if dataType <= typeMap {
+		control = byte(dataType << 5)
+	}
+
+	if size < 29 {
+		buf.WriteByte(control | byte(size))
+	} else {
+		buf.WriteByte(control | 29)
+	}
+
+	if dataType > typeMap {
+		buf.WriteByte(byte(dataType - typeMap))
+	}
+
+	if size >= 29 {
+		buf.WriteByte(byte(size - 29))
+	}
+}
+
+func encodeUint(buf *bytes.Buffer, dataType int, value uint64) {
+	raw := binary.BigEndian.AppendUint64(nil, value)
+	raw = bytes.TrimLeft(raw, "\x00")
+
+	encodeControl(buf, dataType, len(raw))
+	buf.Write(raw)
+}
+
+func encodeValue(t *testing.T, buf *bytes.Buffer, value any) {
+	t.Helper()
+
+	switch value := value.(type) {
+	case string:
+		encodeControl(buf, typeString, len(value))
+		buf.WriteString(value)
+	case uint16:
+		encodeUint(buf, typeUint16, uint64(value))
+	case uint32:
+		encodeUint(buf, typeUint32, uint64(value))
+	case uint64:
+		encodeUint(buf, typeUint64, value)
+	case []any:
+		encodeControl(buf, typeArray, len(value))
+
+		for _, item := range value {
+			encodeValue(t, buf, item)
+		}
+	case map[string]any:
+		encodeControl(buf, typeMap, len(value))
+
+		keys := make([]string, 0, len(value))
+		for key := range value {
+			keys = append(keys, key)
+		}
+
+		sort.Strings(keys)
+
+		for _, key := range keys {
+			encodeValue(t, buf, key)
+			encodeValue(t, buf, value[key])
+		}
+	default:
+		t.Fatalf("unsupported mmdb value %T", value)
+	}
+}
+
+type trieNode struct {
+	children [2]*trieNode
+	// data holds data section offsets plus one, 0 when empty
+	data [2]int
+}
+
+// writeMMDB writes an IPv4 MaxMind DB with 24 bit records mapping networks
+// to records.
+func writeMMDB(t *testing.T, networks map[string]map[string]any) string {
+	t.Helper()
+
+	var data bytes.Buffer
+
+	root := &trieNode{}
+
+	for network, rec := range networks {
+		prefix := netip.MustParsePrefix(network)
+		addr := prefix.Addr().As4()
+		offset := data.Len()
+
+		encodeValue(t, &data, rec)
+
+		node := root
+
+		for i := range prefix.Bits() {
+			bit := addr[i/8] >> (7 - i%8) & 1
+			if i == prefix.Bits()-1 {
+				node.data[bit] = offset + 1
+
+				break
+			}
+
+			if node.children[bit] == nil {
+				node.children[bit] = &trieNode{}
+			}
+
+			node = node.children[bit]
+		}
+	}
+
+	nodes := []*trieNode{root}
+	index := map[*trieNode]int{root: 0}
+
+	for i := 0; i < len(nodes); i++ {
+		for _, child := range nodes[i].children {
+			if child != nil {
+				index[child] = len(nodes)
+				nodes = append(nodes, child)
+			}
+		}
+	}
+
+	var tree bytes.Buffer
+
+	for _, node := range nodes {
+		for bit := range 2 {
+			record := len(nodes)
+
+			switch {
+			case node.children[bit] != nil:
+				record = index[node.children[bit]]
+			case node.data[bit] != 0:
+				record = len(nodes) + 16 + node.data[bit] - 1
+			}
+
+			tree.Write([]byte{byte(record >> 16), byte(record >> 8), byte(record)})
+		}
+	}
+
+	var db bytes.Buffer
+
+	db.Write(tree.Bytes())
+	db.Write(make([]byte, 16))
+	db.Write(data.Bytes())
+	db.WriteString("\xab\xcd\xefMaxMind.com")
+
+	encodeValue(t, &db, map[string]any{
+		"binary_format_major_version": uint16(2),
+		"binary_format_minor_version": uint16(0),
+		"build_epoch":                 uint64(1760000000),
+		"database_type":               "GoShort-Test-Country",
+		"description":                 map[string]any{"en": "goshort test fixture"},
+		"ip_version":                  uint16(4),
+		"languages":                   []any{"en"},
+		"node_count":                  uint32(len(nodes)),
+		"record_size":                 uint16(24),
+	})
+
+	path := filepath.Join(t.TempDir(), "test.mmdb")
+	if err := os.WriteFile(path, db.Bytes(), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	return path
+}
+
+func Test_Locate(t *testing.T) {
+	t.Parallel()
+
+	path := writeMMDB(t, map[string]map[string]any{
+		"81.2.69.0/24": {
+			"country":      map[string]any{"iso_code": "GB"},
+			"subdivisions": []any{map[string]any{"iso_code": "ENG"}},
+		},
+		"2.125.0.0/16": {
+			"country": map[string]any{"iso_code": "DE"},
+		},
+		"203.0.113.0/24": {
+			"registered_country": map[string]any{"iso_code": "JP"},
+		},
+	})
+
+	db, err := geoip.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer func() { _ = db.Close() }()
+
+	tests := []struct {
+		addr string
+		want geoip.Location
+	}{
+		{addr: "81.2.69.160", want: geoip.Location{Country: "GB", Region: "GB-ENG"}},
+		{addr: "2.125.160.216", want: geoip.Location{Country: "DE"}},
+		{addr: "::ffff:2.125.1.1", want: geoip.Location{Country: "DE"}},
+		{addr: "203.0.113.7", want: geoip.Location{Country: "JP"}},
+		{addr: "10.0.0.1", want: geoip.Location{}},
+	}
+
+	for _, tt := range tests {
+		got, err := db.Locate(netip.MustParseAddr(tt.addr))
+		if err != nil {
+			t.Errorf("%s unexpected error %v", tt.addr, err)
+		}
+
+		if got != tt.want {
+			t.Errorf("%s expected %+v got %+v", tt.addr, tt.want, got)
+		}
+	}
+
+	if _, err := db.Locate(netip.MustParseAddr("2001:db8::1")); err == nil {
+		t.Error("expected error locating IPv6 address in IPv4 database")
+	}
+}
@@ -0,0 +1,358 @@
+package logging
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/jacekdobrowolski/goshort/pkg/proxy"
+)
+
+type AccessLogFormat string
+
+const (
+	FormatCommon   AccessLogFormat = "common"
+	FormatCombined AccessLogFormat = "combined"
+	FormatW3C      AccessLogFormat = "w3c"
+	FormatJSON     AccessLogFormat = "json"
+)
+
+type AccessLogField string
+
+const (
+	FieldTime      AccessLogField = "time"
+	FieldClientIP  AccessLogField = "client_ip"
+	FieldUser      AccessLogField = "user"
+	FieldMethod    AccessLogField = "method"
+	FieldHost      AccessLogField = "host"
+	FieldPath      AccessLogField = "path"
+	FieldQuery     AccessLogField = "query"
+	FieldProtocol  AccessLogField = "protocol"
+	FieldStatus    AccessLogField = "status"
+	FieldBytes     AccessLogField = "bytes"
+	FieldDuration  AccessLogField = "duration_ms"
+	FieldReferer   AccessLogField = "referer"
+	FieldUserAgent AccessLogField = "user_agent"
+	FieldRequestID AccessLogField = "request_id"
+)
+
+var (
+	errUnknownAccessLogFormat = errors.New("unknown access log format")
+	errUnknownAccessLogField  = errors.New("unknown access log field")
+)
+
+//nolint:gochecknoglobals // read only lookup tables
+var (
+	defaultAccessLogFields = []AccessLogField{
+		FieldTime, FieldClientIP, FieldMethod, FieldPath, FieldQuery,
+		FieldStatus, FieldBytes, FieldDuration, FieldReferer, FieldUserAgent,
+	}
+
+	w3cFieldNames = map[AccessLogField]string{
+		FieldTime:      "date time",
+		FieldClientIP:  "c-ip",
+		FieldUser:      "cs-username",
+		FieldMethod:    "cs-method",
+		FieldHost:      "cs-host",
+		FieldPath:      "cs-uri-stem",
+		FieldQuery:     "cs-uri-query",
+		FieldProtocol:  "cs-version",
+		FieldStatus:    "sc-status",
+		FieldBytes:     "sc-bytes",
+		FieldDuration:  "time-taken",
+		FieldReferer:   "cs(Referer)",
+		FieldUserAgent: "cs(User-Agent)",
+		FieldRequestID: "cs(X-Request-Id)",
+	}
+)
+
+func ParseAccessLogFormat(format string) (AccessLogFormat, error) {
+	switch f := AccessLogFormat(strings.ToLower(format)); f {
+	case FormatCommon, FormatCombined, FormatW3C, FormatJSON:
+		return f, nil
+	default:
+		return "", fmt.Errorf("%w: %s", errUnknownAccessLogFormat, format)
+	}
+}
+
+// ParseAccessLogFields parses a comma separated field list.
+func ParseAccessLogFields(list string) ([]AccessLogField, error) {
+	var fields []AccessLogField
+
+	for _, name := range strings.Split(list, ",") {
+		name = strings.TrimSpace(name)
+		if name == "" {
+			continue
+		}
+
+		field := AccessLogField(name)
+		if _, ok := w3cFieldNames[field]; !ok {
+			return nil, fmt.Errorf("%w: %s", errUnknownAccessLogField, name)
+		}
+
+		fields = append(fields, field)
+	}
+
+	return fields, nil
+}
+
+type AccessLogOptions struct {
+	Format AccessLogFormat
+	// Fields selects what is written in W3C and JSON formats,
+	// Common and Combined formats have a fixed layout.
+	Fields []AccessLogField
+	// TrustedProxies whose forwarding headers are used to resolve client IP.
+	TrustedProxies proxy.Trusted
+	// SuccessSampling logs one in every SuccessSampling requests answered
+	// with status below 400. Errors are always logged. Zero logs everything.
+	SuccessSampling uint64
+}
+
+type accessLogEntry struct {
+	start    time.Time
+	duration time.Duration
+	clientIP string
+	user     string
+	request  *http.Request
+	status   int
+	bytes    int64
+}
+
+type accessLogger struct {
+	options AccessLogOptions
+	out     io.Writer
+
+	mu         sync.Mutex
+	headerOnce sync.Once
+	successes  atomic.Uint64
+}
+
+func AccessLogMiddleware(handler http.Handler, out io.Writer, options AccessLogOptions) http.Handler {
+	if options.Format == "" {
+		options.Format = FormatCombined
+	}
+
+	if len(options.Fields) == 0 {
+		options.Fields = defaultAccessLogFields
+	}
+
+	al := &accessLogger{
+		options: options,
+		out:     out,
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+
+		rw, ok := w.(*ResponseWriter)
+		if !ok {
+			rw = NewResponseWriter(w)
+		}
+
+		handler.ServeHTTP(rw, r)
+
+		status := rw.Status()
+		if status == 0 {
+			status = http.StatusOK
+		}
+
+		if !al.sampled(status) {
+			return
+		}
+
+		user, _, _ := r.BasicAuth()
+
+		var clientIP string
+		if ip := options.TrustedProxies.ClientIP(r); ip.IsValid() {
+			clientIP = ip.String()
+		}
+
+		al.write(accessLogEntry{
+			start:    start,
+			duration: time.Since(start),
+			clientIP: clientIP,
+			user:     user,
+			request:  r,
+			status:   status,
+			bytes:    rw.BytesWritten(),
+		})
+	})
+}
+
+func (al *accessLogger) sampled(status int) bool {
+	if status >= http.StatusBadRequest || al.options.SuccessSampling <= 1 {
+		return true
+	}
+
+	return al.successes.Add(1)%al.options.SuccessSampling == 1
+}
+
+func (al *accessLogger) write(entry accessLogEntry) {
+	var line bytes.Buffer
+
+	switch al.options.Format {
+	case FormatCommon:
+		writeCommon(&line, entry)
+	case FormatCombined:
+		writeCommon(&line, entry)
+		fmt.Fprintf(&line, " %s %s",
+			quote(entry.request.Referer()),
+			quote(entry.request.UserAgent()),
+		)
+	case FormatW3C:
+		al.writeW3C(&line, entry)
+	case FormatJSON:
+		al.writeJSON(&line, entry)
+	}
+
+	line.WriteByte('\n')
+
+	al.mu.Lock()
+	defer al.mu.Unlock()
+
+	if al.options.Format == FormatW3C {
+		al.headerOnce.Do(func() {
+			fmt.Fprintf(al.out, "#Version: 1.0\n#Date: %s\n#Fields: %s\n",
+				entry.start.UTC().Format(time.DateTime),
+				al.w3cHeader(),
+			)
+		})
+	}
+
+	_, _ = al.out.Write(line.Bytes())
+}
+
+func writeCommon(line *bytes.Buffer, entry accessLogEntry) {
+	bytesSent := "-"
+	if entry.bytes > 0 {
+		bytesSent = strconv.FormatInt(entry.bytes, 10)
+	}
+
+	fmt.Fprintf(line, `%s - %s [%s] "%s %s %s" %d %s`,
+		dash(entry.clientIP),
+		dash(entry.user),
+		entry.start.Format("02/Jan/2006:15:04:05 -0700"),
+		entry.request.Method,
+		entry.request.URL.RequestURI(),
+		entry.request.Proto,
+		entry.status,
+		bytesSent,
+	)
+}
+
+func (al *accessLogger) w3cHeader() string {
+	names := make([]string, 0, len(al.options.Fields))
+
+	for _, field := range al.options.Fields {
+		names = append(names, w3cFieldNames[field])
+	}
+
+	return strings.Join(names, " ")
+}
+
+func (al *accessLogger) writeW3C(line *bytes.Buffer, entry accessLogEntry) {
+	for i, field := range al.options.Fields {
+		if i > 0 {
+			line.WriteByte(' ')
+		}
+
+		if field == FieldTime {
+			line.WriteString(entry.start.UTC().Format("2006-01-02 15:04:05"))
+
+			continue
+		}
+
+		value := entry.value(field)
+
+		switch v := value.(type) {
+		case string:
+			// W3C extended format separates fields with spaces
+			line.WriteString(dash(strings.ReplaceAll(v, " ", "+")))
+		case float64:
+			// time-taken is in seconds
+			line.WriteString(strconv.FormatFloat(v/1000, 'f', 3, 64))
+		default:
+			fmt.Fprint(line, v)
+		}
+	}
+}
+
+func (al *accessLogger) writeJSON(line *bytes.Buffer, entry accessLogEntry) {
+	record := make(map[string]any, len(al.options.Fields))
+
+	for _, field := range al.options.Fields {
+		record[string(field)] = entry.value(field)
+	}
+
+	encoded, err := json.Marshal(record)
+	if err != nil {
+		fmt.Fprintf(line, `{"error":%q}`, err.Error())
+
+		return
+	}
+
+	line.Write(encoded)
+}
+
+func (entry accessLogEntry) value(field AccessLogField) any {
+	r := entry.request
+
+	switch field {
+	case FieldTime:
+		return entry.start.UTC().Format(time.RFC3339Nano)
+	case FieldClientIP:
+		return entry.clientIP
+	case FieldUser:
+		return entry.user
+	case FieldMethod:
+		return r.Method
+	case FieldHost:
+		return r.Host
+	case FieldPath:
+		return r.URL.Path
+	case FieldQuery:
+		return r.URL.RawQuery
+	case FieldProtocol:
+		return r.Proto
+	case FieldStatus:
+		return entry.status
+	case FieldBytes:
+		return entry.bytes
+	case FieldDuration:
+		return float64(entry.duration.Microseconds()) / 1000
+	case FieldReferer:
+		return r.Referer()
+	case FieldUserAgent:
+		return r.UserAgent()
+	case FieldRequestID:
+		id, _ := RequestIDFromContext(r.Context())
+
+		return id
+	default:
+		return nil
+	}
+}
+
+func dash(s string) string {
+	if s == "" {
+		return "-"
+	}
+
+	return s
+}
+
+func quote(s string) string {
+	if s == "" {
+		return `"-"`
+	}
+
+	return strconv.Quote(s)
+}
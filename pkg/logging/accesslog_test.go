@@ -0,0 +1,129 @@
+package logging_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/jacekdobrowolski/goshort/pkg/logging"
+	"github.com/jacekdobrowolski/goshort/pkg/proxy"
+)
+
+func serveAccessLog(t *testing.T, options logging.AccessLogOptions, status int, requests ...*http.Request) string {
+	t.Helper()
+
+	var out bytes.Buffer
+
+	handler := logging.AccessLogMiddleware(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(status)
+		_, _ = w.Write([]byte("body"))
+	}), &out, options)
+
+	for _, r := range requests {
+		handler.ServeHTTP(httptest.NewRecorder(), r)
+	}
+
+	return out.String()
+}
+
+func Test_AccessLogCombined(t *testing.T) {
+	t.Parallel()
+
+	r := httptest.NewRequest(http.MethodGet, "/abc?x=1", nil)
+	r.RemoteAddr = "192.0.2.7:1234"
+	r.Header.Set("Referer", "http://ref.test/")
+	r.Header.Set("User-Agent", "k6")
+
+	line := serveAccessLog(t, logging.AccessLogOptions{Format: logging.FormatCombined}, http.StatusFound, r)
+
+	expected := regexp.MustCompile(
+		`^192\.0\.2\.7 - - \[[^\]]+\] "GET /abc\?x=1 HTTP/1\.1" 302 4 "http://ref\.test/" "k6"\n$`,
+	)
+	if !expected.MatchString(line) {
+		t.Errorf("unexpected combined log line %q", line)
+	}
+}
+
+func Test_AccessLogW3C(t *testing.T) {
+	t.Parallel()
+
+	r := httptest.NewRequest(http.MethodGet, "/abc", nil)
+	r.Header.Set("User-Agent", "Mozilla 5.0")
+
+	out := serveAccessLog(t, logging.AccessLogOptions{
+		Format: logging.FormatW3C,
+		Fields: []logging.AccessLogField{logging.FieldMethod, logging.FieldQuery, logging.FieldUserAgent},
+	}, http.StatusOK, r, r)
+
+	lines := strings.Split(strings.TrimSpace(out), "\n")
+	if len(lines) != 5 {
+		t.Fatalf("expected 3 header lines and 2 entries got %q", out)
+	}
+
+	if lines[2] != "#Fields: cs-method cs-uri-query cs(User-Agent)" {
+		t.Errorf("unexpected fields directive %q", lines[2])
+	}
+
+	if lines[3] != "GET - Mozilla+5.0" {
+		t.Errorf("unexpected entry %q", lines[3])
+	}
+}
+
+func Test_AccessLogJSONTrustedProxy(t *testing.T) {
+	t.Parallel()
+
+	trusted, err := proxy.ParseTrusted("10.0.0.0/8")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	r := httptest.NewRequest(http.MethodGet, "/abc", nil)
+	r.RemoteAddr = "10.1.1.1:5555"
+	r.Header.Set("X-Forwarded-For", "198.51.100.1, 203.0.113.9, 10.2.2.2")
+
+	out := serveAccessLog(t, logging.AccessLogOptions{
+		Format:         logging.FormatJSON,
+		Fields:         []logging.AccessLogField{logging.FieldClientIP, logging.FieldStatus, logging.FieldBytes},
+		TrustedProxies: trusted,
+	}, http.StatusOK, r)
+
+	var record map[string]any
+	if err := json.Unmarshal([]byte(out), &record); err != nil {
+		t.Fatal(err)
+	}
+
+	if record["client_ip"] != "203.0.113.9" {
+		t.Errorf("expected client_ip 203.0.113.9 got %v", record["client_ip"])
+	}
+
+	if record["status"] != float64(http.StatusOK) || record["bytes"] != float64(4) {
+		t.Errorf("unexpected status or bytes %v", record)
+	}
+
+	if len(record) != 3 {
+		t.Errorf("expected only selected fields got %v", record)
+	}
+}
+
+func Test_AccessLogSampling(t *testing.T) {
+	t.Parallel()
+
+	requests := make([]*http.Request, 10)
+	for i := range requests {
+		requests[i] = httptest.NewRequest(http.MethodGet, "/", nil)
+	}
+
+	options := logging.AccessLogOptions{Format: logging.FormatCommon, SuccessSampling: 5}
+
+	if lines := strings.Count(serveAccessLog(t, options, http.StatusOK, requests...), "\n"); lines != 2 {
+		t.Errorf("expected 2 sampled lines got %d", lines)
+	}
+
+	if lines := strings.Count(serveAccessLog(t, options, http.StatusNotFound, requests...), "\n"); lines != 10 {
+		t.Errorf("expected errors to be always logged got %d lines", lines)
+	}
+}
@@ -0,0 +1,60 @@
+package logging_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log/slog"
+	"testing"
+
+	"github.com/jacekdobrowolski/goshort/pkg/logging"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+)
+
+func Test_ContextHandler(t *testing.T) {
+	t.Parallel()
+
+	var logs bytes.Buffer
+
+	logger := slog.New(logging.NewContextHandler(slog.NewJSONHandler(&logs, nil)))
+
+	ctx, span := sdktrace.NewTracerProvider().Tracer("test").Start(context.Background(), "test")
+	defer span.End()
+
+	ctx = logging.WithRequestID(ctx, "req-1")
+	ctx = logging.WithAttrs(ctx, slog.String("short", "abc"))
+
+	logger.InfoContext(ctx, "first")
+
+	record := map[string]any{}
+	if err := json.Unmarshal(logs.Bytes(), &record); err != nil {
+		t.Fatal(err)
+	}
+
+	expected := map[string]string{
+		"trace_id":   span.SpanContext().TraceID().String(),
+		"span_id":    span.SpanContext().SpanID().String(),
+		"request_id": "req-1",
+		"short":      "abc",
+	}
+
+	for key, value := range expected {
+		if record[key] != value {
+			t.Errorf("expected %s=%s got %v", key, value, record[key])
+		}
+	}
+
+	logs.Reset()
+	logger.InfoContext(context.Background(), "second")
+
+	record = map[string]any{}
+	if err := json.Unmarshal(logs.Bytes(), &record); err != nil {
+		t.Fatal(err)
+	}
+
+	for key := range expected {
+		if _, ok := record[key]; ok {
+			t.Errorf("attribute %s leaked into unrelated record", key)
+		}
+	}
+}
@@ -7,6 +7,7 @@ import (
 
 	"go.opentelemetry.io/otel"
 	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
 	"go.opentelemetry.io/otel/trace"
 )
 
@@ -31,13 +32,12 @@ func Middleware(handler http.Handler, logger *slog.Logger) http.Handler {
 		ctx, parentSpan := tracer.Start(req.Context(), "http", trace.WithNewRoot())
 		defer parentSpan.End()
 
-		logger = logger.With(
+		ctx = WithAttrs(ctx,
 			slog.String("http.method", req.Method),
 			slog.String("http.url", req.URL.Path),
-			slog.String("trace_id", parentSpan.SpanContext().TraceID().String()),
 		)
 
-		logger.Debug("request received")
+		logger.DebugContext(ctx, "request received")
 
 		parentSpan.SetAttributes(
 			attribute.KeyValue{
@@ -50,13 +50,38 @@ func Middleware(handler http.Handler, logger *slog.Logger) http.Handler {
 			},
 		)
 
+		if id, ok := RequestIDFromContext(ctx); ok {
+			parentSpan.SetAttributes(attribute.String("http.request.id", id))
+		}
+
 		counter.Add(ctx, 1)
 
 		req = req.WithContext(ctx)
 
-		handler.ServeHTTP(writer, req)
+		rw, ok := writer.(*ResponseWriter)
+		if !ok {
+			rw = NewResponseWriter(writer)
+		}
+
+		handler.ServeHTTP(rw, req)
+
+		status := rw.Status()
+		if status == 0 {
+			// handler returned without writing, net/http sends 200
+			status = http.StatusOK
+		}
+
+		parentSpan.SetAttributes(attribute.Int("http.response.status_code", status))
+
+		if status >= http.StatusInternalServerError {
+			parentSpan.SetStatus(codes.Error, http.StatusText(status))
+		}
 
 		historgram.Record(ctx, time.Since(start).Milliseconds())
-		logger.Debug("response written", slog.Duration("time", time.Since(start)))
+		logger.DebugContext(ctx, "response written",
+			slog.Int("http.status_code", status),
+			slog.Int64("http.response_bytes", rw.BytesWritten()),
+			slog.Duration("time", time.Since(start)),
+		)
 	})
 }
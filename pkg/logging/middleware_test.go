@@ -0,0 +1,134 @@
+package logging_test
+
+import (
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/jacekdobrowolski/goshort/pkg/logging"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+func Test_ResponseWriter(t *testing.T) {
+	t.Parallel()
+
+	t.Run("implicit 200 and byte count", func(t *testing.T) {
+		t.Parallel()
+
+		w := httptest.NewRecorder()
+		rw := logging.NewResponseWriter(w)
+
+		if _, err := rw.Write([]byte("hello")); err != nil {
+			t.Fatal(err)
+		}
+
+		if _, err := io.Copy(rw, strings.NewReader(" world")); err != nil {
+			t.Fatal(err)
+		}
+
+		if rw.Status() != http.StatusOK {
+			t.Errorf("expected status %d got %d", http.StatusOK, rw.Status())
+		}
+
+		if rw.BytesWritten() != int64(len("hello world")) {
+			t.Errorf("expected %d bytes got %d", len("hello world"), rw.BytesWritten())
+		}
+
+		if w.Body.String() != "hello world" {
+			t.Errorf("unexpected body %q", w.Body.String())
+		}
+	})
+
+	t.Run("first status wins", func(t *testing.T) {
+		t.Parallel()
+
+		rw := logging.NewResponseWriter(httptest.NewRecorder())
+		rw.WriteHeader(http.StatusNotFound)
+		rw.WriteHeader(http.StatusInternalServerError)
+
+		if rw.Status() != http.StatusNotFound {
+			t.Errorf("expected status %d got %d", http.StatusNotFound, rw.Status())
+		}
+	})
+
+	t.Run("flush is forwarded", func(t *testing.T) {
+		t.Parallel()
+
+		w := httptest.NewRecorder()
+		rw := logging.NewResponseWriter(w)
+
+		var writer http.ResponseWriter = rw
+
+		flusher, ok := writer.(http.Flusher)
+		if !ok {
+			t.Fatal("wrapped writer does not implement http.Flusher")
+		}
+
+		flusher.Flush()
+
+		if !w.Flushed {
+			t.Error("underlying writer was not flushed")
+		}
+	})
+
+	t.Run("hijack unsupported", func(t *testing.T) {
+		t.Parallel()
+
+		rw := logging.NewResponseWriter(httptest.NewRecorder())
+
+		if _, _, err := rw.Hijack(); err == nil {
+			t.Error("expected error hijacking recorder")
+		}
+	})
+}
+
+//nolint:paralleltest // replaces global tracer provider
+func Test_MiddlewareSpanStatus(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+
+	previous := otel.GetTracerProvider()
+	otel.SetTracerProvider(provider)
+
+	t.Cleanup(func() { otel.SetTracerProvider(previous) })
+
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+
+	handler := logging.Middleware(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusBadGateway)
+	}), logger)
+
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/x", nil))
+
+	spans := recorder.Ended()
+	if len(spans) != 1 {
+		t.Fatalf("expected 1 span got %d", len(spans))
+	}
+
+	if spans[0].Status().Code != codes.Error {
+		t.Errorf("expected span status error got %v", spans[0].Status().Code)
+	}
+
+	found := false
+
+	for _, attr := range spans[0].Attributes() {
+		if attr.Key == attribute.Key("http.response.status_code") {
+			found = true
+
+			if attr.Value.AsInt64() != http.StatusBadGateway {
+				t.Errorf("expected status code attribute %d got %d", http.StatusBadGateway, attr.Value.AsInt64())
+			}
+		}
+	}
+
+	if !found {
+		t.Error("missing http.response.status_code attribute")
+	}
+}
@@ -0,0 +1,77 @@
+package logging
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"runtime/debug"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+var errPanic = errors.New("panic")
+
+// RecoveryMiddleware turns handler panics into 500 responses. It has to be
+// wrapped by Middleware so the panic is recorded on the request span.
+func RecoveryMiddleware(handler http.Handler, logger *slog.Logger) http.Handler {
+	meter := otel.Meter("middleware")
+
+	counter, err := meter.Int64Counter("panics_total")
+	if err != nil {
+		logger.Error("error creating meter", slog.String("err", err.Error()))
+	}
+
+	return http.HandlerFunc(func(writer http.ResponseWriter, req *http.Request) {
+		rw, ok := writer.(*ResponseWriter)
+		if !ok {
+			rw = NewResponseWriter(writer)
+		}
+
+		defer func() {
+			recovered := recover()
+			if recovered == nil {
+				return
+			}
+
+			// net/http uses this panic to abort the response on purpose
+			if recovered == http.ErrAbortHandler {
+				panic(recovered)
+			}
+
+			stack := string(debug.Stack())
+
+			panicErr, ok := recovered.(error)
+			if ok {
+				panicErr = fmt.Errorf("%w: %w", errPanic, panicErr)
+			} else {
+				panicErr = fmt.Errorf("%w: %v", errPanic, recovered)
+			}
+
+			ctx := req.Context()
+			span := trace.SpanFromContext(ctx)
+
+			span.RecordError(panicErr, trace.WithAttributes(
+				attribute.String("exception.stacktrace", stack),
+				attribute.Bool("exception.escaped", true),
+			))
+			span.SetStatus(codes.Error, "panic")
+
+			counter.Add(ctx, 1)
+
+			logger.ErrorContext(ctx, "panic serving request",
+				slog.String("err", panicErr.Error()),
+				slog.String("stack", stack),
+			)
+
+			if rw.Status() == 0 {
+				http.Error(rw, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+			}
+		}()
+
+		handler.ServeHTTP(rw, req)
+	})
+}
@@ -0,0 +1,62 @@
+package logging_test
+
+import (
+	"bytes"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/jacekdobrowolski/goshort/pkg/logging"
+)
+
+func Test_RequestIDMiddleware(t *testing.T) {
+	t.Parallel()
+
+	var logs bytes.Buffer
+
+	logger := slog.New(logging.NewContextHandler(slog.NewTextHandler(&logs, nil)))
+
+	handler := logging.RequestIDMiddleware(http.HandlerFunc(func(_ http.ResponseWriter, r *http.Request) {
+		logger.InfoContext(r.Context(), "handled")
+	}))
+
+	tests := []struct {
+		name     string
+		incoming string
+		reused   bool
+	}{
+		{name: "generated when missing", incoming: "", reused: false},
+		{name: "reused when valid", incoming: "abc-123", reused: true},
+		{name: "replaced when invalid", incoming: "bad id\n", reused: false},
+		{name: "replaced when too long", incoming: strings.Repeat("a", 129), reused: false},
+	}
+
+	for _, tt := range tests {
+		logs.Reset()
+
+		r := httptest.NewRequest(http.MethodGet, "/", nil)
+		if tt.incoming != "" {
+			r.Header.Set(logging.RequestIDHeader, tt.incoming)
+		}
+
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, r)
+
+		id := w.Header().Get(logging.RequestIDHeader)
+		if id == "" {
+			t.Errorf("%s: missing %s response header", tt.name, logging.RequestIDHeader)
+
+			continue
+		}
+
+		if (id == tt.incoming) != tt.reused {
+			t.Errorf("%s: unexpected request id %q", tt.name, id)
+		}
+
+		if !strings.Contains(logs.String(), "request_id="+id) {
+			t.Errorf("%s: request id missing from log %q", tt.name, logs.String())
+		}
+	}
+}
@@ -0,0 +1,119 @@
+package logging
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+)
+
+// ResponseWriter wraps http.ResponseWriter recording the status code and
+// number of body bytes written. Flush, Hijack and ReadFrom are forwarded to
+// the underlying writer so wrapping does not disable streaming, websockets
+// or sendfile.
+type ResponseWriter struct {
+	http.ResponseWriter
+
+	status      int
+	bytes       int64
+	wroteHeader bool
+}
+
+func NewResponseWriter(w http.ResponseWriter) *ResponseWriter {
+	return &ResponseWriter{ResponseWriter: w}
+}
+
+// Status returns the status code sent to the client, http.StatusOK if the
+// handler wrote a body without calling WriteHeader and 0 if nothing was written.
+func (rw *ResponseWriter) Status() int {
+	return rw.status
+}
+
+func (rw *ResponseWriter) BytesWritten() int64 {
+	return rw.bytes
+}
+
+func (rw *ResponseWriter) WriteHeader(status int) {
+	if !rw.wroteHeader {
+		rw.status = status
+		// informational responses may be followed by the final status
+		rw.wroteHeader = status >= http.StatusOK || status == http.StatusSwitchingProtocols
+	}
+
+	rw.ResponseWriter.WriteHeader(status)
+}
+
+func (rw *ResponseWriter) Write(b []byte) (int, error) {
+	if !rw.wroteHeader {
+		rw.WriteHeader(http.StatusOK)
+	}
+
+	n, err := rw.ResponseWriter.Write(b)
+	rw.bytes += int64(n)
+
+	if err != nil {
+		return n, fmt.Errorf("error writing response: %w", err)
+	}
+
+	return n, nil
+}
+
+func (rw *ResponseWriter) Flush() {
+	if !rw.wroteHeader {
+		rw.WriteHeader(http.StatusOK)
+	}
+
+	if flusher, ok := rw.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+func (rw *ResponseWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	hijacker, ok := rw.ResponseWriter.(http.Hijacker)
+	if !ok {
+		return nil, nil, fmt.Errorf("error hijacking connection: %w", http.ErrNotSupported)
+	}
+
+	if !rw.wroteHeader {
+		rw.status = http.StatusSwitchingProtocols
+		rw.wroteHeader = true
+	}
+
+	conn, buf, err := hijacker.Hijack()
+	if err != nil {
+		return nil, nil, fmt.Errorf("error hijacking connection: %w", err)
+	}
+
+	return conn, buf, nil
+}
+
+func (rw *ResponseWriter) ReadFrom(src io.Reader) (int64, error) {
+	if !rw.wroteHeader {
+		rw.WriteHeader(http.StatusOK)
+	}
+
+	var (
+		n   int64
+		err error
+	)
+
+	if readerFrom, ok := rw.ResponseWriter.(io.ReaderFrom); ok {
+		n, err = readerFrom.ReadFrom(src)
+	} else {
+		n, err = io.Copy(rw.ResponseWriter, src)
+	}
+
+	rw.bytes += n
+
+	if err != nil {
+		return n, fmt.Errorf("error copying response: %w", err)
+	}
+
+	return n, nil
+}
+
+// Unwrap lets http.ResponseController reach the underlying writer.
+func (rw *ResponseWriter) Unwrap() http.ResponseWriter {
+	return rw.ResponseWriter
+}
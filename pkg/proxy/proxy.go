@@ -0,0 +1,281 @@
+package proxy
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/netip"
+	"net/url"
+	"strings"
+)
+
+var errUnknownHeader = errors.New("unknown forwarding header, expected x-forwarded or forwarded")
+
+// Header is the family of forwarding headers trusted proxies set.
+type Header string
+
+const (
+	// HeaderXForwarded is X-Forwarded-For, X-Forwarded-Proto, X-Forwarded-Host
+	// and X-Real-IP.
+	HeaderXForwarded Header = "x-forwarded"
+	// HeaderForwarded is the RFC 7239 Forwarded header.
+	HeaderForwarded Header = "forwarded"
+)
+
+// ParseHeader parses a header family name, x-forwarded when empty.
+func ParseHeader(name string) (Header, error) {
+	switch header := Header(strings.ToLower(strings.TrimSpace(name))); header {
+	case "":
+		return HeaderXForwarded, nil
+	case HeaderXForwarded, HeaderForwarded:
+		return header, nil
+	default:
+		return "", fmt.Errorf("%w: %q", errUnknownHeader, name)
+	}
+}
+
+// Trusted is a list of networks whose forwarding headers are believed.
+// Headers added by anyone else are ignored since they can be spoofed, so is
+// the family the proxies do not set as they pass it through from clients.
+type Trusted struct {
+	Networks []netip.Prefix
+	// Header the proxies set, x-forwarded when empty.
+	Header Header
+}
+
+// ParseTrusted parses comma separated CIDRs or single addresses of proxies
+// setting X-Forwarded-* headers.
+func ParseTrusted(list string) (Trusted, error) {
+	trusted := Trusted{Header: HeaderXForwarded}
+
+	for _, entry := range strings.Split(list, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+
+		if !strings.Contains(entry, "/") {
+			addr, err := netip.ParseAddr(entry)
+			if err != nil {
+				return Trusted{}, fmt.Errorf("error parsing trusted proxy %q: %w", entry, err)
+			}
+
+			trusted.Networks = append(trusted.Networks, netip.PrefixFrom(addr.Unmap(), addr.Unmap().BitLen()))
+
+			continue
+		}
+
+		prefix, err := netip.ParsePrefix(entry)
+		if err != nil {
+			return Trusted{}, fmt.Errorf("error parsing trusted proxy %q: %w", entry, err)
+		}
+
+		trusted.Networks = append(trusted.Networks, prefix.Masked())
+	}
+
+	return trusted, nil
+}
+
+func (t Trusted) Contains(addr netip.Addr) bool {
+	addr = addr.Unmap()
+
+	for _, prefix := range t.Networks {
+		if prefix.Contains(addr) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// ClientIP returns the address of the client that sent the request. When the
+// direct peer is a trusted proxy the forwarding chain from Forwarded, or
+// X-Forwarded-For and X-Real-IP, depending on Header is walked right to left
+// and the first untrusted hop is returned.
+func (t Trusted) ClientIP(r *http.Request) netip.Addr {
+	remote := RemoteAddr(r)
+	if !remote.IsValid() || !t.Contains(remote) {
+		return remote
+	}
+
+	chain := t.forwardedFor(r.Header)
+	if len(chain) == 0 {
+		return remote
+	}
+
+	for i := len(chain) - 1; i >= 0; i-- {
+		if !t.Contains(chain[i]) {
+			return chain[i]
+		}
+	}
+
+	return chain[0]
+}
+
+// Origin returns the scheme and host the client sent the request to. When
+// the direct peer is a trusted proxy they are read from the Forwarded element
+// of the client hop, or the last X-Forwarded-Proto and X-Forwarded-Host
+// values, depending on Header, otherwise from the connection and Host header.
+func (t Trusted) Origin(r *http.Request) (string, string) {
+	scheme, host := "http", r.Host
+	if r.TLS != nil {
+		scheme = "https"
+	}
+
+	remote := RemoteAddr(r)
+	if !remote.IsValid() || !t.Contains(remote) {
+		return scheme, host
+	}
+
+	var forwardedProto, forwardedHost string
+
+	if t.Header == HeaderForwarded {
+		element := t.clientHop(ParseForwarded(r.Header.Values("Forwarded")))
+		forwardedProto, forwardedHost = element["proto"], element["host"]
+	} else {
+		forwardedProto = lastValue(r.Header.Values("X-Forwarded-Proto"))
+		forwardedHost = lastValue(r.Header.Values("X-Forwarded-Host"))
+	}
+
+	if forwardedProto = strings.ToLower(forwardedProto); forwardedProto == "http" || forwardedProto == "https" {
+		scheme = forwardedProto
+	}
+
+	if validHost(forwardedHost) {
+		host = forwardedHost
+	}
+
+	return scheme, host
+}
+
+// clientHop returns the Forwarded element added by the proxy the client
+// connected to, walking hops right to left like ClientIP.
+func (t Trusted) clientHop(elements []map[string]string) map[string]string {
+	for i := len(elements) - 1; i >= 0; i-- {
+		if addr, ok := parseNode(elements[i]["for"]); !ok || !t.Contains(addr) {
+			return elements[i]
+		}
+	}
+
+	if len(elements) == 0 {
+		return nil
+	}
+
+	return elements[0]
+}
+
+func lastValue(values []string) string {
+	if len(values) == 0 {
+		return ""
+	}
+
+	list := strings.Split(values[len(values)-1], ",")
+
+	return strings.TrimSpace(list[len(list)-1])
+}
+
+// validHost accepts a host with an optional port and nothing else.
+func validHost(host string) bool {
+	if host == "" {
+		return false
+	}
+
+	parsed, err := url.Parse("http://" + host)
+
+	return err == nil && parsed.Host == host && parsed.User == nil && parsed.Path == "" && parsed.RawQuery == ""
+}
+
+// RemoteAddr returns the address of the direct peer.
+func RemoteAddr(r *http.Request) netip.Addr {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+
+	addr, err := netip.ParseAddr(host)
+	if err != nil {
+		return netip.Addr{}
+	}
+
+	return addr.Unmap()
+}
+
+func (t Trusted) forwardedFor(header http.Header) []netip.Addr {
+	var chain []netip.Addr
+
+	if t.Header == HeaderForwarded {
+		for _, element := range ParseForwarded(header.Values("Forwarded")) {
+			if addr, ok := parseNode(element["for"]); ok {
+				chain = append(chain, addr)
+			}
+		}
+
+		return chain
+	}
+
+	if values := header.Values("X-Forwarded-For"); len(values) > 0 {
+		for _, value := range values {
+			for _, node := range strings.Split(value, ",") {
+				if addr, ok := parseNode(node); ok {
+					chain = append(chain, addr)
+				}
+			}
+		}
+
+		return chain
+	}
+
+	if addr, ok := parseNode(header.Get("X-Real-Ip")); ok {
+		chain = append(chain, addr)
+	}
+
+	return chain
+}
+
+// ParseForwarded splits RFC 7239 Forwarded header values into elements,
+// one per hop, with lower cased parameter names and unquoted values.
+func ParseForwarded(values []string) []map[string]string {
+	var elements []map[string]string
+
+	for _, value := range values {
+		for _, element := range strings.Split(value, ",") {
+			params := make(map[string]string)
+
+			for _, pair := range strings.Split(element, ";") {
+				key, val, ok := strings.Cut(strings.TrimSpace(pair), "=")
+				if !ok {
+					continue
+				}
+
+				params[strings.ToLower(strings.TrimSpace(key))] = strings.Trim(strings.TrimSpace(val), `"`)
+			}
+
+			if len(params) > 0 {
+				elements = append(elements, params)
+			}
+		}
+	}
+
+	return elements
+}
+
+func parseNode(node string) (netip.Addr, bool) {
+	node = strings.TrimSpace(node)
+	if node == "" {
+		return netip.Addr{}, false
+	}
+
+	if addrPort, err := netip.ParseAddrPort(node); err == nil {
+		return addrPort.Addr().Unmap(), true
+	}
+
+	node = strings.TrimSuffix(strings.TrimPrefix(node, "["), "]")
+
+	addr, err := netip.ParseAddr(node)
+	if err != nil {
+		return netip.Addr{}, false
+	}
+
+	return addr.Unmap(), true
+}
@@ -0,0 +1,265 @@
+package proxy_test
+
+import (
+	"crypto/tls"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"testing"
+
+	"github.com/jacekdobrowolski/goshort/pkg/proxy"
+)
+
+func Test_ParseTrusted(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		list      string
+		err       bool
+		trusted   []string
+		untrusted []string
+	}{
+		{list: "", untrusted: []string{"10.0.0.1"}},
+		{
+			list:      "10.0.0.0/8, 192.168.1.7",
+			trusted:   []string{"10.1.2.3", "192.168.1.7", "::ffff:10.0.0.1"},
+			untrusted: []string{"192.168.1.8", "11.0.0.1"},
+		},
+		{list: "10.1.2.3/8", trusted: []string{"10.200.0.1"}},
+		{list: "fd00::/8,::1", trusted: []string{"fd12::1", "::1"}, untrusted: []string{"fe80::1"}},
+		{list: "10.0.0.0/33", err: true},
+		{list: "proxy.internal", err: true},
+	}
+
+	for _, tt := range tests {
+		trusted, err := proxy.ParseTrusted(tt.list)
+		if (err != nil) != tt.err {
+			t.Errorf("%q expected error %t got %v", tt.list, tt.err, err)
+
+			continue
+		}
+
+		for _, addr := range tt.trusted {
+			if !trusted.Contains(netip.MustParseAddr(addr)) {
+				t.Errorf("%q expected %s to be trusted", tt.list, addr)
+			}
+		}
+
+		for _, addr := range tt.untrusted {
+			if trusted.Contains(netip.MustParseAddr(addr)) {
+				t.Errorf("%q expected %s not to be trusted", tt.list, addr)
+			}
+		}
+	}
+}
+
+func Test_ParseHeader(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name string
+		want proxy.Header
+		err  bool
+	}{
+		{name: "", want: proxy.HeaderXForwarded},
+		{name: "X-Forwarded", want: proxy.HeaderXForwarded},
+		{name: "forwarded", want: proxy.HeaderForwarded},
+		{name: "x-real-ip", err: true},
+	}
+
+	for _, tt := range tests {
+		header, err := proxy.ParseHeader(tt.name)
+		if (err != nil) != tt.err || header != tt.want {
+			t.Errorf("%q expected %q error %t got %q %v", tt.name, tt.want, tt.err, header, err)
+		}
+	}
+}
+
+func Test_ClientIP(t *testing.T) {
+	t.Parallel()
+
+	trusted, err := proxy.ParseTrusted("10.0.0.0/8,fd00::/8")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name       string
+		remoteAddr string
+		family     proxy.Header
+		header     http.Header
+		want       string
+	}{
+		{
+			name:       "untrusted peer",
+			remoteAddr: "192.0.2.1:1234",
+			header:     http.Header{"X-Forwarded-For": {"198.51.100.1"}},
+			want:       "192.0.2.1",
+		},
+		{name: "trusted peer without headers", remoteAddr: "10.0.0.1:1234", want: "10.0.0.1"},
+		{
+			name:       "trusted chain",
+			remoteAddr: "10.0.0.1:1234",
+			header:     http.Header{"X-Forwarded-For": {"198.51.100.1, 10.0.0.3", "10.0.0.2"}},
+			want:       "198.51.100.1",
+		},
+		{
+			name:       "spoofed left-most entries",
+			remoteAddr: "10.0.0.1:1234",
+			header:     http.Header{"X-Forwarded-For": {"127.0.0.1, 203.0.113.9, 198.51.100.1"}},
+			want:       "198.51.100.1",
+		},
+		{
+			name:       "only trusted hops",
+			remoteAddr: "10.0.0.1:1234",
+			header:     http.Header{"X-Forwarded-For": {"10.0.0.3, 10.0.0.2"}},
+			want:       "10.0.0.3",
+		},
+		{
+			name:       "x-real-ip",
+			remoteAddr: "10.0.0.1:1234",
+			header:     http.Header{"X-Real-Ip": {"198.51.100.1"}},
+			want:       "198.51.100.1",
+		},
+		{
+			name:       "client forwarded passed through x-forwarded proxy",
+			remoteAddr: "10.0.0.1:1234",
+			header: http.Header{
+				"Forwarded":       {`for=1.2.3.4`},
+				"X-Forwarded-For": {"198.51.100.1"},
+			},
+			want: "198.51.100.1",
+		},
+		{
+			name:       "forwarded",
+			remoteAddr: "10.0.0.1:1234",
+			family:     proxy.HeaderForwarded,
+			header: http.Header{
+				"Forwarded":       {`for=198.51.100.1;proto=https, for=10.0.0.2`},
+				"X-Forwarded-For": {"203.0.113.9"},
+			},
+			want: "198.51.100.1",
+		},
+		{
+			name:       "client x-forwarded-for passed through forwarded proxy",
+			remoteAddr: "10.0.0.1:1234",
+			family:     proxy.HeaderForwarded,
+			header:     http.Header{"X-Forwarded-For": {"1.2.3.4"}},
+			want:       "10.0.0.1",
+		},
+		{
+			name:       "bracketed ipv6 forwarded",
+			remoteAddr: "[fd00::1]:1234",
+			family:     proxy.HeaderForwarded,
+			header:     http.Header{"Forwarded": {`for="[2001:db8::1]:4711", for="[fd00::2]"`}},
+			want:       "2001:db8::1",
+		},
+		{
+			name:       "obfuscated forwarded node",
+			remoteAddr: "10.0.0.1:1234",
+			family:     proxy.HeaderForwarded,
+			header:     http.Header{"Forwarded": {`for=_hidden, for=198.51.100.1`}},
+			want:       "198.51.100.1",
+		},
+		{
+			name:       "ipv4 mapped peer",
+			remoteAddr: "[::ffff:10.0.0.1]:1234",
+			header:     http.Header{"X-Forwarded-For": {"198.51.100.1"}},
+			want:       "198.51.100.1",
+		},
+	}
+
+	for _, tt := range tests {
+		r := httptest.NewRequest(http.MethodGet, "/", nil)
+		r.RemoteAddr = tt.remoteAddr
+
+		for key, values := range tt.header {
+			r.Header[key] = values
+		}
+
+		trusted.Header = tt.family
+
+		if got := trusted.ClientIP(r); got != netip.MustParseAddr(tt.want) {
+			t.Errorf("%s expected %s got %s", tt.name, tt.want, got)
+		}
+	}
+}
+
+func Test_Origin(t *testing.T) {
+	t.Parallel()
+
+	trusted, err := proxy.ParseTrusted("10.0.0.0/8")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name       string
+		remoteAddr string
+		tls        bool
+		family     proxy.Header
+		header     http.Header
+		scheme     string
+		host       string
+	}{
+		{name: "direct", remoteAddr: "192.0.2.1:1234", scheme: "http", host: "goshort.test"},
+		{name: "direct tls", remoteAddr: "192.0.2.1:1234", tls: true, scheme: "https", host: "goshort.test"},
+		{
+			name:       "untrusted headers",
+			remoteAddr: "192.0.2.1:1234",
+			header:     http.Header{"X-Forwarded-Proto": {"https"}, "X-Forwarded-Host": {"evil.test"}},
+			scheme:     "http",
+			host:       "goshort.test",
+		},
+		{
+			name:       "last x-forwarded values",
+			remoteAddr: "10.0.0.1:1234",
+			header:     http.Header{"X-Forwarded-Proto": {"http, HTTPS"}, "X-Forwarded-Host": {"evil.test, sho.rt:8443"}},
+			scheme:     "https",
+			host:       "sho.rt:8443",
+		},
+		{
+			name:       "client forwarded passed through x-forwarded proxy",
+			remoteAddr: "10.0.0.1:1234",
+			header:     http.Header{"Forwarded": {`for=1.2.3.4;proto=https;host=evil.test`}},
+			scheme:     "http",
+			host:       "goshort.test",
+		},
+		{
+			name:       "forwarded client hop",
+			remoteAddr: "10.0.0.1:1234",
+			family:     proxy.HeaderForwarded,
+			header: http.Header{"Forwarded": {
+				`for=198.51.100.1;proto=https;host=sho.rt, for=10.0.0.2;proto=http;host=internal`,
+			}},
+			scheme: "https",
+			host:   "sho.rt",
+		},
+		{
+			name:       "invalid forwarded values",
+			remoteAddr: "10.0.0.1:1234",
+			header:     http.Header{"X-Forwarded-Proto": {"javascript"}, "X-Forwarded-Host": {"user@sho.rt/path"}},
+			scheme:     "http",
+			host:       "goshort.test",
+		},
+	}
+
+	for _, tt := range tests {
+		r := httptest.NewRequest(http.MethodGet, "http://goshort.test/", nil)
+		r.RemoteAddr = tt.remoteAddr
+
+		if tt.tls {
+			r.TLS = &tls.ConnectionState{}
+		}
+
+		for key, values := range tt.header {
+			r.Header[key] = values
+		}
+
+		trusted.Header = tt.family
+
+		if scheme, host := trusted.Origin(r); scheme != tt.scheme || host != tt.host {
+			t.Errorf("%s expected %s://%s got %s://%s", tt.name, tt.scheme, tt.host, scheme, host)
+		}
+	}
+}
@@ -0,0 +1,177 @@
+package qr
+
+import (
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"image/png"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/skip2/go-qrcode"
+)
+
+// Level is the error correction level, higher levels survive more damage
+// at the cost of denser codes.
+type Level string
+
+const (
+	LevelL Level = "L"
+	LevelM Level = "M"
+	LevelQ Level = "Q"
+	LevelH Level = "H"
+)
+
+var (
+	errUnknownLevel = errors.New("unknown error correction level, expected L, M, Q or H")
+	errInvalidColor = errors.New("invalid colour, expected RRGGBB or RRGGBBAA hex")
+	errSizeTooSmall = errors.New("size too small for the code")
+)
+
+func ParseLevel(level string) (Level, error) {
+	switch parsed := Level(strings.ToUpper(level)); parsed {
+	case LevelL, LevelM, LevelQ, LevelH:
+		return parsed, nil
+	default:
+		return "", fmt.Errorf("%w: %q", errUnknownLevel, level)
+	}
+}
+
+func (l Level) recoveryLevel() qrcode.RecoveryLevel {
+	switch l {
+	case LevelL:
+		return qrcode.Low
+	case LevelQ:
+		return qrcode.High
+	case LevelH:
+		return qrcode.Highest
+	case LevelM:
+		return qrcode.Medium
+	default:
+		return qrcode.Medium
+	}
+}
+
+// ParseColor parses RRGGBB or RRGGBBAA hex with an optional leading '#'.
+func ParseColor(value string) (color.NRGBA, error) {
+	raw, err := hex.DecodeString(strings.TrimPrefix(value, "#"))
+	if err != nil || len(raw) != 3 && len(raw) != 4 {
+		return color.NRGBA{}, fmt.Errorf("%w: %q", errInvalidColor, value)
+	}
+
+	c := color.NRGBA{R: raw[0], G: raw[1], B: raw[2], A: 0xff}
+	if len(raw) == 4 {
+		c.A = raw[3]
+	}
+
+	return c, nil
+}
+
+// Options controls rendering, Size is the image width and height in pixels
+// and Margin the quiet zone in modules.
+type Options struct {
+	Size       int
+	Margin     int
+	Foreground color.NRGBA
+	Background color.NRGBA
+}
+
+// Code is an encoded QR code.
+type Code struct {
+	modules [][]bool
+}
+
+func New(content string, level Level) (*Code, error) {
+	encoded, err := qrcode.New(content, level.recoveryLevel())
+	if err != nil {
+		return nil, fmt.Errorf("error encoding qr code: %w", err)
+	}
+
+	encoded.DisableBorder = true
+
+	return &Code{modules: encoded.Bitmap()}, nil
+}
+
+// layout returns the module size in pixels and the offset centering the
+// code with its margin in the image.
+func (c *Code) layout(options Options) (int, int, error) {
+	total := len(c.modules) + 2*options.Margin
+
+	scale := options.Size / total
+	if scale < 1 {
+		return 0, 0, fmt.Errorf("%w: %d modules need at least %dpx", errSizeTooSmall, total, total)
+	}
+
+	return scale, (options.Size-scale*total)/2 + scale*options.Margin, nil
+}
+
+// PNG writes a two colour paletted PNG.
+func (c *Code) PNG(w io.Writer, options Options) error {
+	scale, offset, err := c.layout(options)
+	if err != nil {
+		return err
+	}
+
+	img := image.NewPaletted(image.Rect(0, 0, options.Size, options.Size),
+		color.Palette{options.Background, options.Foreground})
+
+	for y, row := range c.modules {
+		for x, dark := range row {
+			if !dark {
+				continue
+			}
+
+			for py := range scale {
+				for px := range scale {
+					img.SetColorIndex(offset+x*scale+px, offset+y*scale+py, 1)
+				}
+			}
+		}
+	}
+
+	if err := png.Encode(w, img); err != nil {
+		return fmt.Errorf("error encoding png: %w", err)
+	}
+
+	return nil
+}
+
+// SVG writes a scalable image, Size sets its width and height.
+func (c *Code) SVG(w io.Writer, options Options) error {
+	if _, _, err := c.layout(options); err != nil {
+		return err
+	}
+
+	total := len(c.modules) + 2*options.Margin
+
+	var path strings.Builder
+
+	for y, row := range c.modules {
+		for x, dark := range row {
+			if dark {
+				fmt.Fprintf(&path, "M%d %dh1v1h-1z", x+options.Margin, y+options.Margin)
+			}
+		}
+	}
+
+	_, err := fmt.Fprintf(w, `<svg xmlns="http://www.w3.org/2000/svg" width="%d" height="%d" viewBox="0 0 %d %d" `+
+		`shape-rendering="crispEdges"><rect width="100%%" height="100%%" %s/><path d="%s" %s/></svg>`,
+		options.Size, options.Size, total, total, svgFill(options.Background), path.String(), svgFill(options.Foreground))
+	if err != nil {
+		return fmt.Errorf("error writing svg: %w", err)
+	}
+
+	return nil
+}
+
+func svgFill(c color.NRGBA) string {
+	fill := fmt.Sprintf(`fill="#%02x%02x%02x"`, c.R, c.G, c.B)
+	if c.A != 0xff {
+		fill += ` fill-opacity="` + strconv.FormatFloat(float64(c.A)/0xff, 'f', 3, 64) + `"`
+	}
+
+	return fill
+}
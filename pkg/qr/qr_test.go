@@ -0,0 +1,101 @@
+package qr_test
+
+import (
+	"bytes"
+	"image/color"
+	"image/png"
+	"strings"
+	"testing"
+
+	"github.com/jacekdobrowolski/goshort/pkg/qr"
+)
+
+func Test_PNG(t *testing.T) {
+	t.Parallel()
+
+	code, err := qr.New("http://localhost/abc", qr.LevelM)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	red := color.NRGBA{R: 0xff, A: 0xff}
+	white := color.NRGBA{R: 0xff, G: 0xff, B: 0xff, A: 0xff}
+
+	var buf bytes.Buffer
+	if err := code.PNG(&buf, qr.Options{Size: 100, Margin: 4, Foreground: red, Background: white}); err != nil {
+		t.Fatal(err)
+	}
+
+	img, err := png.Decode(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if bounds := img.Bounds(); bounds.Dx() != 100 || bounds.Dy() != 100 {
+		t.Errorf("expected 100x100 image got %v", bounds)
+	}
+
+	// version 2 codes have 25 modules, with the margin 33 modules of 3px
+	// fit in 100px and the top left finder pattern starts at 12px
+	if c := color.NRGBAModel.Convert(img.At(11, 11)); c != white {
+		t.Errorf("expected margin to be background got %v", c)
+	}
+
+	if c := color.NRGBAModel.Convert(img.At(12, 12)); c != red {
+		t.Errorf("expected finder pattern to be foreground got %v", c)
+	}
+
+	if err := code.PNG(&buf, qr.Options{Size: 20, Margin: 4}); err == nil {
+		t.Error("expected error for size smaller than the code")
+	}
+}
+
+func Test_SVG(t *testing.T) {
+	t.Parallel()
+
+	code, err := qr.New("http://localhost/abc", qr.LevelH)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var buf bytes.Buffer
+
+	err = code.SVG(&buf, qr.Options{
+		Size:       512,
+		Margin:     0,
+		Foreground: color.NRGBA{A: 0xff},
+		Background: color.NRGBA{R: 0xff, G: 0xff, B: 0xff},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	svg := buf.String()
+	for _, want := range []string{`width="512"`, `fill="#000000"`, `fill="#ffffff" fill-opacity="0.000"`, `d="M0 0h1`} {
+		if !strings.Contains(svg, want) {
+			t.Errorf("expected svg to contain %s got %s", want, svg)
+		}
+	}
+}
+
+func Test_ParseColor(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		value string
+		want  color.NRGBA
+		err   bool
+	}{
+		{value: "#ff8000", want: color.NRGBA{R: 0xff, G: 0x80, A: 0xff}},
+		{value: "00000080", want: color.NRGBA{A: 0x80}},
+		{value: "fff", err: true},
+		{value: "red", err: true},
+	}
+
+	for _, tt := range tests {
+		got, err := qr.ParseColor(tt.value)
+		if (err != nil) != tt.err || got != tt.want {
+			t.Errorf("%s expected %v error %t got %v %v", tt.value, tt.want, tt.err, got, err)
+		}
+	}
+}
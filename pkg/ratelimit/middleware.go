@@ -0,0 +1,65 @@
+package ratelimit
+
+import (
+	"log/slog"
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Middleware limits requests per key returned by keyFunc and reports the
+// bucket state in RateLimit-* headers. Limiter errors fail open.
+func Middleware(
+	handler http.Handler,
+	logger *slog.Logger,
+	limiter Limiter,
+	group string,
+	limit Limit,
+	keyFunc func(*http.Request) string,
+) http.Handler {
+	meter := otel.Meter("ratelimit")
+
+	counter, err := meter.Int64Counter("ratelimit_rejected_total")
+	if err != nil {
+		logger.Error("error creating meter", slog.String("err", err.Error()))
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+
+		result, err := limiter.Allow(ctx, group+":"+keyFunc(r), limit)
+		if err != nil {
+			logger.ErrorContext(ctx, "rate limiter error", slog.String("err", err.Error()))
+			handler.ServeHTTP(w, r)
+
+			return
+		}
+
+		header := w.Header()
+		header.Set("RateLimit-Limit", strconv.Itoa(limit.Burst))
+		header.Set("RateLimit-Remaining", strconv.Itoa(result.Remaining))
+		header.Set("RateLimit-Reset", ceilSeconds(result.Reset))
+
+		if !result.Allowed {
+			trace.SpanFromContext(ctx).SetAttributes(attribute.String("ratelimit.group", group))
+			counter.Add(ctx, 1, metric.WithAttributes(attribute.String("group", group)))
+
+			header.Set("Retry-After", ceilSeconds(result.RetryAfter))
+			http.Error(w, http.StatusText(http.StatusTooManyRequests), http.StatusTooManyRequests)
+
+			return
+		}
+
+		handler.ServeHTTP(w, r)
+	})
+}
+
+func ceilSeconds(d time.Duration) string {
+	return strconv.Itoa(int(math.Ceil(d.Seconds())))
+}
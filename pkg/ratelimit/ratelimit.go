@@ -0,0 +1,99 @@
+package ratelimit
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var errInvalidLimit = errors.New("invalid rate limit, expected COUNT/PERIOD[:BURST] e.g. 10/s or 100/1m:200")
+
+// Limit is a token bucket refilled with Rate tokens per second up to Burst.
+type Limit struct {
+	Rate  float64
+	Burst int
+}
+
+// ParseLimit parses COUNT/PERIOD[:BURST], burst defaults to COUNT.
+func ParseLimit(value string) (Limit, error) {
+	rateSpec, burstSpec, hasBurst := strings.Cut(strings.TrimSpace(value), ":")
+
+	countSpec, periodSpec, ok := strings.Cut(rateSpec, "/")
+	if !ok {
+		return Limit{}, errInvalidLimit
+	}
+
+	count, err := strconv.Atoi(countSpec)
+	if err != nil || count <= 0 {
+		return Limit{}, errInvalidLimit
+	}
+
+	if periodSpec == "s" || periodSpec == "m" || periodSpec == "h" {
+		periodSpec = "1" + periodSpec
+	}
+
+	period, err := time.ParseDuration(periodSpec)
+	if err != nil || period <= 0 {
+		return Limit{}, errInvalidLimit
+	}
+
+	limit := Limit{
+		Rate:  float64(count) / period.Seconds(),
+		Burst: count,
+	}
+
+	if hasBurst {
+		limit.Burst, err = strconv.Atoi(burstSpec)
+		if err != nil || limit.Burst <= 0 {
+			return Limit{}, errInvalidLimit
+		}
+	}
+
+	return limit, nil
+}
+
+// Result describes the bucket after a request was counted.
+type Result struct {
+	Allowed   bool
+	Remaining int
+	// RetryAfter is how long until the next request would be allowed.
+	RetryAfter time.Duration
+	// Reset is how long until the bucket is full again.
+	Reset time.Duration
+}
+
+type Limiter interface {
+	Allow(ctx context.Context, key string, limit Limit) (Result, error)
+}
+
+// ResultFromTokens builds a Result from the tokens left in the bucket.
+// Backends call it after taking a token when allowed.
+func ResultFromTokens(allowed bool, tokens float64, limit Limit) Result {
+	result := Result{
+		Allowed:   allowed,
+		Remaining: max(0, int(math.Floor(tokens))),
+		Reset:     secondsToDuration((float64(limit.Burst) - tokens) / limit.Rate),
+	}
+
+	if !allowed {
+		result.RetryAfter = secondsToDuration((1 - tokens) / limit.Rate)
+	}
+
+	return result
+}
+
+func secondsToDuration(seconds float64) time.Duration {
+	if seconds <= 0 {
+		return 0
+	}
+
+	return time.Duration(seconds * float64(time.Second))
+}
+
+func (l Limit) String() string {
+	return fmt.Sprintf("%g/s:%d", l.Rate, l.Burst)
+}
@@ -0,0 +1,123 @@
+package ratelimit_test
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/jacekdobrowolski/goshort/pkg/ratelimit"
+)
+
+func Test_ParseLimit(t *testing.T) {
+	t.Parallel()
+
+	cases := []struct {
+		value string
+		want  ratelimit.Limit
+		err   bool
+	}{
+		{value: "10/s", want: ratelimit.Limit{Rate: 10, Burst: 10}},
+		{value: "60/m:5", want: ratelimit.Limit{Rate: 1, Burst: 5}},
+		{value: "30/30s", want: ratelimit.Limit{Rate: 1, Burst: 30}},
+		{value: "10", err: true},
+		{value: "0/s", err: true},
+		{value: "10/x", err: true},
+		{value: "10/s:0", err: true},
+	}
+
+	for _, tc := range cases {
+		got, err := ratelimit.ParseLimit(tc.value)
+		if tc.err {
+			if err == nil {
+				t.Errorf("%q expected error got %v", tc.value, got)
+			}
+
+			continue
+		}
+
+		if err != nil {
+			t.Errorf("%q unexpected error %v", tc.value, err)
+		}
+
+		if got != tc.want {
+			t.Errorf("%q expected %v got %v", tc.value, tc.want, got)
+		}
+	}
+}
+
+func Test_MemoryLimiter(t *testing.T) {
+	t.Parallel()
+
+	now := time.Unix(0, 0)
+	limiter := ratelimit.NewMemoryLimiterWithClock(func() time.Time { return now })
+	limit := ratelimit.Limit{Rate: 1, Burst: 2}
+	ctx := context.Background()
+
+	for i := range 2 {
+		result, _ := limiter.Allow(ctx, "a", limit)
+		if !result.Allowed {
+			t.Fatalf("request %d expected allowed", i)
+		}
+	}
+
+	result, _ := limiter.Allow(ctx, "a", limit)
+	if result.Allowed || result.Remaining != 0 {
+		t.Fatalf("expected rejection got %+v", result)
+	}
+
+	if result.RetryAfter != time.Second {
+		t.Errorf("expected RetryAfter %s got %s", time.Second, result.RetryAfter)
+	}
+
+	if result, _ := limiter.Allow(ctx, "b", limit); !result.Allowed {
+		t.Error("expected separate bucket for other key")
+	}
+
+	now = now.Add(time.Second)
+
+	if result, _ := limiter.Allow(ctx, "a", limit); !result.Allowed {
+		t.Error("expected allowed after refill")
+	}
+}
+
+func Test_Middleware(t *testing.T) {
+	t.Parallel()
+
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	limiter := ratelimit.NewMemoryLimiterWithClock(func() time.Time { return time.Unix(0, 0) })
+	next := http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) { w.WriteHeader(http.StatusOK) })
+	handler := ratelimit.Middleware(next, logger, limiter, "create", ratelimit.Limit{Rate: 0.1, Burst: 1},
+		func(r *http.Request) string { return r.RemoteAddr })
+
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/", nil))
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected StatusCode %d got %d", http.StatusOK, w.Code)
+	}
+
+	if remaining := w.Header().Get("RateLimit-Remaining"); remaining != "0" {
+		t.Errorf("expected RateLimit-Remaining 0 got %q", remaining)
+	}
+
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/", nil))
+
+	if w.Code != http.StatusTooManyRequests {
+		t.Fatalf("expected StatusCode %d got %d", http.StatusTooManyRequests, w.Code)
+	}
+
+	for header, want := range map[string]string{
+		"Retry-After":     "10",
+		"RateLimit-Limit": "1",
+		"RateLimit-Reset": "10",
+	} {
+		if got := w.Header().Get(header); got != want {
+			t.Errorf("expected %s %q got %q", header, want, got)
+		}
+	}
+}
@@ -0,0 +1,177 @@
+package urlpolicy
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/netip"
+	"net/url"
+	"slices"
+	"strings"
+)
+
+const DefaultMaxLength = 2048
+
+// Resolver looks up host addresses, *net.Resolver implements it.
+type Resolver interface {
+	LookupNetIP(ctx context.Context, network, host string) ([]netip.Addr, error)
+}
+
+// Blocklist reports the name of the list flagging a URL.
+type Blocklist interface {
+	Blocked(u *url.URL) (string, bool)
+}
+
+// Violation is returned when a URL breaks the policy, Reason is safe to
+// show to the client.
+type Violation struct {
+	Reason string
+}
+
+func (v *Violation) Error() string {
+	return "url rejected: " + v.Reason
+}
+
+func violation(format string, args ...any) *Violation {
+	return &Violation{Reason: fmt.Sprintf(format, args...)}
+}
+
+// Policy decides which destination URLs links may point to.
+//
+// Domain patterns are exact hosts, "*.example.com" matching any subdomain of
+// example.com but not example.com itself, or "*" matching every host.
+type Policy struct {
+	// Schemes allowed in destinations, compared case-insensitively.
+	Schemes []string
+	// AllowDomains restricts destinations to matching hosts when not empty.
+	AllowDomains []string
+	DenyDomains  []string
+	// AllowPrivate permits hosts resolving to loopback, private, link-local
+	// or unspecified addresses.
+	AllowPrivate bool
+	// MaxLength in bytes, 0 is unlimited.
+	MaxLength int
+	// Resolver defaults to net.DefaultResolver.
+	Resolver Resolver
+	// Blocklist of known malicious destinations, optional.
+	Blocklist Blocklist
+}
+
+// Default allows http and https destinations on public addresses.
+func Default() Policy {
+	return Policy{
+		Schemes:   []string{"http", "https"},
+		MaxLength: DefaultMaxLength,
+	}
+}
+
+// Check returns a *Violation when raw breaks the policy.
+func (p *Policy) Check(ctx context.Context, raw string) error {
+	if p.MaxLength > 0 && len(raw) > p.MaxLength {
+		return violation("url longer than %d bytes", p.MaxLength)
+	}
+
+	u, err := url.ParseRequestURI(raw)
+	if err != nil {
+		return violation("invalid url")
+	}
+
+	if !slices.Contains(p.Schemes, strings.ToLower(u.Scheme)) {
+		return violation("scheme %q is not allowed", u.Scheme)
+	}
+
+	host := strings.TrimSuffix(strings.ToLower(u.Hostname()), ".")
+	if host == "" {
+		return violation("url has no host")
+	}
+
+	if matchAny(p.DenyDomains, host) {
+		return violation("domain %q is denied", host)
+	}
+
+	if len(p.AllowDomains) > 0 && !matchAny(p.AllowDomains, host) {
+		return violation("domain %q is not allowed", host)
+	}
+
+	if p.Blocklist != nil {
+		if list, blocked := p.Blocklist.Blocked(u); blocked {
+			return violation("destination is on blocklist %s", list)
+		}
+	}
+
+	if p.AllowPrivate {
+		return nil
+	}
+
+	return p.checkAddresses(ctx, host)
+}
+
+func (p *Policy) checkAddresses(ctx context.Context, host string) error {
+	addrs := []netip.Addr{}
+
+	if addr, err := netip.ParseAddr(host); err == nil {
+		addrs = append(addrs, addr)
+	} else {
+		resolver := p.Resolver
+		if resolver == nil {
+			resolver = net.DefaultResolver
+		}
+
+		addrs, err = resolver.LookupNetIP(ctx, "ip", host)
+		if err != nil || len(addrs) == 0 {
+			return violation("host %q does not resolve", host)
+		}
+	}
+
+	for _, addr := range addrs {
+		if !publicAddr(addr.Unmap()) {
+			return violation("host %q resolves to non-public address %s", host, addr)
+		}
+	}
+
+	return nil
+}
+
+func publicAddr(addr netip.Addr) bool {
+	return !(addr.IsLoopback() || addr.IsPrivate() || addr.IsUnspecified() ||
+		addr.IsLinkLocalUnicast() || addr.IsLinkLocalMulticast() ||
+		addr.IsInterfaceLocalMulticast() || addr.IsMulticast())
+}
+
+func matchAny(patterns []string, host string) bool {
+	for _, pattern := range patterns {
+		if Match(pattern, host) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Match reports whether host matches an exact or "*." wildcard pattern.
+func Match(pattern, host string) bool {
+	pattern = strings.TrimSuffix(strings.ToLower(pattern), ".")
+
+	if pattern == "*" {
+		return true
+	}
+
+	if domain, ok := strings.CutPrefix(pattern, "*."); ok {
+		return strings.HasSuffix(host, "."+domain)
+	}
+
+	return host == pattern
+}
+
+// ParseList splits a comma separated list dropping empty entries.
+func ParseList(list string) []string {
+	var items []string
+
+	for _, item := range strings.Split(list, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+
+	return items
+}
@@ -0,0 +1,105 @@
+package urlpolicy_test
+
+import (
+	"context"
+	"errors"
+	"net/netip"
+	"strings"
+	"testing"
+
+	"github.com/jacekdobrowolski/goshort/pkg/urlpolicy"
+)
+
+type staticResolver map[string][]netip.Addr
+
+func (r staticResolver) LookupNetIP(_ context.Context, _, host string) ([]netip.Addr, error) {
+	addrs, ok := r[host]
+	if !ok {
+		return nil, errors.New("no such host")
+	}
+
+	return addrs, nil
+}
+
+func Test_PolicyCheck(t *testing.T) {
+	t.Parallel()
+
+	policy := urlpolicy.Default()
+	policy.DenyDomains = []string{"*.evil.test"}
+	policy.MaxLength = 64
+	policy.Resolver = staticResolver{
+		"example.com":   {netip.MustParseAddr("93.184.216.34")},
+		"localhost":     {netip.MustParseAddr("127.0.0.1")},
+		"intranet.test": {netip.MustParseAddr("93.184.216.34"), netip.MustParseAddr("10.1.2.3")},
+		"evil.test":     {netip.MustParseAddr("93.184.216.35")},
+	}
+
+	tests := []struct {
+		url    string
+		reason string
+	}{
+		{url: "https://example.com/path", reason: ""},
+		{url: "HTTP://Example.com./", reason: ""},
+		{url: "https://evil.test/", reason: ""},
+		{url: "javascript:alert(1)", reason: `scheme "javascript" is not allowed`},
+		{url: "not a url", reason: "invalid url"},
+		{url: "file:///etc/passwd", reason: `scheme "file" is not allowed`},
+		{url: "ftp://example.com/", reason: `scheme "ftp" is not allowed`},
+		{url: "https://www.evil.test/", reason: `domain "www.evil.test" is denied`},
+		{url: "http://localhost:8080/", reason: `host "localhost" resolves to non-public address 127.0.0.1`},
+		{url: "http://intranet.test/", reason: `host "intranet.test" resolves to non-public address 10.1.2.3`},
+		{url: "http://10.0.0.1/", reason: `host "10.0.0.1" resolves to non-public address 10.0.0.1`},
+		{
+			url:    "http://[::ffff:127.0.0.1]/",
+			reason: `host "::ffff:127.0.0.1" resolves to non-public address ::ffff:127.0.0.1`,
+		},
+		{url: "http://unknown.test/", reason: `host "unknown.test" does not resolve`},
+		{url: "https://example.com/" + strings.Repeat("a", 64), reason: "url longer than 64 bytes"},
+	}
+
+	for _, tt := range tests {
+		err := policy.Check(context.Background(), tt.url)
+
+		if tt.reason == "" {
+			if err != nil {
+				t.Errorf("%s expected allowed got %v", tt.url, err)
+			}
+
+			continue
+		}
+
+		var violation *urlpolicy.Violation
+		if !errors.As(err, &violation) {
+			t.Errorf("%s expected violation got %v", tt.url, err)
+
+			continue
+		}
+
+		if violation.Reason != tt.reason {
+			t.Errorf("%s expected reason %q got %q", tt.url, tt.reason, violation.Reason)
+		}
+	}
+}
+
+func Test_PolicyAllowDomains(t *testing.T) {
+	t.Parallel()
+
+	policy := urlpolicy.Policy{
+		Schemes:      []string{"https"},
+		AllowDomains: []string{"example.com", "*.example.org"},
+		AllowPrivate: true,
+	}
+
+	for url, allowed := range map[string]bool{
+		"https://example.com/":       true,
+		"https://sub.example.com/":   false,
+		"https://docs.example.org/":  true,
+		"https://example.org/":       false,
+		"https://notexample.org/":    false,
+		"https://localhost/internal": false,
+	} {
+		if err := policy.Check(context.Background(), url); (err == nil) != allowed {
+			t.Errorf("%s expected allowed %t got %v", url, allowed, err)
+		}
+	}
+}
@@ -0,0 +1,103 @@
+package useragent
+
+import (
+	"strings"
+)
+
+// Operating systems reported by Parse.
+const (
+	OSiOS      = "ios"
+	OSAndroid  = "android"
+	OSWindows  = "windows"
+	OSMacOS    = "macos"
+	OSLinux    = "linux"
+	OSChromeOS = "chromeos"
+	OSOther    = "other"
+)
+
+// Device classes reported by Parse.
+const (
+	DeviceMobile  = "mobile"
+	DeviceTablet  = "tablet"
+	DeviceDesktop = "desktop"
+)
+
+// botMarkers are lower case substrings of crawler, preview fetcher and
+// HTTP library user agents.
+//
+//nolint:gochecknoglobals // read only
+var botMarkers = []string{
+	"bot", "crawl", "spider", "slurp", "facebookexternalhit", "embedly", "preview",
+	"curl/", "wget/", "python-requests", "go-http-client", "okhttp", "headlesschrome", "lighthouse",
+}
+
+type Agent struct {
+	OS     string
+	Device string
+	// Bot is set for crawlers, link preview fetchers, HTTP libraries and
+	// requests without a User-Agent.
+	Bot bool
+}
+
+// Parse classifies a User-Agent header, it only looks for well known
+// tokens and never fails.
+func Parse(userAgent string) Agent {
+	lower := strings.ToLower(userAgent)
+
+	agent := Agent{
+		OS:     parseOS(lower),
+		Device: DeviceDesktop,
+		Bot:    strings.TrimSpace(lower) == "",
+	}
+
+	for _, marker := range botMarkers {
+		if strings.Contains(lower, marker) {
+			agent.Bot = true
+
+			break
+		}
+	}
+
+	switch {
+	case strings.Contains(lower, "ipad") || strings.Contains(lower, "tablet") ||
+		agent.OS == OSAndroid && !strings.Contains(lower, "mobile"):
+		agent.Device = DeviceTablet
+	case strings.Contains(lower, "mobile") || strings.Contains(lower, "iphone") || strings.Contains(lower, "ipod"):
+		agent.Device = DeviceMobile
+	}
+
+	return agent
+}
+
+func parseOS(lower string) string {
+	switch {
+	case strings.Contains(lower, "iphone") || strings.Contains(lower, "ipad") || strings.Contains(lower, "ipod"):
+		return OSiOS
+	case strings.Contains(lower, "android"):
+		return OSAndroid
+	// the token alone would match "microsoft"
+	case strings.Contains(lower, "; cros ") || strings.Contains(lower, "(cros "):
+		return OSChromeOS
+	case strings.Contains(lower, "windows"):
+		return OSWindows
+	case strings.Contains(lower, "macintosh") || strings.Contains(lower, "mac os x"):
+		return OSMacOS
+	case strings.Contains(lower, "linux"):
+		return OSLinux
+	default:
+		return OSOther
+	}
+}
+
+func ValidOS(os string) bool {
+	switch os {
+	case OSiOS, OSAndroid, OSWindows, OSMacOS, OSLinux, OSChromeOS, OSOther:
+		return true
+	default:
+		return false
+	}
+}
+
+func ValidDevice(device string) bool {
+	return device == DeviceMobile || device == DeviceTablet || device == DeviceDesktop
+}
@@ -0,0 +1,85 @@
+package useragent_test
+
+import (
+	"testing"
+
+	"github.com/jacekdobrowolski/goshort/pkg/useragent"
+)
+
+func Test_Parse(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name      string
+		userAgent string
+		want      useragent.Agent
+	}{
+		{
+			name: "iphone",
+			userAgent: "Mozilla/5.0 (iPhone; CPU iPhone OS 17_4 like Mac OS X) AppleWebKit/605.1.15 " +
+				"(KHTML, like Gecko) Version/17.4 Mobile/15E148 Safari/604.1",
+			want: useragent.Agent{OS: useragent.OSiOS, Device: useragent.DeviceMobile},
+		},
+		{
+			name: "ipad",
+			userAgent: "Mozilla/5.0 (iPad; CPU OS 16_6 like Mac OS X) AppleWebKit/605.1.15 " +
+				"(KHTML, like Gecko) Version/16.6 Mobile/15E148 Safari/604.1",
+			want: useragent.Agent{OS: useragent.OSiOS, Device: useragent.DeviceTablet},
+		},
+		{
+			name: "android phone",
+			userAgent: "Mozilla/5.0 (Linux; Android 14; Pixel 8) AppleWebKit/537.36 " +
+				"(KHTML, like Gecko) Chrome/124.0.0.0 Mobile Safari/537.36",
+			want: useragent.Agent{OS: useragent.OSAndroid, Device: useragent.DeviceMobile},
+		},
+		{
+			name: "android tablet",
+			userAgent: "Mozilla/5.0 (Linux; Android 13; SM-X710) AppleWebKit/537.36 " +
+				"(KHTML, like Gecko) Chrome/124.0.0.0 Safari/537.36",
+			want: useragent.Agent{OS: useragent.OSAndroid, Device: useragent.DeviceTablet},
+		},
+		{
+			name:      "windows desktop",
+			userAgent: "Mozilla/5.0 (Windows NT 10.0; Win64; x64; rv:125.0) Gecko/20100101 Firefox/125.0",
+			want:      useragent.Agent{OS: useragent.OSWindows, Device: useragent.DeviceDesktop},
+		},
+		{
+			name: "chromebook",
+			userAgent: "Mozilla/5.0 (X11; CrOS x86_64 15633.69.0) AppleWebKit/537.36 " +
+				"(KHTML, like Gecko) Chrome/124.0.0.0 Safari/537.36",
+			want: useragent.Agent{OS: useragent.OSChromeOS, Device: useragent.DeviceDesktop},
+		},
+		{
+			name:      "outlook",
+			userAgent: "Microsoft Office/16.0 (Windows NT 10.0; Microsoft Outlook 16.0.17328; Pro)",
+			want:      useragent.Agent{OS: useragent.OSWindows, Device: useragent.DeviceDesktop},
+		},
+		{
+			name: "mac desktop",
+			userAgent: "Mozilla/5.0 (Macintosh; Intel Mac OS X 10_15_7) AppleWebKit/605.1.15 " +
+				"(KHTML, like Gecko) Version/17.4 Safari/605.1.15",
+			want: useragent.Agent{OS: useragent.OSMacOS, Device: useragent.DeviceDesktop},
+		},
+		{
+			name:      "googlebot",
+			userAgent: "Mozilla/5.0 (compatible; Googlebot/2.1; +http://www.google.com/bot.html)",
+			want:      useragent.Agent{OS: useragent.OSOther, Device: useragent.DeviceDesktop, Bot: true},
+		},
+		{
+			name:      "curl",
+			userAgent: "curl/8.5.0",
+			want:      useragent.Agent{OS: useragent.OSOther, Device: useragent.DeviceDesktop, Bot: true},
+		},
+		{
+			name:      "empty",
+			userAgent: "",
+			want:      useragent.Agent{OS: useragent.OSOther, Device: useragent.DeviceDesktop, Bot: true},
+		},
+	}
+
+	for _, tt := range tests {
+		if got := useragent.Parse(tt.userAgent); got != tt.want {
+			t.Errorf("%s expected %+v got %+v", tt.name, tt.want, got)
+		}
+	}
+}
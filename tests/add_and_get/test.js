@@ -30,7 +30,10 @@ export default function () {
     const randomUrl = generateRandomUrl();
     const payload = JSON.stringify({ url: randomUrl });
     const params = {
-        headers: { 'Content-Type': 'application/json' },
+        headers: {
+            'Content-Type': 'application/json',
+            'X-Api-Key': __ENV.LINKS_API_KEY,
+        },
     };
 
     const res = http.post(url, payload, params);